@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/chaos"
+	"github.com/rayomqio/benchmq/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+// chaosCmd represents the chaos-proxy command
+var chaosCmd = &cobra.Command{
+	Use:   "chaos-proxy",
+	Short: "Run a TCP proxy that injects network faults between clients and the broker",
+	Long: `Runs a TCP proxy in front of the broker that degrades every connection
+passing through it, for reproducible bad-network tests without root or tc.
+Point benchmarks at the listen address.
+
+Parameters:
+    - listen: Address the proxy accepts clients on
+    - upstream: Broker address, defaults to host:port
+    - latency: Delay added to data in each direction
+    - jitter: Random variation of the latency, +/- this value
+    - bandwidth: Bytes per second per direction and connection (KB, MB, GB suffixes)
+    - reset-prob: Probability per second that a connection is reset
+    - stall-prob: Probability per second that a connection stalls
+    - stall-duration: Length of a stall`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+		// Parse flags
+		host, err := cmd.Flags().GetString("host")
+		if err != nil {
+			logger.Error("failed to parse host flag", logger.ErrorAttr(err))
+			return
+		}
+
+		port, err := cmd.Flags().GetUint16("port")
+		if err != nil {
+			logger.Error("failed to parse port flag", logger.ErrorAttr(err))
+			return
+		}
+
+		listen, err := cmd.Flags().GetString("listen")
+		if err != nil {
+			logger.Error("failed to parse listen flag", logger.ErrorAttr(err))
+			return
+		}
+
+		upstream, err := cmd.Flags().GetString("upstream")
+		if err != nil {
+			logger.Error("failed to parse upstream flag", logger.ErrorAttr(err))
+			return
+		}
+		if upstream == "" {
+			upstream = net.JoinHostPort(host, strconv.Itoa(int(port)))
+		}
+
+		latency, err := cmd.Flags().GetDuration("latency")
+		if err != nil {
+			logger.Error("failed to parse latency flag", logger.ErrorAttr(err))
+			return
+		}
+
+		jitter, err := cmd.Flags().GetDuration("jitter")
+		if err != nil {
+			logger.Error("failed to parse jitter flag", logger.ErrorAttr(err))
+			return
+		}
+
+		bandwidthStr, err := cmd.Flags().GetString("bandwidth")
+		if err != nil {
+			logger.Error("failed to parse bandwidth flag", logger.ErrorAttr(err))
+			return
+		}
+		bandwidth, err := chaos.ParseBandwidth(bandwidthStr)
+		if err != nil {
+			logger.Error("failed to parse bandwidth flag", logger.ErrorAttr(err))
+			return
+		}
+
+		resetProb, err := cmd.Flags().GetFloat64("reset-prob")
+		if err != nil {
+			logger.Error("failed to parse reset-prob flag", logger.ErrorAttr(err))
+			return
+		}
+
+		stallProb, err := cmd.Flags().GetFloat64("stall-prob")
+		if err != nil {
+			logger.Error("failed to parse stall-prob flag", logger.ErrorAttr(err))
+			return
+		}
+
+		stallDuration, err := cmd.Flags().GetDuration("stall-duration")
+		if err != nil {
+			logger.Error("failed to parse stall-duration flag", logger.ErrorAttr(err))
+			return
+		}
+
+		statsInterval, err := cmd.Flags().GetDuration("stats-interval")
+		if err != nil {
+			logger.Error("failed to parse stats-interval flag", logger.ErrorAttr(err))
+			return
+		}
+
+		proxy, err := chaos.New(listen, upstream,
+			chaos.WithLatency(latency),
+			chaos.WithJitter(jitter),
+			chaos.WithBandwidth(bandwidth),
+			chaos.WithResetProb(resetProb),
+			chaos.WithStallProb(stallProb),
+			chaos.WithStallDuration(stallDuration),
+		)
+		if err != nil {
+			logger.Error("failed to create chaos proxy", logger.ErrorAttr(err))
+			return
+		}
+		if err := proxy.Start(); err != nil {
+			logger.Error("failed to start chaos proxy", logger.ErrorAttr(err))
+			return
+		}
+		logger.Info("chaos proxy started", logger.String("listen", proxy.Addr()), logger.String("upstream", upstream))
+
+		var ticks <-chan time.Time
+		if statsInterval > 0 {
+			ticker := time.NewTicker(statsInterval)
+			defer ticker.Stop()
+			ticks = ticker.C
+		}
+
+		for {
+			select {
+			case <-ticks:
+				logger.Info("chaos proxy stats", chaosStatsAttrs(proxy.Stats())...)
+			case <-sigs:
+				_ = proxy.Close()
+				logger.Info("chaos proxy stopped", append(chaosStatsAttrs(proxy.Stats()), logger.State("interrupted"))...)
+				return
+			}
+		}
+	},
+}
+
+func chaosStatsAttrs(s chaos.Stats) []slog.Attr {
+	return []slog.Attr{
+		logger.Any("connections", s.Connections),
+		logger.Any("active", s.Active),
+		logger.Any("resets", s.Resets),
+		logger.Any("stalls", s.Stalls),
+		logger.Any("bytesUp", s.BytesUp),
+		logger.Any("bytesDown", s.BytesDown),
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(chaosCmd)
+
+	// Register flags
+	chaosCmd.Flags().String("listen", chaos.DefaultListen, "Address the proxy accepts clients on")
+	chaosCmd.Flags().String("upstream", "", "Broker address host:port (default host:port flags)")
+	chaosCmd.Flags().Duration("latency", 0, "Delay added to data in each direction")
+	chaosCmd.Flags().Duration("jitter", 0, "Random variation of the latency, +/- this value")
+	chaosCmd.Flags().String("bandwidth", "0", "Bytes per second per direction and connection, with KB, MB or GB suffix (0 is unlimited)")
+	chaosCmd.Flags().Float64("reset-prob", 0, "Probability per second that a connection is reset")
+	chaosCmd.Flags().Float64("stall-prob", 0, "Probability per second that a connection stops forwarding for stall-duration")
+	chaosCmd.Flags().Duration("stall-duration", chaos.DefaultStallDuration, "Length of an injected stall")
+	chaosCmd.Flags().Duration("stats-interval", 10*time.Second, "Interval of the stats log line (0 disables it)")
+}
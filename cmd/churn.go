@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rayomqio/benchmq/internal/bench"
+	"github.com/rayomqio/benchmq/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+// churnCmd represents the churn command
+var churnCmd = &cobra.Command{
+	Use:   "churn",
+	Short: "Measure subscribe and unsubscribe churn of long-lived clients",
+	Long: `Long-lived clients repeatedly subscribe to one of their filters, hold it and
+unsubscribe again at a configured rate, like apps subscribing to the topics of
+the screen a user navigates to. Publishers keep sending to every filter, and
+the SUBACK and UNSUBACK latency as well as messages that arrived for a filter
+after its UNSUBACK are reported.
+
+Parameters:
+    - clients: Number of churning clients
+    - count: Subscribe/unsubscribe cycles per client
+    - filters: Filters per client the cycles rotate over
+    - rate: Cycles per second per client
+    - dwell: Time a subscription is held (default: half a cycle)
+    - publishers: Number of clients publishing to all filters
+    - publish-rate: Messages per second per publisher
+    - qos: Quality of service level (0, 1, 2)
+    - topic: Topic prefix of the filters`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+		// Parse flags
+		host, err := cmd.Flags().GetString("host")
+		if err != nil {
+			logger.Error("failed to parse host flag", logger.ErrorAttr(err))
+			return
+		}
+
+		port, err := cmd.Flags().GetUint16("port")
+		if err != nil {
+			logger.Error("failed to parse port flag", logger.ErrorAttr(err))
+			return
+		}
+
+		clientID, err := cmd.Flags().GetString("clientID")
+		if err != nil {
+			logger.Error("failed to parse clientID flag", logger.ErrorAttr(err))
+			return
+		}
+
+		clients, err := cmd.Flags().GetInt("clients")
+		if err != nil {
+			logger.Error("failed to parse clients flag", logger.ErrorAttr(err))
+			return
+		}
+
+		count, err := cmd.Flags().GetInt("count")
+		if err != nil {
+			logger.Error("failed to parse count flag", logger.ErrorAttr(err))
+			return
+		}
+
+		qos, err := cmd.Flags().GetUint16("qos")
+		if err != nil {
+			logger.Error("failed to parse qos flag", logger.ErrorAttr(err))
+			return
+		}
+
+		topic, err := cmd.Flags().GetString("topic")
+		if err != nil {
+			logger.Error("failed to parse topic flag", logger.ErrorAttr(err))
+			return
+		}
+
+		var churn bench.Churn
+		if churn.Filters, err = cmd.Flags().GetInt("filters"); err != nil {
+			logger.Error("failed to parse filters flag", logger.ErrorAttr(err))
+			return
+		}
+		if churn.Rate, err = cmd.Flags().GetFloat64("rate"); err != nil {
+			logger.Error("failed to parse rate flag", logger.ErrorAttr(err))
+			return
+		}
+		if churn.Dwell, err = cmd.Flags().GetDuration("dwell"); err != nil {
+			logger.Error("failed to parse dwell flag", logger.ErrorAttr(err))
+			return
+		}
+		if churn.Publishers, err = cmd.Flags().GetInt("publishers"); err != nil {
+			logger.Error("failed to parse publishers flag", logger.ErrorAttr(err))
+			return
+		}
+		if churn.PublishRate, err = cmd.Flags().GetFloat64("publish-rate"); err != nil {
+			logger.Error("failed to parse publish-rate flag", logger.ErrorAttr(err))
+			return
+		}
+
+		clean, err := cmd.Flags().GetBool("clean")
+		if err != nil {
+			logger.Error("failed to parse clean flag", logger.ErrorAttr(err))
+			return
+		}
+
+		keepalive, err := cmd.Flags().GetUint16("keepalive")
+		if err != nil {
+			logger.Error("failed to parse keepalive flag", logger.ErrorAttr(err))
+			return
+		}
+
+		username, err := cmd.Flags().GetString("username")
+		if err != nil {
+			logger.Error("failed to parse username flag", logger.ErrorAttr(err))
+			return
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			logger.Error("failed to parse password flag", logger.ErrorAttr(err))
+			return
+		}
+
+		options, err := runOptions(cmd)
+		if err != nil {
+			logger.Error("failed to parse flags", logger.ErrorAttr(err))
+			return
+		}
+
+		// Create benchmark
+		b, err := bench.NewBenchmark(Cfg, append(options,
+			bench.WithClientID(clientID),
+			bench.WithClients(clients),
+			bench.WithMessageCount(count),
+			bench.WithQoS(qos),
+			bench.WithTopic(topic),
+			bench.WithChurn(churn),
+			bench.WithCleanSession(clean),
+			bench.WithKeepAlive(keepalive),
+			bench.WithUsername(username),
+			bench.WithPassword(password),
+			bench.WithHost(host),
+			bench.WithPort(port),
+		)...)
+		if err != nil {
+			logger.Error("failed to create benchmark", logger.ErrorAttr(err))
+			return
+		}
+
+		done := make(chan struct{})
+		go func() {
+			b.RunChurn()
+			close(done)
+		}()
+
+		select {
+		case <-sigs:
+			logger.Info("received shutdown signal", logger.State("interrupted"))
+			return
+		case <-done:
+			logger.Info("churn benchmark completed", logger.State("completed"))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(churnCmd)
+
+	// Register flags
+	churnCmd.Flags().IntP("clients", "c", 100, "Number of churning clients")
+	churnCmd.Flags().IntP("count", "n", 100, "Subscribe/unsubscribe cycles per client")
+	churnCmd.Flags().Uint16P("qos", "q", 0, "Quality of service level (0, 1, 2)")
+	churnCmd.Flags().StringP("topic", "t", "bench/churn", "Topic prefix of the filters")
+	churnCmd.Flags().Int("filters", bench.DefaultChurnFilters, "Filters per client the cycles rotate over")
+	churnCmd.Flags().Float64("rate", bench.DefaultChurnRate, "Subscribe/unsubscribe cycles per second per client")
+	churnCmd.Flags().Duration("dwell", 0, "Time a subscription is held (default: half a cycle)")
+	churnCmd.Flags().Int("publishers", bench.DefaultChurnPublishers, "Number of clients publishing to all filters")
+	churnCmd.Flags().Float64("publish-rate", bench.DefaultChurnPublishRate, "Messages per second per publisher")
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/conformance"
+	"github.com/rayomqio/benchmq/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+var conformanceCmd = &cobra.Command{
+	Use:   "conformance",
+	Short: "Check which MQTT features the broker implements correctly",
+	Long: `Run a battery of MQTT 3.1.1 protocol checks against the broker and print a pass/fail matrix.
+
+Checks:
+    - wildcard matching: + and # filters receive exactly the matching topics
+    - retained messages: delivered to new subscribers, cleared by an empty payload
+    - qos 2 exactly once: no missing or duplicated messages
+    - will messages: published on abnormal disconnect only
+    - session takeover: a second connection with the same client ID disconnects the first
+    - topic validation: wildcard PUBLISH topics and malformed filters are rejected
+    - max packet handling: malformed lengths are rejected, large payloads delivered intact`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Parse flags
+		host, err := cmd.Flags().GetString("host")
+		if err != nil {
+			logger.Error("failed to parse host", logger.ErrorAttr(err))
+			return
+		}
+
+		port, err := cmd.Flags().GetUint16("port")
+		if err != nil {
+			logger.Error("failed to parse port", logger.ErrorAttr(err))
+			return
+		}
+
+		username, err := cmd.Flags().GetString("username")
+		if err != nil {
+			logger.Error("failed to parse username", logger.ErrorAttr(err))
+			return
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			logger.Error("failed to parse password", logger.ErrorAttr(err))
+			return
+		}
+
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			logger.Error("failed to parse timeout", logger.ErrorAttr(err))
+			return
+		}
+
+		maxPacket, err := cmd.Flags().GetInt("max-packet")
+		if err != nil {
+			logger.Error("failed to parse max packet size", logger.ErrorAttr(err))
+			return
+		}
+
+		prefix, err := cmd.Flags().GetString("prefix")
+		if err != nil {
+			logger.Error("failed to parse topic prefix", logger.ErrorAttr(err))
+			return
+		}
+
+		cfg := *Cfg
+		cfg.Server.Host = host
+		cfg.Server.Port = port
+		cfg.Client.Username = username
+		cfg.Client.Password = password
+
+		suite, err := conformance.NewSuite(
+			&cfg,
+			conformance.WithTimeout(timeout),
+			conformance.WithMaxPacket(maxPacket),
+			conformance.WithTopicPrefix(prefix),
+		)
+		if err != nil {
+			logger.Error("failed to create conformance suite", logger.ErrorAttr(err))
+			return
+		}
+
+		results := suite.Run()
+
+		failed := 0
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "CHECK\tRESULT\tTIME\tDETAIL")
+		for _, r := range results {
+			status := "PASS"
+			if !r.Passed {
+				status = "FAIL"
+				failed++
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, status, r.Duration.Round(time.Millisecond), r.Detail)
+		}
+		_ = w.Flush()
+
+		logger.Info("conformance run completed", logger.Int("checks", len(results)), logger.Int("failed", failed))
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(conformanceCmd)
+
+	// Register flags
+	conformanceCmd.Flags().Duration("timeout", conformance.DefaultTimeout, "Maximum wait for expected messages in each check")
+	conformanceCmd.Flags().Int("max-packet", conformance.DefaultMaxPacket, "Payload size in bytes of the large packet check")
+	conformanceCmd.Flags().String("prefix", conformance.DefaultTopicPrefix, "Topic prefix used by all checks")
+}
@@ -73,9 +73,26 @@ var connCmd = &cobra.Command{
 			return
 		}
 
+		hold, err := cmd.Flags().GetDuration("hold")
+		if err != nil {
+			logger.Error("failed to parse hold flag", logger.ErrorAttr(err))
+			return
+		}
+
+		options, err := runOptions(cmd)
+		if err != nil {
+			logger.Error("failed to parse flags", logger.ErrorAttr(err))
+			return
+		}
+		dial, err := dialOptions(cmd)
+		if err != nil {
+			logger.Error("failed to parse flags", logger.ErrorAttr(err))
+			return
+		}
+		options = append(options, dial...)
+
 		// Create benchmark
-		b, err := bench.NewBenchmark(
-			Cfg,
+		b, err := bench.NewBenchmark(Cfg, append(options,
 			bench.WithClients(clients),
 			bench.WithDelay(delay),
 			bench.WithCleanSession(clean),
@@ -85,7 +102,8 @@ var connCmd = &cobra.Command{
 			bench.WithPassword(password),
 			bench.WithHost(host),
 			bench.WithPort(port),
-		)
+			bench.WithHold(hold),
+		)...)
 		if err != nil {
 			logger.Error("failed to create benchmark", logger.ErrorAttr(err))
 			return
@@ -114,4 +132,5 @@ func init() {
 	// Register flags
 	connCmd.Flags().IntP("clients", "c", 100, "Number of concurrent clients to connect")
 	connCmd.Flags().IntP("delay", "d", 1000, "Delay between each client connection in milliseconds")
+	connCmd.Flags().Duration("hold", 0, "Keep all connections open for this long after the last client connected (reports memory per connection)")
 }
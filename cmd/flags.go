@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rayomqio/benchmq/internal/bench"
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/payload"
+	"github.com/spf13/cobra"
+)
+
+// runOptions reads the flags every benchmark shares into bench options: the
+// client implementation, resource monitoring, broker statistics, timeline,
+// report and tracing
+func runOptions(cmd *cobra.Command) ([]bench.Option, error) {
+	flags := cmd.Flags()
+	clientImpl, err := flags.GetString("client-impl")
+	if err != nil {
+		return nil, err
+	}
+	monitorInterval, err := flags.GetDuration("monitor-interval")
+	if err != nil {
+		return nil, err
+	}
+	sysStats, err := flags.GetBool("sys-stats")
+	if err != nil {
+		return nil, err
+	}
+	sysTopic, err := flags.GetString("sys-topic")
+	if err != nil {
+		return nil, err
+	}
+	timelineInterval, err := flags.GetDuration("timeline-interval")
+	if err != nil {
+		return nil, err
+	}
+	timelinePath, err := flags.GetString("timeline")
+	if err != nil {
+		return nil, err
+	}
+	timelineFormat, err := flags.GetString("timeline-format")
+	if err != nil {
+		return nil, err
+	}
+	reportPath, err := flags.GetString("report")
+	if err != nil {
+		return nil, err
+	}
+	otlpEndpoint, err := flags.GetString("otlp-endpoint")
+	if err != nil {
+		return nil, err
+	}
+	traceFile, err := flags.GetString("trace-file")
+	if err != nil {
+		return nil, err
+	}
+	traceSample, err := flags.GetFloat64("trace-sample")
+	if err != nil {
+		return nil, err
+	}
+	traceService, err := flags.GetString("trace-service")
+	if err != nil {
+		return nil, err
+	}
+	traceContext, err := flags.GetBool("trace-context")
+	if err != nil {
+		return nil, err
+	}
+	command, settings := runSettings(cmd)
+
+	return []bench.Option{
+		bench.WithClientImpl(mqtt.Implementation(clientImpl)),
+		bench.WithMonitorInterval(monitorInterval),
+		bench.WithSysStats(sysStats, sysTopic),
+		bench.WithTimeline(timelineInterval, timelinePath, timelineFormat),
+		bench.WithReport(reportPath, command, settings),
+		bench.WithTracing(otlpEndpoint, traceFile, traceSample, traceService),
+		bench.WithTraceContext(traceContext),
+	}, nil
+}
+
+// dialOptions reads the flags that shape how clients reach the brokers into
+// bench options: broker URLs, source addresses, proxies and faults
+func dialOptions(cmd *cobra.Command) ([]bench.Option, error) {
+	flags := cmd.Flags()
+	brokers, err := flags.GetStringSlice("broker")
+	if err != nil {
+		return nil, err
+	}
+	strategy, err := flags.GetString("strategy")
+	if err != nil {
+		return nil, err
+	}
+	localAddrs, err := flags.GetStringSlice("local-addr")
+	if err != nil {
+		return nil, err
+	}
+	localPorts, err := flags.GetString("local-ports")
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := flags.GetString("proxy")
+	if err != nil {
+		return nil, err
+	}
+	proxyProtocol, err := flags.GetString("proxy-protocol")
+	if err != nil {
+		return nil, err
+	}
+	proxyProtocolSrc, err := flags.GetString("proxy-protocol-src")
+	if err != nil {
+		return nil, err
+	}
+	chaosSpec, err := flags.GetString("chaos")
+	if err != nil {
+		return nil, err
+	}
+
+	return []bench.Option{
+		bench.WithBrokers(brokers),
+		bench.WithStrategy(bench.Strategy(strategy)),
+		bench.WithLocalAddrs(localAddrs),
+		bench.WithLocalPorts(localPorts),
+		bench.WithProxy(proxy),
+		bench.WithProxyProtocol(proxyProtocol),
+		bench.WithProxyProtocolSource(proxyProtocolSrc),
+		bench.WithChaos(chaosSpec),
+	}, nil
+}
+
+// parseLargeFlags reads the large message flags into a bench option and
+// reports whether the mode is enabled
+func parseLargeFlags(cmd *cobra.Command) (bench.Option, bool, error) {
+	enabled, err := cmd.Flags().GetBool("large")
+	if err != nil {
+		return nil, false, err
+	}
+	minRaw, err := cmd.Flags().GetString("large-min")
+	if err != nil {
+		return nil, false, err
+	}
+	minSize, err := parseByteSize(minRaw)
+	if err != nil {
+		return nil, false, fmt.Errorf("large-min: %w", err)
+	}
+	maxRaw, err := cmd.Flags().GetString("large-max")
+	if err != nil {
+		return nil, false, err
+	}
+	maxSize, err := parseByteSize(maxRaw)
+	if err != nil {
+		return nil, false, fmt.Errorf("large-max: %w", err)
+	}
+	factor, err := cmd.Flags().GetInt("large-factor")
+	if err != nil {
+		return nil, false, err
+	}
+	return bench.WithLargeMessages(enabled, minSize, maxSize, factor), enabled, nil
+}
+
+// parseByteSize parses a size like 1048576, 512K, 1MB or 2GiB, units are
+// binary multiples
+func parseByteSize(raw string) (int, error) {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	mult := 1
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", raw)
+	}
+	return n * mult, nil
+}
+
+// addSparkplugFlags registers the Sparkplug B flags of pub and sub
+func addSparkplugFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("sparkplug", false, "Publish and validate Sparkplug B edge node messages instead of plain payloads")
+	cmd.Flags().String("sp-group", bench.DefaultSparkplugGroup, "Sparkplug B group id")
+	cmd.Flags().Int("sp-devices", bench.DefaultSparkplugDevices, "Sparkplug B devices per edge node")
+	cmd.Flags().Int("sp-metrics", bench.DefaultSparkplugMetrics, "Sparkplug B metrics per message")
+}
+
+// parseSparkplugFlags reads the Sparkplug B flags into a bench option
+func parseSparkplugFlags(cmd *cobra.Command) (bench.Option, error) {
+	enabled, err := cmd.Flags().GetBool("sparkplug")
+	if err != nil {
+		return nil, err
+	}
+	group, err := cmd.Flags().GetString("sp-group")
+	if err != nil {
+		return nil, err
+	}
+	devices, err := cmd.Flags().GetInt("sp-devices")
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := cmd.Flags().GetInt("sp-metrics")
+	if err != nil {
+		return nil, err
+	}
+	return bench.WithSparkplug(enabled, group, devices, metrics), nil
+}
+
+// parsePayloadFlags reads the payload generator flags into a spec
+func parsePayloadFlags(cmd *cobra.Command) (payload.Spec, error) {
+	var spec payload.Spec
+	var err error
+
+	if spec.Size, err = cmd.Flags().GetInt("payload-size"); err != nil {
+		return spec, err
+	}
+	dist, err := cmd.Flags().GetString("payload-dist")
+	if err != nil {
+		return spec, err
+	}
+	spec.Dist = payload.Distribution(dist)
+	if spec.MinSize, err = cmd.Flags().GetInt("payload-min"); err != nil {
+		return spec, err
+	}
+	if spec.MaxSize, err = cmd.Flags().GetInt("payload-max"); err != nil {
+		return spec, err
+	}
+	if spec.StdDev, err = cmd.Flags().GetFloat64("payload-stddev"); err != nil {
+		return spec, err
+	}
+	if spec.Random, err = cmd.Flags().GetBool("payload-random"); err != nil {
+		return spec, err
+	}
+	if spec.File, err = cmd.Flags().GetString("payload-file"); err != nil {
+		return spec, err
+	}
+	if spec.Dir, err = cmd.Flags().GetString("payload-dir"); err != nil {
+		return spec, err
+	}
+	if spec.Template, err = cmd.Flags().GetString("payload-template"); err != nil {
+		return spec, err
+	}
+	if spec.Schema, err = cmd.Flags().GetString("payload-schema"); err != nil {
+		return spec, err
+	}
+	if spec.ProtoMsg, err = cmd.Flags().GetString("schema-message"); err != nil {
+		return spec, err
+	}
+	if spec.Seed, err = cmd.Flags().GetInt64("payload-seed"); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rayomqio/benchmq/internal/bench"
+	"github.com/rayomqio/benchmq/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+// propagationCmd represents the propagation command
+var propagationCmd = &cobra.Command{
+	Use:   "propagation",
+	Short: "Measure message propagation between the nodes of a broker cluster",
+	Long: `Publishes on one set of broker nodes and subscribes on another to measure
+cross-node propagation latency and message loss for every node pair.
+
+Parameters:
+    - pub-host: Broker node publishers connect to, host or host:port (repeatable)
+    - sub-host: Broker node subscribers connect to, host or host:port (repeatable)
+    - clients: Number of publishers per publisher node
+    - count: Number of messages to publish per client
+    - delay: Delay between messages in milliseconds
+    - qos: Quality of service level (0, 1, 2)
+    - topic: Topic prefix of the probe messages
+    - drain: Time to wait for in-flight messages after publishing ended
+
+Nodes default to the cluster section of the config file, then to host and port.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+		// Parse flags
+		host, err := cmd.Flags().GetString("host")
+		if err != nil {
+			logger.Error("failed to parse host flag", logger.ErrorAttr(err))
+			return
+		}
+
+		port, err := cmd.Flags().GetUint16("port")
+		if err != nil {
+			logger.Error("failed to parse port flag", logger.ErrorAttr(err))
+			return
+		}
+
+		clientID, err := cmd.Flags().GetString("clientID")
+		if err != nil {
+			logger.Error("failed to parse clientID flag", logger.ErrorAttr(err))
+			return
+		}
+
+		clients, err := cmd.Flags().GetInt("clients")
+		if err != nil {
+			logger.Error("failed to parse clients flag", logger.ErrorAttr(err))
+			return
+		}
+
+		count, err := cmd.Flags().GetInt("count")
+		if err != nil {
+			logger.Error("failed to parse count flag", logger.ErrorAttr(err))
+			return
+		}
+
+		delay, err := cmd.Flags().GetInt("delay")
+		if err != nil {
+			logger.Error("failed to parse delay flag", logger.ErrorAttr(err))
+			return
+		}
+
+		qos, err := cmd.Flags().GetUint16("qos")
+		if err != nil {
+			logger.Error("failed to parse qos flag", logger.ErrorAttr(err))
+			return
+		}
+
+		topic, err := cmd.Flags().GetString("topic")
+		if err != nil {
+			logger.Error("failed to parse topic flag", logger.ErrorAttr(err))
+			return
+		}
+
+		pubHosts, err := cmd.Flags().GetStringSlice("pub-host")
+		if err != nil {
+			logger.Error("failed to parse pub-host flag", logger.ErrorAttr(err))
+			return
+		}
+
+		subHosts, err := cmd.Flags().GetStringSlice("sub-host")
+		if err != nil {
+			logger.Error("failed to parse sub-host flag", logger.ErrorAttr(err))
+			return
+		}
+
+		drain, err := cmd.Flags().GetDuration("drain")
+		if err != nil {
+			logger.Error("failed to parse drain flag", logger.ErrorAttr(err))
+			return
+		}
+
+		clean, err := cmd.Flags().GetBool("clean")
+		if err != nil {
+			logger.Error("failed to parse clean flag", logger.ErrorAttr(err))
+			return
+		}
+
+		keepalive, err := cmd.Flags().GetUint16("keepalive")
+		if err != nil {
+			logger.Error("failed to parse keepalive flag", logger.ErrorAttr(err))
+			return
+		}
+
+		username, err := cmd.Flags().GetString("username")
+		if err != nil {
+			logger.Error("failed to parse username flag", logger.ErrorAttr(err))
+			return
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			logger.Error("failed to parse password flag", logger.ErrorAttr(err))
+			return
+		}
+
+		options, err := runOptions(cmd)
+		if err != nil {
+			logger.Error("failed to parse flags", logger.ErrorAttr(err))
+			return
+		}
+
+		// Create benchmark
+		b, err := bench.NewBenchmark(Cfg, append(options,
+			bench.WithClientID(clientID),
+			bench.WithClients(clients),
+			bench.WithMessageCount(count),
+			bench.WithDelay(delay),
+			bench.WithQoS(qos),
+			bench.WithTopic(topic),
+			bench.WithPublishNodes(pubHosts),
+			bench.WithSubscribeNodes(subHosts),
+			bench.WithDrain(drain),
+			bench.WithCleanSession(clean),
+			bench.WithKeepAlive(keepalive),
+			bench.WithUsername(username),
+			bench.WithPassword(password),
+			bench.WithHost(host),
+			bench.WithPort(port),
+		)...)
+		if err != nil {
+			logger.Error("failed to create benchmark", logger.ErrorAttr(err))
+			return
+		}
+
+		done := make(chan struct{})
+		go func() {
+			b.RunPropagation()
+			close(done)
+		}()
+
+		select {
+		case <-sigs:
+			logger.Info("received shutdown signal", logger.State("interrupted"))
+			return
+		case <-done:
+			logger.Info("propagation benchmark completed", logger.State("completed"))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(propagationCmd)
+
+	// Register flags
+	propagationCmd.Flags().IntP("clients", "c", 1, "Number of publishers per publisher node")
+	propagationCmd.Flags().IntP("count", "n", 100, "Number of messages to publish per client")
+	propagationCmd.Flags().IntP("delay", "d", 10, "Delay between messages in milliseconds")
+	propagationCmd.Flags().Uint16P("qos", "q", 1, "Quality of service level (0, 1, 2)")
+	propagationCmd.Flags().StringP("topic", "t", "bench/propagation", "Topic prefix of the probe messages")
+	propagationCmd.Flags().StringSlice("pub-host", nil, "Broker node publishers connect to, host or host:port (repeatable)")
+	propagationCmd.Flags().StringSlice("sub-host", nil, "Broker node subscribers connect to, host or host:port (repeatable)")
+	propagationCmd.Flags().Duration("drain", bench.DefaultDrain, "Time to wait for in-flight messages after publishing ended")
+}
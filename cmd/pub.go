@@ -6,6 +6,7 @@ import (
 	"syscall"
 
 	"github.com/rayomqio/benchmq/internal/bench"
+	"github.com/rayomqio/benchmq/internal/payload"
 	"github.com/rayomqio/benchmq/pkg/logger"
 	"github.com/spf13/cobra"
 )
@@ -25,6 +26,15 @@ Parameters:
     - count: Number of messages to publish per client
     - qos: Quality of service level (0, 1, 2)
     - message: The message payload
+    - async: Pipeline messages and collect acknowledgements asynchronously
+    - max-inflight: Unacknowledged messages per client in async mode
+    - payload-size: Generated payload size in bytes (mean for normal distribution)
+    - payload-dist: Payload size distribution (fixed, uniform, normal)
+    - payload-file / payload-dir: Payload loaded from a file or rotated from a directory
+    - payload-template: Payload template with {timestamp}, {seq}, {client_id}, {rand_float:0:100}, ...
+    - payload-schema: JSON Schema or .proto file random valid payloads are generated from (schema-message, payload-seed)
+    - sparkplug: Act as Sparkplug B edge nodes (sp-group, sp-devices, sp-metrics)
+    - large: Publish growing payloads up to the broker's limit (large-min, large-max, large-factor)
     - topic: Topic to publish to
     - retain: Whether to retain the last message
     - clean: Whether to use a clean session
@@ -119,8 +129,60 @@ Parameters:
 			return
 		}
 
-		b, err := bench.NewBenchmark(
-			Cfg,
+		async, err := cmd.Flags().GetBool("async")
+		if err != nil {
+			logger.Error("failed to parse async flag", logger.ErrorAttr(err))
+			return
+		}
+
+		maxInflight, err := cmd.Flags().GetInt("max-inflight")
+		if err != nil {
+			logger.Error("failed to parse max in-flight", logger.ErrorAttr(err))
+			return
+		}
+
+		spec, err := parsePayloadFlags(cmd)
+		if err != nil {
+			logger.Error("failed to parse payload flags", logger.ErrorAttr(err))
+			return
+		}
+		spec.Message = message
+
+		gen, err := payload.New(spec)
+		if err != nil {
+			logger.Error("failed to create payload generator", logger.ErrorAttr(err))
+			return
+		}
+
+		sparkplug, err := parseSparkplugFlags(cmd)
+		if err != nil {
+			logger.Error("failed to parse sparkplug flags", logger.ErrorAttr(err))
+			return
+		}
+
+		large, largeMode, err := parseLargeFlags(cmd)
+		if err != nil {
+			logger.Error("failed to parse large message flags", logger.ErrorAttr(err))
+			return
+		}
+		if largeMode && !cmd.Flags().Changed("count") {
+			// One message per size unless asked otherwise
+			count = 1
+		}
+
+		options, err := runOptions(cmd)
+		if err != nil {
+			logger.Error("failed to parse flags", logger.ErrorAttr(err))
+			return
+		}
+		dial, err := dialOptions(cmd)
+		if err != nil {
+			logger.Error("failed to parse flags", logger.ErrorAttr(err))
+			return
+		}
+		options = append(options, dial...)
+
+		b, err := bench.NewBenchmark(Cfg, append(options,
 			bench.WithClientID(clientID),
 			bench.WithClients(clients),
 			bench.WithTopic(topic),
@@ -131,11 +193,16 @@ Parameters:
 			bench.WithCleanSession(cleanSession),
 			bench.WithKeepAlive(keepalive),
 			bench.WithMessage(message),
+			bench.WithPayload(gen),
+			bench.WithAsync(async),
+			bench.WithMaxInflight(maxInflight),
 			bench.WithUsername(username),
 			bench.WithPassword(password),
 			bench.WithHost(host),
 			bench.WithPort(port),
-		)
+			sparkplug,
+			large,
+		)...)
 		if err != nil {
 			logger.Error("failed to create benchmark", logger.State("failed"), logger.ErrorAttr(err))
 			return
@@ -147,6 +214,10 @@ Parameters:
 			os.Exit(0)
 		}()
 
+		if largeMode {
+			b.LargeMessages()
+			return
+		}
 		b.PublishMessages()
 	},
 }
@@ -162,4 +233,23 @@ func init() {
 	pubCmd.Flags().Uint16P("qos", "q", 0, "Quality of service level (0, 1, 2)")
 	pubCmd.Flags().StringP("message", "m", "Hello, World!", "Message to publish")
 	pubCmd.Flags().StringP("topic", "t", "bench/test", "Topic to publish messages to")
+	pubCmd.Flags().Bool("async", false, "Publish asynchronously with a window of unacknowledged messages per client")
+	pubCmd.Flags().Int("max-inflight", bench.DefaultMaxInflight, "Maximum unacknowledged messages per client in async mode")
+	pubCmd.Flags().Int("payload-size", 0, "Generated payload size in bytes, mean for normal distribution (overrides message)")
+	pubCmd.Flags().String("payload-dist", "fixed", "Payload size distribution (fixed, uniform, normal)")
+	pubCmd.Flags().Int("payload-min", 0, "Minimum payload size in bytes for uniform and normal distributions")
+	pubCmd.Flags().Int("payload-max", 0, "Maximum payload size in bytes for uniform and normal distributions")
+	pubCmd.Flags().Float64("payload-stddev", 0, "Standard deviation of the payload size for normal distribution")
+	pubCmd.Flags().Bool("payload-random", false, "Fill generated payloads with random binary content")
+	pubCmd.Flags().String("payload-file", "", "File whose content is published as payload")
+	pubCmd.Flags().String("payload-dir", "", "Directory of payload files rotated per message")
+	pubCmd.Flags().String("payload-template", "", "Payload template with placeholders like {timestamp}, {seq}, {client_id}, {rand_float:0:100}")
+	pubCmd.Flags().String("payload-schema", "", "JSON Schema or .proto file random valid payloads are generated from")
+	pubCmd.Flags().String("schema-message", "", "Protobuf message of a .proto schema (default: first message)")
+	pubCmd.Flags().Int64("payload-seed", 0, "Seed of the schema payload generator, equal seeds publish equal payloads")
+	addSparkplugFlags(pubCmd)
+	pubCmd.Flags().Bool("large", false, "Publish payloads growing from large-min to large-max until the broker rejects one")
+	pubCmd.Flags().String("large-min", "1MiB", "Payload size of the first large message step (e.g. 512KiB, 1MB)")
+	pubCmd.Flags().String("large-max", "256MiB", "Payload size of the last large message step, capped to the MQTT limit")
+	pubCmd.Flags().Int("large-factor", bench.DefaultLargeFactor, "Growth of the payload size between large message steps")
 }
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/server"
+	"github.com/rayomqio/benchmq/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+// shutdownTimeout bounds how long serve waits for the active run to stop
+const shutdownTimeout = 30 * time.Second
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API to submit, steer and stop benchmarks",
+	Long: `Serves an HTTP API for test orchestrators to run benchmarks without
+spawning CLI processes. One benchmark runs at a time, later submissions wait
+in a queue.
+
+Endpoints:
+    - POST /runs: Submit a benchmark spec
+    - GET /runs: List all runs
+    - GET /runs/{id}: State and live status of a run
+    - PATCH /runs/{id}: Change delayMs, rate or clients of the active run
+    - POST /runs/{id}/stop: Stop the active run or cancel a queued one
+    - GET /runs/{id}/results: Final results and timeline of a finished run
+
+Parameters:
+    - listen: Address the API listens on
+    - queue: Number of runs that may wait behind the active one`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+
+		// Parse flags
+		listen, err := cmd.Flags().GetString("listen")
+		if err != nil {
+			logger.Error("failed to parse listen flag", logger.ErrorAttr(err))
+			return
+		}
+
+		queue, err := cmd.Flags().GetInt("queue")
+		if err != nil {
+			logger.Error("failed to parse queue flag", logger.ErrorAttr(err))
+			return
+		}
+
+		srv, err := server.New(Cfg, server.WithAddr(listen), server.WithQueueSize(queue))
+		if err != nil {
+			logger.Error("failed to create server", logger.ErrorAttr(err))
+			return
+		}
+
+		// Shutdown lets the active run stop and report before serve returns
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			<-sigs
+			logger.Info("received shutdown signal", logger.State("interrupted"))
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Error("failed to shut down server", logger.ErrorAttr(err))
+			}
+		}()
+
+		if err := srv.ListenAndServe(); err != nil {
+			logger.Error("failed to serve", logger.ErrorAttr(err))
+			return
+		}
+		<-stopped
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	// Register flags
+	serveCmd.Flags().String("listen", server.DefaultAddr, "Address the HTTP API listens on")
+	serveCmd.Flags().Int("queue", server.DefaultQueueSize, "Number of runs that may wait behind the active one")
+}
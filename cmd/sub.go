@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/rayomqio/benchmq/internal/bench"
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/schema"
 	"github.com/rayomqio/benchmq/pkg/logger"
 	"github.com/spf13/cobra"
 )
@@ -21,11 +25,16 @@ Parameters:
 	- clientID: Base client ID prefix (each client appends "-<n>")
     - clients: Number of concurrent subscribers
     - qos: Quality of service level (0, 1, 2)
-    - topic: Topic to subscribe to
+    - topic: Topic filter to subscribe to as filter[:qos], repeatable
+    - topic-file: File of topic filters, one filter[:qos] per line
+    - subscribe-per-filter: Send one SUBSCRIBE per filter instead of a single one
+    - validate-schema: Validate received payloads against a JSON Schema or .proto file
+    - sparkplug: Subscribe to a Sparkplug B group and validate its messages
     - clean: Whether to use a clean session
     - keepalive: Keepalive interval in seconds
-    - delay: Optional sleep between subscription lifetime checks
-    - count: Expected number of messages (used to determine how long to wait)`,
+    - count: Expected number of messages, subscribers finish once they received it
+    - idle-timeout: Finish a subscriber that received nothing for this long
+    - deadline: Finish every subscriber after this long (default: delay × count)`,
 	Run: func(cmd *cobra.Command, args []string) {
 		sigs := make(chan os.Signal, 1)
 		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -68,7 +77,19 @@ Parameters:
 			return
 		}
 
-		topic, err := cmd.Flags().GetString("topic")
+		idleTimeout, err := cmd.Flags().GetDuration("idle-timeout")
+		if err != nil {
+			logger.Error("failed to parse idle timeout", logger.ErrorAttr(err))
+			return
+		}
+
+		deadline, err := cmd.Flags().GetDuration("deadline")
+		if err != nil {
+			logger.Error("failed to parse deadline", logger.ErrorAttr(err))
+			return
+		}
+
+		topics, err := cmd.Flags().GetStringArray("topic")
 		if err != nil {
 			logger.Error("failed to parse topic", logger.ErrorAttr(err))
 			return
@@ -80,6 +101,29 @@ Parameters:
 			return
 		}
 
+		topicFile, err := cmd.Flags().GetString("topic-file")
+		if err != nil {
+			logger.Error("failed to parse topic file", logger.ErrorAttr(err))
+			return
+		}
+
+		perFilter, err := cmd.Flags().GetBool("subscribe-per-filter")
+		if err != nil {
+			logger.Error("failed to parse subscribe per filter flag", logger.ErrorAttr(err))
+			return
+		}
+
+		if topicFile != "" && !cmd.Flags().Changed("topic") {
+			// The file replaces the default topic
+			topics = nil
+		}
+		filters, err := parseFilters(topics, topicFile, byte(qos))
+		if err != nil {
+			logger.Error("failed to parse topic filters", logger.ErrorAttr(err))
+			return
+		}
+		topic := filters[0].Topic
+
 		cleanSession, err := cmd.Flags().GetBool("clean")
 		if err != nil {
 			logger.Error("failed to parse clean session flag", logger.ErrorAttr(err))
@@ -104,21 +148,65 @@ Parameters:
 			return
 		}
 
-		b, err := bench.NewBenchmark(
-			Cfg,
+		validateSchema, err := cmd.Flags().GetString("validate-schema")
+		if err != nil {
+			logger.Error("failed to parse validate-schema flag", logger.ErrorAttr(err))
+			return
+		}
+
+		schemaMessage, err := cmd.Flags().GetString("schema-message")
+		if err != nil {
+			logger.Error("failed to parse schema-message flag", logger.ErrorAttr(err))
+			return
+		}
+
+		var validation bench.Option
+		if validateSchema != "" {
+			s, err := schema.Load(validateSchema, schemaMessage)
+			if err != nil {
+				logger.Error("failed to load schema", logger.ErrorAttr(err))
+				return
+			}
+			validation = bench.WithSchemaValidation(s)
+		}
+
+		sparkplug, err := parseSparkplugFlags(cmd)
+		if err != nil {
+			logger.Error("failed to parse sparkplug flags", logger.ErrorAttr(err))
+			return
+		}
+
+		options, err := runOptions(cmd)
+		if err != nil {
+			logger.Error("failed to parse flags", logger.ErrorAttr(err))
+			return
+		}
+		dial, err := dialOptions(cmd)
+		if err != nil {
+			logger.Error("failed to parse flags", logger.ErrorAttr(err))
+			return
+		}
+		options = append(options, dial...)
+
+		b, err := bench.NewBenchmark(Cfg, append(options,
 			bench.WithClientID(clientID),
 			bench.WithClients(clients),
 			bench.WithTopic(topic),
+			bench.WithFilters(filters, perFilter),
 			bench.WithQoS(qos),
 			bench.WithMessageCount(count),
 			bench.WithDelay(delay),
+			bench.WithIdleTimeout(idleTimeout),
+			bench.WithDeadline(deadline),
 			bench.WithCleanSession(cleanSession),
 			bench.WithKeepAlive(keepalive),
 			bench.WithUsername(username),
 			bench.WithPassword(password),
 			bench.WithHost(host),
 			bench.WithPort(port),
-		)
+			sparkplug,
+			validation,
+		)...)
 		if err != nil {
 			logger.Error("failed to create benchmark", logger.State("failed"), logger.ErrorAttr(err))
 			return
@@ -139,8 +227,55 @@ func init() {
 
 	// Register flags
 	subCmd.Flags().IntP("clients", "c", 100, "Number of concurrent subscriber clients")
-	subCmd.Flags().IntP("delay", "d", 1000, "Delay between subscription lifetime checks (ms)")
-	subCmd.Flags().IntP("count", "n", 1000, "Expected number of messages per client")
+	subCmd.Flags().IntP("delay", "d", 1000, "Expected delay between messages (ms), the default deadline is delay × count")
+	subCmd.Flags().IntP("count", "n", 1000, "Expected number of messages per client, 0 waits for the idle timeout or deadline")
+	subCmd.Flags().Duration("idle-timeout", bench.DefaultIdleTimeout, "Finish a subscriber that received nothing for this long (0 disables)")
+	subCmd.Flags().Duration("deadline", 0, "Finish every subscriber after this long (default: delay × count, 0 with delay 0 waits without limit)")
 	subCmd.Flags().Uint16P("qos", "q", 0, "Quality of service level (0, 1, 2)")
-	subCmd.Flags().StringP("topic", "t", "bench/test", "Topic to subscribe to")
+	subCmd.Flags().StringArrayP("topic", "t", []string{"bench/test"}, "Topic filter to subscribe to as filter[:qos], repeatable")
+	subCmd.Flags().String("topic-file", "", "File of topic filters, one filter[:qos] per line")
+	subCmd.Flags().Bool("subscribe-per-filter", false, "Send one SUBSCRIBE per topic filter instead of a single one for all")
+	subCmd.Flags().String("validate-schema", "", "JSON Schema or .proto file received payloads are validated against")
+	subCmd.Flags().String("schema-message", "", "Protobuf message of a .proto schema (default: first message)")
+	addSparkplugFlags(subCmd)
+}
+
+// parseFilters reads topic filters from the topic flags and the lines of
+// file, a filter without a :qos suffix uses qos
+func parseFilters(topics []string, file string, qos byte) ([]mqtt.Filter, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				topics = append(topics, line)
+			}
+		}
+	}
+
+	var filters []mqtt.Filter
+	seen := make(map[string]bool)
+	for _, spec := range topics {
+		f := mqtt.Filter{Topic: spec, QoS: qos}
+		if i := strings.LastIndexByte(spec, ':'); i >= 0 {
+			switch spec[i+1:] {
+			case "0", "1", "2":
+				f.Topic, f.QoS = spec[:i], spec[i+1]-'0'
+			}
+		}
+		if f.Topic == "" {
+			return nil, fmt.Errorf("empty topic filter in %q", spec)
+		}
+		if seen[f.Topic] {
+			return nil, fmt.Errorf("topic filter %q given twice", f.Topic)
+		}
+		seen[f.Topic] = true
+		filters = append(filters, f)
+	}
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("no topic filter given")
+	}
+	return filters, nil
 }
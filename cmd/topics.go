@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rayomqio/benchmq/internal/bench"
+	"github.com/rayomqio/benchmq/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+// topicsCmd represents the topics command
+var topicsCmd = &cobra.Command{
+	Use:   "topics",
+	Short: "Measure how the broker scales with the size of its topic tree",
+	Long: `Builds a topic hierarchy and grows exact and wildcard subscriptions over it
+in steps. After every step publishers send probes to the leaf topics, and the
+SUBACK latency, publish throughput and match latency from publish to delivery
+are reported as a function of the subscription count.
+
+Parameters:
+    - depth: Levels of the topic tree below the topic
+    - fanout: Children per level
+    - leaves: Leaf topics in use (default: fanout^depth)
+    - subscriptions: Subscriptions after the last step
+    - steps: Steps the subscriptions grow in
+    - wildcards: Share of subscriptions with a + or # wildcard, from 0 to 1
+    - clients: Number of subscriber clients holding the subscriptions
+    - publishers: Number of publisher clients
+    - count: Number of probes to publish per publisher and step
+    - delay: Delay between probes in milliseconds
+    - qos: Quality of service level (0, 1, 2)
+    - topic: Root of the topic tree
+    - drain: Time to wait for deliveries after every publish phase`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+		// Parse flags
+		host, err := cmd.Flags().GetString("host")
+		if err != nil {
+			logger.Error("failed to parse host flag", logger.ErrorAttr(err))
+			return
+		}
+
+		port, err := cmd.Flags().GetUint16("port")
+		if err != nil {
+			logger.Error("failed to parse port flag", logger.ErrorAttr(err))
+			return
+		}
+
+		clientID, err := cmd.Flags().GetString("clientID")
+		if err != nil {
+			logger.Error("failed to parse clientID flag", logger.ErrorAttr(err))
+			return
+		}
+
+		clients, err := cmd.Flags().GetInt("clients")
+		if err != nil {
+			logger.Error("failed to parse clients flag", logger.ErrorAttr(err))
+			return
+		}
+
+		count, err := cmd.Flags().GetInt("count")
+		if err != nil {
+			logger.Error("failed to parse count flag", logger.ErrorAttr(err))
+			return
+		}
+
+		delay, err := cmd.Flags().GetInt("delay")
+		if err != nil {
+			logger.Error("failed to parse delay flag", logger.ErrorAttr(err))
+			return
+		}
+
+		qos, err := cmd.Flags().GetUint16("qos")
+		if err != nil {
+			logger.Error("failed to parse qos flag", logger.ErrorAttr(err))
+			return
+		}
+
+		topic, err := cmd.Flags().GetString("topic")
+		if err != nil {
+			logger.Error("failed to parse topic flag", logger.ErrorAttr(err))
+			return
+		}
+
+		var tree bench.TopicTree
+		if tree.Depth, err = cmd.Flags().GetInt("depth"); err != nil {
+			logger.Error("failed to parse depth flag", logger.ErrorAttr(err))
+			return
+		}
+		if tree.Fanout, err = cmd.Flags().GetInt("fanout"); err != nil {
+			logger.Error("failed to parse fanout flag", logger.ErrorAttr(err))
+			return
+		}
+		if tree.Leaves, err = cmd.Flags().GetInt("leaves"); err != nil {
+			logger.Error("failed to parse leaves flag", logger.ErrorAttr(err))
+			return
+		}
+		if tree.Subscriptions, err = cmd.Flags().GetInt("subscriptions"); err != nil {
+			logger.Error("failed to parse subscriptions flag", logger.ErrorAttr(err))
+			return
+		}
+		if tree.Steps, err = cmd.Flags().GetInt("steps"); err != nil {
+			logger.Error("failed to parse steps flag", logger.ErrorAttr(err))
+			return
+		}
+		if tree.Wildcards, err = cmd.Flags().GetFloat64("wildcards"); err != nil {
+			logger.Error("failed to parse wildcards flag", logger.ErrorAttr(err))
+			return
+		}
+		if tree.Publishers, err = cmd.Flags().GetInt("publishers"); err != nil {
+			logger.Error("failed to parse publishers flag", logger.ErrorAttr(err))
+			return
+		}
+
+		drain, err := cmd.Flags().GetDuration("drain")
+		if err != nil {
+			logger.Error("failed to parse drain flag", logger.ErrorAttr(err))
+			return
+		}
+
+		clean, err := cmd.Flags().GetBool("clean")
+		if err != nil {
+			logger.Error("failed to parse clean flag", logger.ErrorAttr(err))
+			return
+		}
+
+		keepalive, err := cmd.Flags().GetUint16("keepalive")
+		if err != nil {
+			logger.Error("failed to parse keepalive flag", logger.ErrorAttr(err))
+			return
+		}
+
+		username, err := cmd.Flags().GetString("username")
+		if err != nil {
+			logger.Error("failed to parse username flag", logger.ErrorAttr(err))
+			return
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			logger.Error("failed to parse password flag", logger.ErrorAttr(err))
+			return
+		}
+
+		options, err := runOptions(cmd)
+		if err != nil {
+			logger.Error("failed to parse flags", logger.ErrorAttr(err))
+			return
+		}
+
+		// Create benchmark
+		b, err := bench.NewBenchmark(Cfg, append(options,
+			bench.WithClientID(clientID),
+			bench.WithClients(clients),
+			bench.WithMessageCount(count),
+			bench.WithDelay(delay),
+			bench.WithQoS(qos),
+			bench.WithTopic(topic),
+			bench.WithTopicTree(tree),
+			bench.WithDrain(drain),
+			bench.WithCleanSession(clean),
+			bench.WithKeepAlive(keepalive),
+			bench.WithUsername(username),
+			bench.WithPassword(password),
+			bench.WithHost(host),
+			bench.WithPort(port),
+		)...)
+		if err != nil {
+			logger.Error("failed to create benchmark", logger.ErrorAttr(err))
+			return
+		}
+
+		done := make(chan struct{})
+		go func() {
+			b.RunTopicTree()
+			close(done)
+		}()
+
+		select {
+		case <-sigs:
+			logger.Info("received shutdown signal", logger.State("interrupted"))
+			return
+		case <-done:
+			logger.Info("topic tree benchmark completed", logger.State("completed"))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(topicsCmd)
+
+	// Register flags
+	topicsCmd.Flags().IntP("clients", "c", 10, "Number of subscriber clients holding the subscriptions")
+	topicsCmd.Flags().IntP("count", "n", 1000, "Number of probes to publish per publisher and step")
+	topicsCmd.Flags().IntP("delay", "d", 0, "Delay between probes in milliseconds")
+	topicsCmd.Flags().Uint16P("qos", "q", 0, "Quality of service level (0, 1, 2)")
+	topicsCmd.Flags().StringP("topic", "t", "bench/tree", "Root of the topic tree")
+	topicsCmd.Flags().Int("depth", bench.DefaultTreeDepth, "Levels of the topic tree below the topic")
+	topicsCmd.Flags().Int("fanout", bench.DefaultTreeFanout, "Children per level of the topic tree")
+	topicsCmd.Flags().Int("leaves", 0, "Leaf topics in use (default: fanout^depth)")
+	topicsCmd.Flags().Int("subscriptions", bench.DefaultTreeSubscriptions, "Subscriptions after the last step")
+	topicsCmd.Flags().Int("steps", bench.DefaultTreeSteps, "Steps the subscriptions grow in")
+	topicsCmd.Flags().Float64("wildcards", bench.DefaultTreeWildcards, "Share of subscriptions with a + or # wildcard, from 0 to 1")
+	topicsCmd.Flags().Int("publishers", bench.DefaultTreePublishers, "Number of publisher clients")
+	topicsCmd.Flags().Duration("drain", bench.DefaultDrain, "Time to wait for deliveries after every publish phase")
+}
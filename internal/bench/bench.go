@@ -1,8 +1,22 @@
 package bench
 
 import (
+	"net/url"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/rayomqio/benchmq/internal/chaos"
+	"github.com/rayomqio/benchmq/internal/metrics"
+	"github.com/rayomqio/benchmq/internal/monitor"
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/payload"
+	"github.com/rayomqio/benchmq/internal/report"
+	"github.com/rayomqio/benchmq/internal/schema"
+	"github.com/rayomqio/benchmq/internal/sparkplug"
+	"github.com/rayomqio/benchmq/internal/sysstats"
+	"github.com/rayomqio/benchmq/internal/timeline"
+	"github.com/rayomqio/benchmq/internal/tracing"
 	"github.com/rayomqio/benchmq/pkg/config"
 	"github.com/rayomqio/benchmq/pkg/er"
 	"github.com/rayomqio/benchmq/pkg/logger"
@@ -17,8 +31,73 @@ type Bench struct {
 	clientID     string
 	topic        string
 	message      string
+	payload      payload.Generator
 	messageCount int
 	retained     bool
+	async        bool
+	maxInflight  int
+	clientImpl   mqtt.Implementation
+	hold         time.Duration
+	filters      []mqtt.Filter // Topic filters of subscribers, the topic when empty
+	perFilter    bool          // Send a SUBSCRIBE per filter instead of one for all
+	idleTimeout  time.Duration // Subscribers end after receiving nothing for this long
+	subDeadline  time.Duration // Subscribers end at the latest after this long
+	drain        time.Duration
+	pubNodes     []string
+	subNodes     []string
+	pubTargets   []node
+	subTargets   []node
+	brokers      []string
+	strategy     Strategy
+	balancer     *balancer
+	localAddrs   []string
+	localPorts   string
+	sources      *sourcePool
+	proxy        string
+	proxyURL     *url.URL
+	chaosSpec    string
+	chaosOpts    []chaos.Option // Faults of the chaos proxies, nil without any
+	ppVersion    string
+	ppSource     string
+	proxySources *proxySources
+	chaos        []*chaos.Proxy
+	monitorEvery time.Duration
+	monitor      *monitor.Monitor // Resource usage of benchmq itself
+	sysStats     bool
+	sysFilter    string
+	collectors   []*collector // Broker statistics per endpoint
+	resources    []monitor.Sample
+	resourceSum  *monitor.Summary
+	reportPath   string
+	command      string
+	settings     []report.Setting
+	timeline     *timeline.Recorder // Per-interval metrics of the run
+	intervals    []timeline.Interval
+	seriesOut    *timeline.Writer
+	seriesPath   string
+	interval     time.Duration
+	seriesFormat timeline.Format
+	started      time.Time
+	otlpEndpoint string
+	traceFile    string
+	traceSample  float64
+	traceService string
+	traceCarry   bool            // Published payloads carry the trace context
+	tracer       *tracing.Tracer // Spans of connects, publishes and receives
+	control      *control        // Runtime adjustments and live status
+	sparkplug    bool
+	spGroup      string
+	spDevices    int
+	spMetrics    int
+	spSent       lifecycle     // Birth and death messages of the edge nodes
+	spSessions   sessions      // bdSeq of the next session of every edge node
+	schema       schema.Schema // Schema received payloads are validated against
+	large        bool
+	largeMin     int
+	largeMax     int
+	largeFactor  int
+	tree         TopicTree
+	churn        Churn
 	cleanSession *bool
 	qos          QoSLevel
 	keepAlive    uint16
@@ -26,9 +105,13 @@ type Bench struct {
 	port         uint16
 	username     string
 	password     string
-	wg           sync.WaitGroup // Wait Group
-	cfg          *config.Config // Config
-	logger       *logger.Logger // Logger
+	wg           sync.WaitGroup     // Wait Group
+	traffic      *mqtt.Traffic      // Wire-level traffic of all clients
+	payloadBytes atomic.Int64       // Payload bytes published or received
+	rtt          *metrics.Histogram // Publish acknowledgement round-trip times
+	handshake    *handshake         // Connection phase timings
+	cfg          *config.Config     // Config
+	logger       *logger.Logger     // Logger
 }
 
 type Option func(*Bench)
@@ -40,16 +123,21 @@ const (
 )
 
 const (
-	DefaultDelay        = 1000             // Default delay between connection (ms)
-	DefaultClients      = 100              // Default clients to connect
-	DefaultClientID     = "benchmq-client" // Default client id
-	DefaultTopic        = "bench/test"     // Default publish/subscribe topic
-	DefaultCleanSession = true             // Default clean session state
-	DefaultQoS          = QoS0             // Default QoS level
-	DefaultKeepAlive    = 60               // Default connection keep alive
-	DefaultMessageCount = 100              // Default message count
-	DefaultMessage      = "Hello, World!"  // Default message
-	DefaultRetained     = false            // Default retained message state
+	DefaultDelay        = 1000               // Default delay between connection (ms)
+	DefaultClients      = 100                // Default clients to connect
+	DefaultClientID     = "benchmq-client"   // Default client id
+	DefaultTopic        = "bench/test"       // Default publish/subscribe topic
+	DefaultCleanSession = true               // Default clean session state
+	DefaultQoS          = QoS0               // Default QoS level
+	DefaultKeepAlive    = 60                 // Default connection keep alive
+	DefaultMessageCount = 100                // Default message count
+	DefaultMessage      = "Hello, World!"    // Default message
+	DefaultRetained     = false              // Default retained message state
+	DefaultMaxInflight  = 16                 // Default in-flight window per client in async mode
+	DefaultClientImpl   = mqtt.ImplPaho      // Default MQTT client implementation
+	DefaultDrain        = 2 * time.Second    // Default wait for in-flight messages after publishing
+	DefaultStrategy     = StrategyRoundRobin // Default distribution of clients over brokers
+	DefaultProxySource  = "10.0.0.0/8"       // Default range of synthetic PROXY protocol client addresses
 )
 
 // NewBenchmark constructor initializes the bench struct
@@ -71,12 +159,35 @@ func NewBenchmark(cfg *config.Config, options ...Option) (*Bench, error) {
 		message:      DefaultMessage,
 		messageCount: DefaultMessageCount,
 		retained:     DefaultRetained,
+		maxInflight:  DefaultMaxInflight,
+		clientImpl:   DefaultClientImpl,
+		drain:        DefaultDrain,
+		idleTimeout:  DefaultIdleTimeout,
+		pubNodes:     cfg.Cluster.Publishers,
+		subNodes:     cfg.Cluster.Subscribers,
+		brokers:      cfg.Server.Brokers,
+		strategy:     Strategy(cfg.Server.Strategy),
+		proxy:        cfg.Client.Proxy,
+		ppSource:     DefaultProxySource,
+		monitorEvery: monitor.DefaultInterval,
+		sysFilter:    sysstats.DefaultFilter,
+		interval:     timeline.DefaultInterval,
+		traceSample:  tracing.DefaultSampleRatio,
+		spGroup:      DefaultSparkplugGroup,
+		spDevices:    DefaultSparkplugDevices,
+		spMetrics:    DefaultSparkplugMetrics,
+		largeMin:     DefaultLargeMin,
+		largeMax:     DefaultLargeMax,
+		largeFactor:  DefaultLargeFactor,
 		cleanSession: &cfg.Client.CleanSession,
 		qos:          DefaultQoS,
 		keepAlive:    cfg.Client.KeepAlive,
 		host:         cfg.Server.Host,
 		port:         cfg.Server.Port,
 		cfg:          cfg,
+		traffic:      &mqtt.Traffic{},
+		rtt:          metrics.NewHistogram(),
+		handshake:    newHandshake(),
 		logger:       logger.NewBenchmarkLogger("bench"),
 	}
 
@@ -89,6 +200,7 @@ func NewBenchmark(cfg *config.Config, options ...Option) (*Bench, error) {
 	if err := bench.validate(); err != nil {
 		return nil, err
 	}
+	bench.control = newControl(time.Duration(bench.delay) * time.Millisecond)
 
 	return &bench, nil
 }
@@ -143,6 +255,144 @@ func (b *Bench) validate() error {
 			Raw:     er.ErrInvalidQoS,
 		}
 	}
+	if b.async && b.maxInflight <= 0 {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidMaxInflight,
+			Raw:     er.ErrInvalidMaxInflight,
+		}
+	}
+	if b.clientImpl != mqtt.ImplPaho && b.clientImpl != mqtt.ImplNative {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrUnknownClientImpl,
+			Raw:     er.ErrUnknownClientImpl,
+		}
+	}
+	if b.clientImpl == mqtt.ImplNative && b.qos > QoS1 {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrUnsupportedQoS,
+			Raw:     er.ErrUnsupportedQoS,
+		}
+	}
+	if b.hold < 0 {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidHold,
+			Raw:     er.ErrInvalidHold,
+		}
+	}
+	if b.idleTimeout < 0 || b.subDeadline < 0 {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidCompletion,
+			Raw:     er.ErrInvalidCompletion,
+		}
+	}
+	if b.monitorEvery < 0 {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidMonitor,
+			Raw:     er.ErrInvalidMonitor,
+		}
+	}
+	if b.interval <= 0 {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidTimeline,
+			Raw:     er.ErrInvalidTimeline,
+		}
+	}
+	if b.drain < 0 {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidDrain,
+			Raw:     er.ErrInvalidDrain,
+		}
+	}
+	if b.sparkplug && (!sparkplug.ValidID(b.spGroup) || b.spDevices < 0 || b.spMetrics < 0) {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidSparkplug,
+			Raw:     er.ErrInvalidSparkplug,
+		}
+	}
+	if b.tree != (TopicTree{}) && (b.tree.Depth <= 0 || b.tree.Fanout <= 0 || b.tree.Leaves < 0 ||
+		b.tree.Subscriptions < 0 || b.tree.Steps <= 0 || b.tree.Publishers <= 0 ||
+		b.tree.Wildcards < 0 || b.tree.Wildcards > 1) {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidTopicTree,
+			Raw:     er.ErrInvalidTopicTree,
+		}
+	}
+	if b.churn != (Churn{}) && (b.churn.Filters <= 0 || b.churn.Rate <= 0 || b.churn.Dwell < 0 ||
+		b.churn.Publishers < 0 || (b.churn.Publishers > 0 && b.churn.PublishRate <= 0)) {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidChurn,
+			Raw:     er.ErrInvalidChurn,
+		}
+	}
+	if b.large && (b.largeMin <= 0 || b.largeMax < b.largeMin || b.largeFactor < 2) {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidLargeMessage,
+			Raw:     er.ErrInvalidLargeMessage,
+		}
+	}
+	// Publishers and subscribers default to the single configured server
+	server := node{host: b.host, port: b.port}
+	var err error
+	if b.pubTargets, err = parseNodes(b.pubNodes, b.port, server); err != nil {
+		return err
+	}
+	if b.subTargets, err = parseNodes(b.subNodes, b.port, server); err != nil {
+		return err
+	}
+	if err := b.setupEndpoints(); err != nil {
+		return err
+	}
+	if err := b.setupChaos(); err != nil {
+		return err
+	}
+	if b.sources, err = newSourcePool(b.localAddrs, b.localPorts); err != nil {
+		return err
+	}
+	if b.proxySources, err = newProxySources(b.ppVersion, b.ppSource); err != nil {
+		return err
+	}
+	if b.proxy != "" {
+		if b.proxyURL, err = mqtt.ParseProxy(b.proxy); err != nil {
+			return err
+		}
+	}
+	if b.seriesPath != "" {
+		if b.seriesFormat, err = timeline.ParseFormat(string(b.seriesFormat), b.seriesPath); err != nil {
+			return err
+		}
+	}
+	if b.traceSample < 0 || b.traceSample > 1 {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "Validate",
+			Message: er.ErrInvalidTracing,
+			Raw:     er.ErrInvalidTracing,
+		}
+	}
 	// Set default clientID
 	if b.clientID == "" {
 		b.clientID = DefaultClientID
@@ -154,9 +404,108 @@ func (b *Bench) validate() error {
 		cs := true
 		b.cleanSession = &cs
 	}
+	// Fall back to the static message when no generator is configured
+	if b.payload == nil {
+		b.payload = payload.NewStatic([]byte(b.message))
+	}
 	return nil
 }
 
+// assignment is the broker endpoint and local source a client was given
+type assignment struct {
+	endpoint *endpoint
+	source   *source
+}
+
+// connected counts a successful connection
+func (a *assignment) connected() {
+	a.endpoint.connected.Add(1)
+	if a.source != nil {
+		a.source.connected.Add(1)
+	}
+}
+
+// failed counts a failed connection
+func (a *assignment) failed() {
+	a.endpoint.failed.Add(1)
+	if a.source != nil {
+		a.source.failed.Add(1)
+	}
+}
+
+// newClient creates an MQTT client for clientID connecting to the endpoint
+// picked by the distribution strategy, from the next local source if any
+func (b *Bench) newClient(clientID string, extra ...mqtt.Option) (mqtt.Client, *assignment, error) {
+	a := &assignment{endpoint: b.balancer.pick(clientID)}
+	options := append([]mqtt.Option{mqtt.WithBroker(a.endpoint.url)}, extra...)
+	if a.endpoint.via != "" {
+		options = append(options, mqtt.WithDialAddr(a.endpoint.via))
+	}
+
+	if b.sources != nil {
+		src, addr, err := b.sources.pick()
+		a.source = src
+		if err != nil {
+			return nil, a, err
+		}
+		options = append(options, mqtt.WithLocalAddr(addr))
+	}
+	if b.proxySources != nil {
+		options = append(options, mqtt.WithProxyProtocol(b.proxySources.version, b.proxySources.pick()))
+	}
+
+	client, err := b.newClientAt(clientID, node{host: b.host, port: b.port}, options...)
+	return client, a, err
+}
+
+// setupEndpoints parses the broker URLs, without any the configured host and
+// port form the only endpoint
+func (b *Bench) setupEndpoints() error {
+	if b.strategy == "" {
+		b.strategy = DefaultStrategy
+	}
+
+	brokers := b.brokers
+	if len(brokers) == 0 {
+		brokers = []string{"tcp://" + node{host: b.host, port: b.port}.String()}
+	}
+
+	endpoints := make([]*endpoint, 0, len(brokers))
+	for _, raw := range brokers {
+		ep, err := parseEndpoint(raw)
+		if err != nil {
+			return err
+		}
+		endpoints = append(endpoints, ep)
+	}
+
+	var err error
+	b.balancer, err = newBalancer(b.strategy, endpoints)
+	return err
+}
+
+// newClientAt creates an MQTT client for clientID connecting to target
+func (b *Bench) newClientAt(clientID string, target node, options ...mqtt.Option) (mqtt.Client, error) {
+	cfg := b.clientConfig(clientID, target)
+	if b.proxyURL != nil {
+		options = append(options, mqtt.WithProxy(b.proxyURL))
+	}
+	return mqtt.New(b.clientImpl, &cfg, append(options, mqtt.WithTraffic(b.traffic), mqtt.WithHandshake(b.handshake.record))...)
+}
+
+// clientConfig returns the config of a client for clientID connecting to target
+func (b *Bench) clientConfig(clientID string, target node) config.Config {
+	cfg := *b.cfg
+	cfg.Server.Host = target.host
+	cfg.Server.Port = target.port
+	cfg.Client.ClientID = clientID
+	cfg.Client.CleanSession = *b.cleanSession
+	cfg.Client.KeepAlive = b.keepAlive
+	cfg.Client.Username = b.username
+	cfg.Client.Password = b.password
+	return cfg
+}
+
 func WithDelay(delay int) Option {
 	return func(b *Bench) {
 		b.delay = delay
@@ -229,6 +578,14 @@ func WithMessage(message string) Option {
 	}
 }
 
+// WithPayload sets the generator producing every published payload, it takes
+// precedence over WithMessage
+func WithPayload(generator payload.Generator) Option {
+	return func(b *Bench) {
+		b.payload = generator
+	}
+}
+
 func WithMessageCount(count int) Option {
 	return func(b *Bench) {
 		b.messageCount = count
@@ -241,6 +598,152 @@ func WithRetained(retained bool) Option {
 	}
 }
 
+// WithAsync enables pipelined publishing where acknowledgements are collected
+// asynchronously instead of waiting for each message
+func WithAsync(async bool) Option {
+	return func(b *Bench) {
+		b.async = async
+	}
+}
+
+// WithMaxInflight sets the number of unacknowledged messages per client in
+// async mode
+func WithMaxInflight(maxInflight int) Option {
+	return func(b *Bench) {
+		b.maxInflight = maxInflight
+	}
+}
+
+// WithClientImpl selects the MQTT client implementation
+func WithClientImpl(impl mqtt.Implementation) Option {
+	return func(b *Bench) {
+		b.clientImpl = impl
+	}
+}
+
+// WithHold keeps every connection of the connection benchmark open until all
+// clients connected plus the hold duration
+func WithHold(hold time.Duration) Option {
+	return func(b *Bench) {
+		b.hold = hold
+	}
+}
+
+// WithFilters subscribes every subscriber to filters instead of the topic,
+// with one SUBSCRIBE packet per filter when perFilter is set
+func WithFilters(filters []mqtt.Filter, perFilter bool) Option {
+	return func(b *Bench) {
+		b.filters = filters
+		b.perFilter = perFilter
+	}
+}
+
+// WithIdleTimeout ends a subscriber once it received nothing for idle, zero
+// disables the timeout
+func WithIdleTimeout(idle time.Duration) Option {
+	return func(b *Bench) {
+		b.idleTimeout = idle
+	}
+}
+
+// WithDeadline ends every subscriber after deadline, zero keeps the delay
+// times the message count
+func WithDeadline(deadline time.Duration) Option {
+	return func(b *Bench) {
+		b.subDeadline = deadline
+	}
+}
+
+// WithBrokers sets the broker URLs clients are distributed over
+func WithBrokers(brokers []string) Option {
+	return func(b *Bench) {
+		if len(brokers) > 0 {
+			b.brokers = brokers
+		}
+	}
+}
+
+// WithStrategy sets how clients are assigned to the brokers
+func WithStrategy(strategy Strategy) Option {
+	return func(b *Bench) {
+		if strategy != "" {
+			b.strategy = strategy
+		}
+	}
+}
+
+// WithLocalAddrs sets the local IPs or CIDRs clients dial from
+func WithLocalAddrs(addrs []string) Option {
+	return func(b *Bench) {
+		b.localAddrs = addrs
+	}
+}
+
+// WithLocalPorts sets the local port range clients dial from, like 20000-30000
+func WithLocalPorts(ports string) Option {
+	return func(b *Bench) {
+		b.localPorts = ports
+	}
+}
+
+// WithProxy sets the SOCKS5 or HTTP CONNECT proxy clients connect through
+func WithProxy(proxy string) Option {
+	return func(b *Bench) {
+		if proxy != "" {
+			b.proxy = proxy
+		}
+	}
+}
+
+// WithProxyProtocol sends PROXY protocol headers of version v1 or v2
+func WithProxyProtocol(version string) Option {
+	return func(b *Bench) {
+		b.ppVersion = version
+	}
+}
+
+// WithProxyProtocolSource sets the CIDR synthetic client addresses are taken from
+func WithProxyProtocolSource(cidr string) Option {
+	return func(b *Bench) {
+		if cidr != "" {
+			b.ppSource = cidr
+		}
+	}
+}
+
+// WithChaos runs an in-process chaos proxy in front of every broker, spec is
+// a fault list as accepted by chaos.ParseSpec
+func WithChaos(spec string) Option {
+	return func(b *Bench) {
+		b.chaosSpec = spec
+	}
+}
+
+// WithDrain sets how long subscribers keep receiving after publishing ended
+func WithDrain(drain time.Duration) Option {
+	return func(b *Bench) {
+		b.drain = drain
+	}
+}
+
+// WithPublishNodes sets the broker nodes publishers connect to
+func WithPublishNodes(nodes []string) Option {
+	return func(b *Bench) {
+		if len(nodes) > 0 {
+			b.pubNodes = nodes
+		}
+	}
+}
+
+// WithSubscribeNodes sets the broker nodes subscribers connect to
+func WithSubscribeNodes(nodes []string) Option {
+	return func(b *Bench) {
+		if len(nodes) > 0 {
+			b.subNodes = nodes
+		}
+	}
+}
+
 func WithUsername(username string) Option {
 	return func(b *Bench) {
 		b.username = username
@@ -252,3 +755,116 @@ func WithPassword(password string) Option {
 		b.password = password
 	}
 }
+
+// WithMonitorInterval sets how often the resources of benchmq are sampled,
+// 0 disables the sampling
+func WithMonitorInterval(interval time.Duration) Option {
+	return func(b *Bench) {
+		b.monitorEvery = interval
+	}
+}
+
+// WithSysStats records the broker statistics published on filter during the
+// run, an empty filter keeps the default $SYS/#
+func WithSysStats(enabled bool, filter string) Option {
+	return func(b *Bench) {
+		b.sysStats = enabled
+		if filter != "" {
+			b.sysFilter = filter
+		}
+	}
+}
+
+// WithReport writes an HTML report to path when the run ends, command and
+// settings describe the run configuration
+func WithReport(path, command string, settings []report.Setting) Option {
+	return func(b *Bench) {
+		b.reportPath = path
+		b.command = command
+		b.settings = settings
+	}
+}
+
+// WithTimeline sets the interval of the per-interval metrics and streams them
+// to path as JSON lines or CSV, the format defaults to the file extension
+func WithTimeline(interval time.Duration, path, format string) Option {
+	return func(b *Bench) {
+		b.interval = interval
+		b.seriesPath = path
+		b.seriesFormat = timeline.Format(format)
+	}
+}
+
+// WithTracing exports OpenTelemetry spans to an OTLP/HTTP endpoint and/or a
+// file, sample is the share of messages traced
+func WithTracing(endpoint, file string, sample float64, service string) Option {
+	return func(b *Bench) {
+		b.otlpEndpoint = endpoint
+		b.traceFile = file
+		b.traceSample = sample
+		b.traceService = service
+	}
+}
+
+// WithTraceContext carries the trace context of published messages in a
+// payload envelope, so traced subscribers of another run join their traces
+func WithTraceContext(enabled bool) Option {
+	return func(b *Bench) {
+		b.traceCarry = enabled
+	}
+}
+
+// WithSparkplug makes publishers act as Sparkplug B edge nodes of group with
+// devices devices and metrics metrics per message, subscribers validate the
+// messages of the group. An empty group keeps the default.
+func WithSparkplug(enabled bool, group string, devices, metrics int) Option {
+	return func(b *Bench) {
+		b.sparkplug = enabled
+		if group != "" {
+			b.spGroup = group
+		}
+		b.spDevices = devices
+		b.spMetrics = metrics
+	}
+}
+
+// WithSchemaValidation validates every received payload against s
+func WithSchemaValidation(s schema.Schema) Option {
+	return func(b *Bench) {
+		b.schema = s
+	}
+}
+
+// WithLargeMessages publishes payloads growing by factor from minSize to
+// maxSize bytes instead of the configured payload, zero values keep the
+// defaults
+func WithLargeMessages(enabled bool, minSize, maxSize, factor int) Option {
+	return func(b *Bench) {
+		b.large = enabled
+		if minSize != 0 {
+			b.largeMin = minSize
+		}
+		if maxSize != 0 {
+			b.largeMax = maxSize
+		}
+		if factor != 0 {
+			b.largeFactor = factor
+		}
+	}
+}
+
+// WithTopicTree sets the topic hierarchy and subscriptions of the topic tree
+// benchmark
+func WithTopicTree(tree TopicTree) Option {
+	return func(b *Bench) {
+		b.tree = tree
+	}
+}
+
+// WithChurn sets the filters, rates and publishers of the subscription churn
+// benchmark
+func WithChurn(churn Churn) Option {
+	return func(b *Bench) {
+		b.churn = churn
+	}
+}
@@ -0,0 +1,269 @@
+package bench
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/metrics"
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// Subscription churn defaults
+const (
+	DefaultChurnFilters     = 5
+	DefaultChurnRate        = 1.0
+	DefaultChurnPublishers  = 1
+	DefaultChurnPublishRate = 1000.0
+)
+
+// Churn describes the subscription churn benchmark: every client cycles
+// through its own filters, subscribing to one, holding it for the dwell time
+// and unsubscribing again, while publishers keep sending to all filters
+type Churn struct {
+	Filters     int           // Filters per client the cycles rotate over
+	Rate        float64       // Subscribe/unsubscribe cycles per second per client
+	Dwell       time.Duration // Time between SUBACK and UNSUBSCRIBE, half the cycle when zero
+	Publishers  int           // Clients publishing to the filters of every client
+	PublishRate float64       // Messages per second per publisher
+}
+
+// dwell returns how long a subscription is held in every cycle
+func (c Churn) dwell() time.Duration {
+	if c.Dwell > 0 {
+		return c.Dwell
+	}
+	return c.period() / 2
+}
+
+// period returns the length of one subscribe/unsubscribe cycle
+func (c Churn) period() time.Duration {
+	return time.Duration(float64(time.Second) / c.Rate)
+}
+
+// churnTopic returns filter k of client i, an exact topic
+func (b *Bench) churnTopic(i, k int) string {
+	return fmt.Sprintf("%s/%d/%d", b.topic, i, k)
+}
+
+// churnState tracks which filters a client holds to tell deliveries from
+// messages the broker sent after acknowledging an UNSUBSCRIBE
+type churnState struct {
+	mu        sync.Mutex
+	active    map[string]bool
+	delivered int64
+	late      int64
+}
+
+// receive counts a message of topic and reports whether it arrived late
+func (s *churnState) receive(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[topic] {
+		s.delivered++
+		return false
+	}
+	s.late++
+	return true
+}
+
+func (s *churnState) set(topic string, active bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[topic] = active
+}
+
+// unsubscribeAcked unsubscribes client from topic and calls acked when the
+// UNSUBACK is handled, in order with the message callbacks where the client
+// supports it and once Unsubscribe returned otherwise
+func unsubscribeAcked(client mqtt.Client, topic string, acked func()) error {
+	if u, ok := client.(mqtt.AckedUnsubscriber); ok {
+		return u.UnsubscribeAcked(topic, acked)
+	}
+	if err := client.Unsubscribe(topic); err != nil {
+		return err
+	}
+	acked()
+	return nil
+}
+
+// RunChurn makes every client repeatedly subscribe and unsubscribe to
+// changing filters at the configured rate while publishers send to all of
+// them. It measures SUBACK and UNSUBACK latency and counts messages that
+// arrived for a filter after its UNSUBACK.
+func (b *Bench) RunChurn() {
+	if !b.begin() {
+		return
+	}
+	start := time.Now()
+	c := b.churn
+	b.logger.Info("started churn benchmark",
+		logger.String("start", start.Format(time.RFC3339Nano)),
+		logger.Int("clients", b.clients),
+		logger.Int("cycles", b.messageCount),
+		logger.Int("filters", c.Filters),
+		logger.Float("rate", c.Rate),
+		logger.Float("dwellMs", ms(c.dwell())),
+	)
+	if b.clientImpl == mqtt.ImplPaho {
+		b.logger.Warn("paho delivers messages concurrently, messages sent before an UNSUBACK may be counted as late, use the native client")
+	}
+
+	suback := metrics.NewHistogram()
+	unsuback := metrics.NewHistogram()
+	var cycles, subFailed, unsubFailed, failed atomic.Int64
+	var delivered, late, lateClients atomic.Int64
+
+	// Publishers run until every churning client finished
+	done := make(chan struct{})
+	var published atomic.Int64
+	var pubs sync.WaitGroup
+	publishers := b.connectClients("pub", c.Publishers)
+	for p, client := range publishers {
+		pubs.Add(1)
+		go func() {
+			defer pubs.Done()
+			b.feedChurn(client, p, len(publishers), done, &published)
+		}()
+	}
+
+	for i := 0; i < b.clients; i++ {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+
+			id := fmt.Sprintf("%s-%d", b.clientID, i)
+			state := &churnState{active: make(map[string]bool)}
+			receive := func(topic string, payload []byte) {
+				if state.receive(topic) {
+					b.timeline.Late()
+					b.logger.Debug("message after unsubscribe", logger.ClientID(id), logger.String("topic", topic))
+					return
+				}
+				b.timeline.Received(0)
+				b.payloadBytes.Add(int64(len(payload)))
+			}
+
+			client, a, err := b.newClient(id, mqtt.WithUnrouted(receive))
+			if err != nil {
+				failed.Add(1)
+				a.failed()
+				b.timeline.Error("client")
+				b.logger.Error("failed to create client", logger.ClientID(id), logger.ErrorAttr(err))
+				return
+			}
+			if err := b.connect(client, id, a.endpoint.url.Host); err != nil {
+				failed.Add(1)
+				a.failed()
+				b.timeline.Error("connect")
+				b.logger.Error("couldn't establish client", logger.ClientID(id), logger.ErrorAttr(err))
+				return
+			}
+			a.connected()
+			b.timeline.Connected()
+			defer func() {
+				client.Disconnect()
+				b.timeline.Disconnected()
+			}()
+			b.logger.LogClientConnection(id)
+
+			for n := 0; n < b.messageCount && !b.Stopped(); n++ {
+				begin := time.Now()
+				topic := b.churnTopic(i, n%c.Filters)
+
+				// Messages may arrive before the SUBACK
+				state.set(topic, true)
+				if err := client.SubscribeMessages(topic, byte(b.qos), receive); err != nil {
+					state.set(topic, false)
+					subFailed.Add(1)
+					b.timeline.Error("subscribe")
+					b.logger.Debug("failed to subscribe", logger.ClientID(id), logger.ErrorAttr(err))
+					b.sleep(c.period() - time.Since(begin))
+					continue
+				}
+				suback.Record(time.Since(begin))
+
+				b.sleep(c.dwell())
+
+				sent := time.Now()
+				if err := unsubscribeAcked(client, topic, func() { state.set(topic, false) }); err != nil {
+					// Still subscribed as far as we know
+					unsubFailed.Add(1)
+					b.timeline.Error("unsubscribe")
+					b.logger.Debug("failed to unsubscribe", logger.ClientID(id), logger.ErrorAttr(err))
+				} else {
+					unsuback.Record(time.Since(sent))
+					cycles.Add(1)
+				}
+				b.sleep(c.period() - time.Since(begin))
+			}
+
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			delivered.Add(state.delivered)
+			late.Add(state.late)
+			if state.late > 0 {
+				lateClients.Add(1)
+				b.logger.Warn("messages arrived after unsubscribe", logger.ClientID(id), logger.Any("late", state.late))
+			}
+		}()
+	}
+
+	b.wg.Wait()
+	close(done)
+	pubs.Wait()
+	for _, client := range publishers {
+		client.Disconnect()
+		b.timeline.Disconnected()
+	}
+
+	elapsed := time.Since(start).Seconds()
+	attrs := []slog.Attr{
+		logger.Int("clients", b.clients),
+		logger.Any("failedClients", failed.Load()),
+		logger.Int("publishers", len(publishers)),
+		logger.Any("cycles", cycles.Load()),
+		logger.Any("subscribeFailed", subFailed.Load()),
+		logger.Any("unsubscribeFailed", unsubFailed.Load()),
+		logger.Float("cyclesPerSec", float64(cycles.Load())/elapsed),
+		logger.Any("published", published.Load()),
+		logger.Any("delivered", delivered.Load()),
+		logger.Any("lateMessages", late.Load()),
+		logger.Any("lateClients", lateClients.Load()),
+		logger.Float("elapsedSec", elapsed),
+	}
+	attrs = append(attrs, latencyAttrs("suback", suback)...)
+	attrs = append(attrs, latencyAttrs("unsuback", unsuback)...)
+	attrs = append(attrs, b.trafficAttrs(elapsed)...)
+	b.end("Churn benchmark", attrs)
+}
+
+// feedChurn publishes round-robin to the filters of every client until done,
+// publisher p of n takes every n-th filter
+func (b *Bench) feedChurn(client mqtt.Client, p, n int, done <-chan struct{}, published *atomic.Int64) {
+	interval := time.Duration(float64(time.Second) / b.churn.PublishRate)
+	total := b.clients * b.churn.Filters
+	payload := []byte("churn")
+	for k := p; ; k += n {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		topic := b.churnTopic(k%total/b.churn.Filters, k%b.churn.Filters)
+		sent := time.Now()
+		if err := client.Publish(topic, byte(b.qos), false, payload, func() {}); err != nil {
+			b.timeline.Error("publish")
+			b.logger.Debug("failed to publish message", logger.String("topic", topic), logger.ErrorAttr(err))
+		} else {
+			published.Add(1)
+			b.timeline.Sent(time.Since(sent))
+		}
+		if !b.sleep(interval - time.Since(sent)) {
+			return
+		}
+	}
+}
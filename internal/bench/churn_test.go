@@ -0,0 +1,54 @@
+package bench
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/mqtt/mqtttest"
+	"github.com/rayomqio/benchmq/pkg/config"
+)
+
+func TestChurnLateAfterUnsuback(t *testing.T) {
+	broker := mqtttest.NewBroker(t)
+	broker.AfterUnsuback = func(c *mqtttest.Conn, filters []string) {
+		_ = c.Publish(filters[0], []byte("churn"))
+	}
+	uri, err := url.Parse(broker.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const topic = "churn/0/0"
+	state := &churnState{active: make(map[string]bool)}
+	received := make(chan bool, 1)
+	receive := func(topic string, _ []byte) { received <- state.receive(topic) }
+
+	cfg := &config.Config{Client: config.Client{ClientID: "churn-0", CleanSession: true}}
+	client, err := mqtt.New(mqtt.ImplNative, cfg, mqtt.WithBroker(uri), mqtt.WithUnrouted(receive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	state.set(topic, true)
+	if err := client.SubscribeMessages(topic, 0, receive); err != nil {
+		t.Fatal(err)
+	}
+	if err := unsubscribeAcked(client, topic, func() { state.set(topic, false) }); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case late := <-received:
+		if !late {
+			t.Error("message published after the UNSUBACK counted as delivered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message")
+	}
+}
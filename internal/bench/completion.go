@@ -0,0 +1,119 @@
+package bench
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// DefaultIdleTimeout ends a subscriber that received nothing for this long
+const DefaultIdleTimeout = 30 * time.Second
+
+// endReason is the condition that ended a subscriber
+type endReason string
+
+const (
+	endCount    endReason = "count"    // Received the expected messages
+	endIdle     endReason = "idle"     // No message within the idle timeout
+	endDeadline endReason = "deadline" // Reached the deadline
+	endStopped  endReason = "stopped"  // The run was stopped
+)
+
+// completion tracks the messages of one subscriber and signals when it
+// received the expected count
+type completion struct {
+	expected int64
+	received atomic.Int64
+	last     atomic.Int64 // Unix nanoseconds of the last message
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newCompletion(expected int) *completion {
+	c := &completion{expected: int64(expected), done: make(chan struct{})}
+	c.last.Store(time.Now().UnixNano())
+	return c
+}
+
+// receive counts a message
+func (c *completion) receive() {
+	c.last.Store(time.Now().UnixNano())
+	if n := c.received.Add(1); c.expected > 0 && n >= c.expected {
+		c.once.Do(func() { close(c.done) })
+	}
+}
+
+// deadline returns how long subscribers live at most, the delay times the
+// message count when not set, zero for no deadline
+func (b *Bench) deadline() time.Duration {
+	if b.subDeadline > 0 {
+		return b.subDeadline
+	}
+	return time.Duration(b.delay) * time.Millisecond * time.Duration(b.messageCount)
+}
+
+// await blocks until the subscriber received its expected messages, was idle
+// for the idle timeout, reached the deadline or the run was stopped
+func (b *Bench) await(c *completion) endReason {
+	var end <-chan time.Time
+	if deadline := b.deadline(); deadline > 0 {
+		t := time.NewTimer(deadline)
+		defer t.Stop()
+		end = t.C
+	}
+
+	// A nil channel never fires, so disabled timeouts don't end the wait
+	var idle <-chan time.Time
+	t := time.NewTimer(b.idleTimeout)
+	defer t.Stop()
+	if b.idleTimeout > 0 {
+		idle = t.C
+	}
+
+	for {
+		select {
+		case <-c.done:
+			return endCount
+		case <-end:
+			return endDeadline
+		case <-b.control.stop:
+			return endStopped
+		case <-idle:
+			// Messages that arrived meanwhile restart the wait
+			quiet := time.Since(time.Unix(0, c.last.Load()))
+			if quiet >= b.idleTimeout {
+				return endIdle
+			}
+			t.Reset(b.idleTimeout - quiet)
+		}
+	}
+}
+
+// endReasons counts the conditions that ended the subscribers
+type endReasons struct {
+	mu     sync.Mutex
+	counts map[endReason]int
+}
+
+func (e *endReasons) add(reason endReason) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.counts == nil {
+		e.counts = make(map[endReason]int)
+	}
+	e.counts[reason]++
+}
+
+func (e *endReasons) attrs() []slog.Attr {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return []slog.Attr{
+		logger.Int("endedByCount", e.counts[endCount]),
+		logger.Int("endedByIdle", e.counts[endIdle]),
+		logger.Int("endedByDeadline", e.counts[endDeadline]),
+		logger.Int("endedByStop", e.counts[endStopped]),
+	}
+}
@@ -2,39 +2,115 @@ package bench
 
 import (
 	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/rayomqio/benchmq/internal/mqtt"
 	"github.com/rayomqio/benchmq/pkg/logger"
 )
 
 func (b *Bench) RunConnections() {
+	// Baseline for the client-side memory per connection
+	baseMem, baseGoroutines := memoryInUse()
+
+	if !b.begin() {
+		return
+	}
 	start := time.Now()
-	b.logger.Info("started connection benchmark", logger.Int("time", int(start.UnixNano())))
+	b.logger.Info("started connection benchmark", logger.Int("time", int(start.UnixNano())), logger.String("clientImpl", string(b.clientImpl)))
 
+	var open int64
+	var failed int64
+	var attempted sync.WaitGroup
+	release := make(chan struct{})
+
+	launched := 0
 	for i := 0; i < b.clients; i++ {
 		b.wg.Add(1)
+		launched++
+		attempted.Add(1)
 		go func(id int) {
 			defer b.wg.Done()
 
-			cfg := *b.cfg
-			cfg.Client.ClientID = fmt.Sprintf("%s-%d", b.clientID, id)
-			cfg.Client.CleanSession = *b.cleanSession
-			cfg.Client.KeepAlive = b.keepAlive
-			cfg.Client.Username = b.username
-			cfg.Client.Password = b.password
-			client := mqtt.NewClient(&cfg)
+			clientID := fmt.Sprintf("%s-%d", b.clientID, id)
+			client, a, err := b.newClient(clientID)
+			if err != nil {
+				atomic.AddInt64(&failed, 1)
+				a.failed()
+				b.timeline.Error("client")
+				attempted.Done()
+				b.logger.Error("failed to create client", logger.ClientID(clientID), logger.ErrorAttr(err))
+				return
+			}
 			defer client.Disconnect()
 
-			if err := client.Connect(); err != nil {
-				b.logger.Error("couldn't establish client", logger.ClientID(cfg.Client.ClientID), logger.State("failed"))
+			if err := b.connect(client, clientID, a.endpoint.url.Host); err != nil {
+				atomic.AddInt64(&failed, 1)
+				a.failed()
+				b.timeline.Error("connect")
+				attempted.Done()
+				b.logger.Error("couldn't establish client", logger.ClientID(clientID), logger.State("failed"))
 				return
 			}
-			b.logger.LogClientConnection(cfg.Client.ClientID)
+			atomic.AddInt64(&open, 1)
+			a.connected()
+			b.timeline.Connected()
+			defer b.timeline.Disconnected()
+			attempted.Done()
+			b.logger.LogClientConnection(clientID)
+
+			// Stay open until every connection was attempted and measured
+			<-release
+			atomic.AddInt64(&open, -1)
 		}(i)
-		time.Sleep(time.Duration(b.delay) * time.Millisecond)
+		if !b.pause() {
+			break
+		}
+	}
+
+	attempted.Wait()
+	connectElapsed := time.Since(start).Seconds()
+
+	attrs := []slog.Attr{
+		logger.Int("clients", launched),
+		logger.Any("failed", atomic.LoadInt64(&failed)),
+		logger.String("clientImpl", string(b.clientImpl)),
 	}
 
+	// Every established connection is still open until release is closed
+	mem, goroutines := memoryInUse()
+	held := atomic.LoadInt64(&open)
+	attrs = append(attrs, logger.Any("heldConnections", held))
+	if held > 0 {
+		attrs = append(attrs,
+			logger.Float("memPerConnBytes", float64(int64(mem)-int64(baseMem))/float64(held)),
+			logger.Float("goroutinesPerConn", float64(goroutines-baseGoroutines)/float64(held)),
+		)
+	}
+	if b.hold > 0 {
+		b.logger.Info("holding connections", logger.Any("held", held), logger.String("hold", b.hold.String()))
+		b.sleep(b.hold)
+	}
+	close(release)
+
 	b.wg.Wait()
-	b.logger.Info("finished connection benchmark", logger.Any("time", time.Since(start).Seconds()))
+	elapsed := time.Since(start).Seconds()
+	attrs = append(attrs,
+		logger.Any("time", elapsed),
+		logger.Float("connectSec", connectElapsed),
+	)
+	attrs = append(attrs, b.handshake.attrs()...)
+	attrs = append(attrs, b.trafficAttrs(elapsed)...)
+	b.end("Connection benchmark", attrs)
+}
+
+// memoryInUse returns the Go heap and stack memory in use after a garbage
+// collection, and the number of goroutines
+func memoryInUse() (uint64, int) {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse + stats.StackInuse, runtime.NumGoroutine()
 }
@@ -0,0 +1,255 @@
+package bench
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/timeline"
+	"github.com/rayomqio/benchmq/pkg/er"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// control steers a benchmark while it runs, it is used by the HTTP API of
+// benchmq serve and safe for concurrent use
+type control struct {
+	pause atomic.Int64 // Delay between messages or connections in nanoseconds
+	stop  chan struct{}
+	once  sync.Once
+
+	mu       sync.Mutex
+	timeline *timeline.Recorder
+	spawn    func(i int, retired *atomic.Bool) // Starts client i, nil unless clients can be changed
+	retired  []*atomic.Bool                    // Set when a client started by spawn is removed or returned
+	active   int                               // Clients started and neither removed nor returned
+	running  int                               // Clients started and not returned, plus the launch
+	results  []slog.Attr
+	err      error // Why the run couldn't start
+}
+
+func newControl(delay time.Duration) *control {
+	c := &control{stop: make(chan struct{})}
+	c.pause.Store(int64(delay))
+	return c
+}
+
+// Status is a live view of a running benchmark
+type Status struct {
+	Clients  int // Publishers still running, the configured clients for other runs
+	Delay    time.Duration
+	Sent     int64
+	Received int64
+	Errors   int64
+	Active   int64 // Connections open at the end of the last interval
+	Last     *timeline.Interval
+}
+
+// Stop ends the benchmark early, clients finish their current message and
+// the run reports what happened until then
+func (b *Bench) Stop() {
+	b.control.once.Do(func() { close(b.control.stop) })
+}
+
+// SetDelay changes the delay between messages of pub and propagation, and
+// between connections of conn
+func (b *Bench) SetDelay(delay time.Duration) error {
+	if delay < 0 {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "SetDelay",
+			Message: er.ErrInvalidDelay,
+			Raw:     er.ErrInvalidDelay,
+		}
+	}
+	b.control.pause.Store(int64(delay))
+	return nil
+}
+
+// SetClients changes the number of publishers of a running pub benchmark,
+// new clients publish the full message count and removed ones stop after
+// their current message
+func (b *Bench) SetClients(clients int) error {
+	c := b.control
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if clients <= 0 {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "SetClients",
+			Message: er.ErrInvalidClients,
+			Raw:     er.ErrInvalidClients,
+		}
+	}
+	if c.spawn == nil {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "SetClients",
+			Message: er.ErrNotAdjustable,
+			Raw:     er.ErrNotAdjustable,
+		}
+	}
+
+	c.scale(clients)
+	return nil
+}
+
+// Status returns the progress of the run so far
+func (b *Bench) Status() Status {
+	c := b.control
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Status{Clients: b.clients, Delay: time.Duration(c.pause.Load())}
+	if c.spawn != nil || len(c.retired) > 0 {
+		s.Clients = c.active
+	}
+	if c.timeline == nil {
+		return s
+	}
+
+	intervals := c.timeline.Intervals()
+	for _, in := range intervals {
+		s.Sent += in.Sent
+		s.Received += in.Received
+		s.Errors += in.Errors
+	}
+	if len(intervals) > 0 {
+		last := intervals[len(intervals)-1]
+		s.Active = last.Active
+		s.Last = &last
+	}
+	return s
+}
+
+// Results returns the final results of the run, nil until it finished
+func (b *Bench) Results() []slog.Attr {
+	b.control.mu.Lock()
+	defer b.control.mu.Unlock()
+	return b.control.results
+}
+
+// Intervals returns the per-interval metrics of a finished run
+func (b *Bench) Intervals() []timeline.Interval {
+	return b.intervals
+}
+
+// Stopped reports whether the run was stopped
+func (b *Bench) Stopped() bool {
+	select {
+	case <-b.control.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits d, it returns false when the run is stopped first
+func (b *Bench) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return !b.Stopped()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-b.control.stop:
+		return false
+	}
+}
+
+// pause waits the current delay, it returns false when the run is stopped
+func (b *Bench) pause() bool {
+	return b.sleep(time.Duration(b.control.pause.Load()))
+}
+
+// watch exposes the timeline of the run to Status
+func (b *Bench) watch(r *timeline.Recorder) {
+	b.control.mu.Lock()
+	b.control.timeline = r
+	b.control.mu.Unlock()
+}
+
+// finish records the final results of the run
+func (b *Bench) finish(attrs []slog.Attr) {
+	b.control.mu.Lock()
+	b.control.results = attrs
+	b.control.mu.Unlock()
+}
+
+// fail records and logs why the run couldn't start
+func (b *Bench) fail(err error) {
+	b.control.mu.Lock()
+	b.control.err = err
+	b.control.mu.Unlock()
+	b.logger.Error("failed to start benchmark", logger.ErrorAttr(err))
+}
+
+// Err returns why the run couldn't start, nil when it ran
+func (b *Bench) Err() error {
+	b.control.mu.Lock()
+	defer b.control.mu.Unlock()
+	return b.control.err
+}
+
+// launch starts clients through spawn and keeps accepting SetClients until
+// every spawned client returned, each client calls b.done when it returns
+func (b *Bench) launch(clients int, spawn func(i int, retired *atomic.Bool)) {
+	c := b.control
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.spawn = spawn
+	// Hold a slot so clients failing right away don't close the launch
+	c.running++
+	c.scale(clients)
+	c.release()
+}
+
+// done marks spawned client i as returned
+func (b *Bench) done(i int) {
+	c := b.control
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.retired[i].Load() {
+		c.retired[i].Store(true)
+		c.active--
+	}
+	c.release()
+}
+
+// spawned returns the number of clients started by launch over the run
+func (b *Bench) spawned() int {
+	b.control.mu.Lock()
+	defer b.control.mu.Unlock()
+	return len(b.control.retired)
+}
+
+// scale starts or retires clients until n are active, c.mu must be held
+func (c *control) scale(n int) {
+	for c.active < n {
+		retired := &atomic.Bool{}
+		c.retired = append(c.retired, retired)
+		c.active++
+		c.running++
+		c.spawn(len(c.retired)-1, retired)
+	}
+	for i := len(c.retired) - 1; i >= 0 && c.active > n; i-- {
+		if !c.retired[i].Load() {
+			c.retired[i].Store(true)
+			c.active--
+		}
+	}
+}
+
+// release frees a running slot, the last one ends SetClients, c.mu must be
+// held
+func (c *control) release() {
+	c.running--
+	if c.running == 0 {
+		c.spawn = nil
+	}
+}
@@ -0,0 +1,261 @@
+package bench
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/rayomqio/benchmq/internal/chaos"
+	"github.com/rayomqio/benchmq/pkg/er"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// Strategy assigns clients to broker endpoints
+type Strategy string
+
+const (
+	StrategyRoundRobin Strategy = "round-robin" // Clients cycle through the endpoints
+	StrategyRandom     Strategy = "random"      // Every client picks a random endpoint
+	StrategyHash       Strategy = "hash"        // Clients are pinned by a hash of their client ID
+	StrategyWeighted   Strategy = "weighted"    // Round-robin proportional to the endpoint weights
+)
+
+// endpoint is a broker URL together with the results of its clients
+type endpoint struct {
+	url    *url.URL
+	via    string // Chaos proxy clients connect through, empty without one
+	weight int
+
+	clients   atomic.Int64 // Clients assigned
+	connected atomic.Int64 // Successful connections
+	failed    atomic.Int64 // Failed connections
+	messages  atomic.Int64 // Messages published or received
+	errors    atomic.Int64 // Failed publishes or subscriptions
+}
+
+// defaultPorts is the broker port of each scheme when the URL has none
+var defaultPorts = map[string]string{
+	"tcp":   "1883",
+	"mqtt":  "1883",
+	"ssl":   "8883",
+	"tls":   "8883",
+	"mqtts": "8883",
+	"tcps":  "8883",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// parseEndpoint parses a broker URL, a weight is given with the weight query
+// parameter (tcp://node1:1883?weight=3) and defaults to 1. A URL without a
+// port gets the default port of its scheme.
+func parseEndpoint(raw string) (*endpoint, error) {
+	uri, err := url.Parse(raw)
+	if err != nil || uri.Scheme == "" || uri.Host == "" {
+		if err == nil {
+			err = er.ErrInvalidBroker
+		}
+		return nil, &er.Error{
+			Package: "Bench",
+			Func:    "ParseEndpoint",
+			Message: er.ErrInvalidBroker,
+			Raw:     err,
+		}
+	}
+
+	port, ok := defaultPorts[uri.Scheme]
+	if !ok {
+		return nil, &er.Error{
+			Package: "Bench",
+			Func:    "ParseEndpoint",
+			Message: er.ErrInvalidBroker,
+			Raw:     fmt.Errorf("unsupported scheme %q in %s", uri.Scheme, raw),
+		}
+	}
+	if uri.Port() == "" {
+		uri.Host = net.JoinHostPort(uri.Hostname(), port)
+	}
+
+	weight := 1
+	query := uri.Query()
+	if w := query.Get("weight"); w != "" {
+		weight, err = strconv.Atoi(w)
+		if err != nil || weight <= 0 {
+			return nil, &er.Error{
+				Package: "Bench",
+				Func:    "ParseEndpoint",
+				Message: er.ErrInvalidBroker,
+				Raw:     fmt.Errorf("invalid weight %q in %s", w, raw),
+			}
+		}
+		query.Del("weight")
+		uri.RawQuery = query.Encode()
+	}
+
+	return &endpoint{url: uri, weight: weight}, nil
+}
+
+// balancer picks the endpoint of each client
+type balancer struct {
+	strategy  Strategy
+	endpoints []*endpoint
+	slots     []int // Endpoint indices, each repeated by its weight
+	next      atomic.Uint64
+}
+
+func newBalancer(strategy Strategy, endpoints []*endpoint) (*balancer, error) {
+	switch strategy {
+	case StrategyRoundRobin, StrategyRandom, StrategyHash, StrategyWeighted:
+	default:
+		return nil, &er.Error{
+			Package: "Bench",
+			Func:    "NewBalancer",
+			Message: er.ErrUnknownStrategy,
+			Raw:     fmt.Errorf("%w: %q", er.ErrUnknownStrategy, strategy),
+		}
+	}
+
+	lb := &balancer{strategy: strategy, endpoints: endpoints}
+	if strategy == StrategyWeighted {
+		// Interleave the slots so consecutive clients spread over endpoints
+		remaining := make([]int, len(endpoints))
+		for i, ep := range endpoints {
+			remaining[i] = ep.weight
+		}
+		for placed := true; placed; {
+			placed = false
+			for i := range remaining {
+				if remaining[i] > 0 {
+					lb.slots = append(lb.slots, i)
+					remaining[i]--
+					placed = true
+				}
+			}
+		}
+	}
+	return lb, nil
+}
+
+// pick returns the endpoint for clientID and counts the assignment
+func (lb *balancer) pick(clientID string) *endpoint {
+	var i int
+	switch lb.strategy {
+	case StrategyRandom:
+		i = rand.IntN(len(lb.endpoints))
+	case StrategyHash:
+		h := fnv.New32a()
+		h.Write([]byte(clientID))
+		i = int(h.Sum32() % uint32(len(lb.endpoints)))
+	case StrategyWeighted:
+		i = lb.slots[(lb.next.Add(1)-1)%uint64(len(lb.slots))]
+	default:
+		i = int((lb.next.Add(1) - 1) % uint64(len(lb.endpoints)))
+	}
+
+	ep := lb.endpoints[i]
+	ep.clients.Add(1)
+	return ep
+}
+
+// logEndpoints logs the per-endpoint breakdown, it is skipped for a single
+// endpoint since the totals already cover it
+func (b *Bench) logEndpoints() {
+	if len(b.balancer.endpoints) < 2 {
+		return
+	}
+	for _, ep := range b.balancer.endpoints {
+		b.logger.Info("endpoint results", b.endpointAttrs(ep)...)
+	}
+}
+
+func (b *Bench) endpointAttrs(ep *endpoint) []slog.Attr {
+	return []slog.Attr{
+		logger.String("endpoint", ep.url.Redacted()),
+		logger.String("strategy", string(b.balancer.strategy)),
+		logger.Int("weight", ep.weight),
+		logger.Any("clients", ep.clients.Load()),
+		logger.Any("connected", ep.connected.Load()),
+		logger.Any("failed", ep.failed.Load()),
+		logger.Any("messages", ep.messages.Load()),
+		logger.Any("errors", ep.errors.Load()),
+	}
+}
+
+// setupChaos checks the chaos fault list, the proxies only start with the run
+func (b *Bench) setupChaos() error {
+	if b.chaosSpec == "" {
+		return nil
+	}
+	if b.proxy != "" {
+		return &er.Error{
+			Package: "Bench",
+			Func:    "SetupChaos",
+			Message: er.ErrInvalidChaos,
+			Raw:     fmt.Errorf("chaos can't be combined with a proxy"),
+		}
+	}
+
+	options, err := chaos.ParseSpec(b.chaosSpec)
+	if err != nil {
+		return err
+	}
+	// New validates the settings without listening
+	if _, err := chaos.New(chaos.DefaultListen, "broker", options...); err != nil {
+		return err
+	}
+	b.chaosOpts = options
+	return nil
+}
+
+// startChaos starts a chaos proxy on the loopback interface for every
+// endpoint and routes its clients through it, the broker host is kept for
+// TLS and WebSocket
+func (b *Bench) startChaos() error {
+	if b.chaosOpts == nil {
+		return nil
+	}
+	for _, ep := range b.balancer.endpoints {
+		proxy, err := chaos.New("127.0.0.1:0", ep.url.Host, b.chaosOpts...)
+		if err == nil {
+			err = proxy.Start()
+		}
+		if err != nil {
+			b.closeChaos()
+			return err
+		}
+		b.chaos = append(b.chaos, proxy)
+		ep.via = proxy.Addr()
+	}
+	return nil
+}
+
+// closeChaos stops the chaos proxies without logging their results
+func (b *Bench) closeChaos() {
+	for i, proxy := range b.chaos {
+		_ = proxy.Close()
+		b.balancer.endpoints[i].via = ""
+	}
+	b.chaos = nil
+}
+
+// logChaos stops the chaos proxies and logs what they injected
+func (b *Bench) logChaos() {
+	for i, proxy := range b.chaos {
+		_ = proxy.Close()
+		b.balancer.endpoints[i].via = ""
+		s := proxy.Stats()
+		b.logger.Info("chaos results",
+			logger.String("endpoint", b.balancer.endpoints[i].url.Redacted()),
+			logger.Any("connections", s.Connections),
+			logger.Any("resets", s.Resets),
+			logger.Any("stalls", s.Stalls),
+			logger.Any("bytesUp", s.BytesUp),
+			logger.Any("bytesDown", s.BytesDown),
+		)
+	}
+	b.chaos = nil
+}
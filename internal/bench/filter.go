@@ -0,0 +1,107 @@
+package bench
+
+import (
+	"sync/atomic"
+
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/sparkplug"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// subFilters returns the topic filters every subscriber subscribes to, the
+// topic at the benchmark QoS unless filters were configured
+func (b *Bench) subFilters() []mqtt.Filter {
+	switch {
+	case b.sparkplug:
+		return []mqtt.Filter{{Topic: sparkplug.Filter(b.spGroup), QoS: byte(b.qos)}}
+	case len(b.filters) > 0:
+		return uniqueFilters(b.filters)
+	}
+	return []mqtt.Filter{{Topic: b.topic, QoS: byte(b.qos)}}
+}
+
+// uniqueFilters drops repeated filters, the first keeps the highest QoS any
+// of them asked for
+func uniqueFilters(filters []mqtt.Filter) []mqtt.Filter {
+	unique := make([]mqtt.Filter, 0, len(filters))
+	index := make(map[string]int, len(filters))
+	for _, f := range filters {
+		if i, ok := index[f.Topic]; ok {
+			unique[i].QoS = max(unique[i].QoS, f.QoS)
+			continue
+		}
+		index[f.Topic] = len(unique)
+		unique = append(unique, f)
+	}
+	return unique
+}
+
+// firstMatch reports whether filter is the first of filters matching topic.
+// Clients call back once for every filter a message matches, counting only
+// the call of the first one counts every PUBLISH once. A topic none of the
+// filters match is counted as well.
+func firstMatch(filters []mqtt.Filter, filter, topic string) bool {
+	for _, f := range filters {
+		if mqtt.TopicMatch(f.Topic, topic) {
+			return f.Topic == filter
+		}
+	}
+	return true
+}
+
+// subscribe subscribes client to filters with a single SUBSCRIBE or with one
+// per filter
+func (b *Bench) subscribe(client mqtt.Client, filters []mqtt.Filter, callback func(filter, topic string, payload []byte)) error {
+	if !b.perFilter {
+		return client.SubscribeFilters(filters, callback)
+	}
+	for _, f := range filters {
+		if err := client.SubscribeFilters([]mqtt.Filter{f}, callback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterCounts counts the messages received per topic filter over all
+// subscribers, a message matching several filters counts for each
+type filterCounts struct {
+	filters []mqtt.Filter
+	counts  map[string]*atomic.Int64
+	qos     map[string]byte
+}
+
+func newFilterCounts(filters []mqtt.Filter) *filterCounts {
+	f := &filterCounts{
+		filters: filters,
+		counts:  make(map[string]*atomic.Int64, len(filters)),
+		qos:     make(map[string]byte, len(filters)),
+	}
+	for _, filter := range filters {
+		f.counts[filter.Topic] = new(atomic.Int64)
+		f.qos[filter.Topic] = filter.QoS
+	}
+	return f
+}
+
+// add counts a message of filter and returns the QoS it was subscribed with
+func (f *filterCounts) add(filter string) byte {
+	if c := f.counts[filter]; c != nil {
+		c.Add(1)
+	}
+	return f.qos[filter]
+}
+
+// logFilters logs the messages received per filter when there are several
+func (b *Bench) logFilters(f *filterCounts) {
+	if len(f.filters) < 2 {
+		return
+	}
+	for _, filter := range f.filters {
+		b.logger.Info("filter results",
+			logger.String("filter", filter.Topic),
+			logger.Int("qos", int(filter.QoS)),
+			logger.Any("received", f.counts[filter.Topic].Load()),
+		)
+	}
+}
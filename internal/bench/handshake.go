@@ -0,0 +1,71 @@
+package bench
+
+import (
+	"log/slog"
+
+	"github.com/rayomqio/benchmq/internal/metrics"
+	"github.com/rayomqio/benchmq/internal/mqtt"
+)
+
+// handshake aggregates the connection phases of all clients
+type handshake struct {
+	dns       *metrics.Histogram
+	tcp       *metrics.Histogram
+	proxy     *metrics.Histogram
+	tls       *metrics.Histogram
+	websocket *metrics.Histogram
+	connack   *metrics.Histogram
+	total     *metrics.Histogram
+}
+
+func newHandshake() *handshake {
+	return &handshake{
+		dns:       metrics.NewHistogram(),
+		tcp:       metrics.NewHistogram(),
+		proxy:     metrics.NewHistogram(),
+		tls:       metrics.NewHistogram(),
+		websocket: metrics.NewHistogram(),
+		connack:   metrics.NewHistogram(),
+		total:     metrics.NewHistogram(),
+	}
+}
+
+// record adds the phases of one connection, proxy, TLS and WebSocket are only
+// recorded for connections that use them
+func (h *handshake) record(p mqtt.Phases) {
+	h.dns.Record(p.DNS)
+	h.tcp.Record(p.TCP)
+	if p.Proxy > 0 {
+		h.proxy.Record(p.Proxy)
+	}
+	if p.TLS > 0 {
+		h.tls.Record(p.TLS)
+	}
+	if p.WebSocket > 0 {
+		h.websocket.Record(p.WebSocket)
+	}
+	h.connack.Record(p.Connack)
+	h.total.Record(p.DNS + p.TCP + p.Proxy + p.TLS + p.WebSocket + p.Connack)
+}
+
+// attrs returns the distribution of every phase that was recorded
+func (h *handshake) attrs() []slog.Attr {
+	var attrs []slog.Attr
+	for _, phase := range []struct {
+		name string
+		h    *metrics.Histogram
+	}{
+		{"connect", h.total},
+		{"dns", h.dns},
+		{"tcp", h.tcp},
+		{"proxy", h.proxy},
+		{"tls", h.tls},
+		{"websocket", h.websocket},
+		{"connack", h.connack},
+	} {
+		if phase.h.Count() > 0 {
+			attrs = append(attrs, latencyAttrs(phase.name, phase.h)...)
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,217 @@
+package bench
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/metrics"
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/payload"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// Large message defaults
+const (
+	DefaultLargeMin    = 1 << 20   // Payload size of the first step
+	DefaultLargeMax    = 256 << 20 // Payload size of the last step, capped to the protocol limit
+	DefaultLargeFactor = 2         // Growth of the payload size between steps
+)
+
+// largeStep is the outcome of publishing one payload size
+type largeStep struct {
+	size     int
+	sent     int64
+	failed   int64
+	transfer *metrics.Histogram
+	rateMin  float64 // Bytes per second of the slowest message
+	rateMax  float64
+	rateSum  float64
+	err      error // First failure, the broker rejected or dropped the message
+	mu       sync.Mutex
+}
+
+// record adds the transfer of one message
+func (s *largeStep) record(d time.Duration) {
+	rate := float64(s.size) / d.Seconds()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent++
+	s.transfer.Record(d)
+	if s.sent == 1 || rate < s.rateMin {
+		s.rateMin = rate
+	}
+	s.rateMax = max(s.rateMax, rate)
+	s.rateSum += rate
+}
+
+// fail records a message the broker didn't accept
+func (s *largeStep) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed++
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *largeStep) attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		logger.Int("sizeBytes", s.size),
+		logger.Any("sent", s.sent),
+		logger.Any("failed", s.failed),
+	}
+	if s.sent > 0 {
+		attrs = append(attrs,
+			logger.Float("transferMinMs", ms(s.transfer.Min())),
+			logger.Float("transferMeanMs", ms(s.transfer.Mean())),
+			logger.Float("transferMaxMs", ms(s.transfer.Max())),
+			logger.Float("bytesPerSecMin", s.rateMin),
+			logger.Float("bytesPerSecMean", s.rateSum/float64(s.sent)),
+			logger.Float("bytesPerSecMax", s.rateMax),
+		)
+	}
+	return attrs
+}
+
+// largeSizes returns the payload size of every step, the last one is capped
+// to the largest payload a PUBLISH can carry
+func (b *Bench) largeSizes() []int {
+	limit := mqtt.MaxPayloadSize(b.topic, byte(b.qos))
+	var sizes []int
+	for size := b.largeMin; ; size *= b.largeFactor {
+		if size >= min(b.largeMax, limit) {
+			return append(sizes, min(b.largeMax, limit))
+		}
+		sizes = append(sizes, size)
+	}
+}
+
+// LargeMessages publishes payloads of growing size until the maximum or the
+// first size the broker rejects, waiting the delay between sizes. Payloads
+// are streamed from a small shared chunk, so memory use doesn't grow with the
+// payload size on the native client. The transfer of a message lasts until
+// the broker acknowledged it, QoS 0 messages are followed by a PINGREQ whose
+// PINGRESP confirms the broker read them. Paho can't ping on demand, its QoS
+// 0 transfers end once the payload was written.
+func (b *Bench) LargeMessages() {
+	if !b.begin() {
+		return
+	}
+	start := time.Now()
+	sizes := b.largeSizes()
+	b.logger.Info("started large message benchmark",
+		logger.String("start", start.Format(time.RFC3339Nano)),
+		logger.Int("minBytes", sizes[0]),
+		logger.Int("maxBytes", sizes[len(sizes)-1]),
+		logger.Int("steps", len(sizes)),
+	)
+	if b.clientImpl == mqtt.ImplPaho {
+		b.logger.Warn("paho holds every payload in memory, use the native client to stream large payloads")
+	}
+
+	var clients []mqtt.Client
+	var failed int
+	for i := 0; i < b.clients; i++ {
+		id := fmt.Sprintf("%s-%d", b.clientID, i)
+		client, a, err := b.newClient(id)
+		if err != nil {
+			failed++
+			a.failed()
+			b.timeline.Error("client")
+			b.logger.Error("failed to create client", logger.ClientID(id), logger.ErrorAttr(err))
+			continue
+		}
+		if err := b.connect(client, id, a.endpoint.url.Host); err != nil {
+			failed++
+			a.failed()
+			b.timeline.Error("connect")
+			b.logger.Error("couldn't establish client", logger.ClientID(id), logger.ErrorAttr(err))
+			continue
+		}
+		a.connected()
+		b.timeline.Connected()
+		b.logger.LogClientConnection(id)
+		clients = append(clients, client)
+	}
+
+	var accepted, rejected int
+	var reason error
+	for _, size := range sizes {
+		if len(clients) == 0 || !b.pause() {
+			break
+		}
+		step := b.largeStep(clients, size)
+		if step.err != nil {
+			rejected, reason = size, step.err
+			b.logger.Warn("broker rejected large message", append(step.attrs(), logger.ErrorAttr(step.err))...)
+			break
+		}
+		accepted = size
+		b.logger.Info("large message size", step.attrs()...)
+	}
+
+	for _, client := range clients {
+		client.Disconnect()
+		b.timeline.Disconnected()
+	}
+
+	elapsed := time.Since(start).Seconds()
+	attrs := []slog.Attr{
+		logger.Int("clients", len(clients)),
+		logger.Int("failedClients", failed),
+		logger.Int("messagesPerSize", b.messageCount),
+		logger.Int("largestAcceptedBytes", accepted),
+		logger.Int("rejectedAtBytes", rejected),
+		logger.Float("elapsedSec", elapsed),
+	}
+	if reason != nil {
+		attrs = append(attrs, logger.String("rejectReason", reason.Error()))
+	}
+	attrs = append(attrs, latencyAttrs("transfer", b.rtt)...)
+	attrs = append(attrs, b.trafficAttrs(elapsed)...)
+	b.end("Large message benchmark", attrs)
+}
+
+// largeStep publishes messageCount payloads of size from every client at once
+func (b *Bench) largeStep(clients []mqtt.Client, size int) *largeStep {
+	step := &largeStep{size: size, transfer: metrics.NewHistogram()}
+	stream := payload.NewStream(size)
+
+	var wg sync.WaitGroup
+	var stopped atomic.Bool
+	for _, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range b.messageCount {
+				if stopped.Load() || b.Stopped() {
+					return
+				}
+
+				sent := time.Now()
+				err := client.Publish(b.topic, byte(b.qos), b.retained, stream, func() {})
+				if pinger, ok := client.(mqtt.Pinger); ok && err == nil && b.qos == QoS0 {
+					err = pinger.Ping()
+				}
+				if err != nil {
+					// A rejected size ends the step for every client
+					stopped.Store(true)
+					step.fail(err)
+					b.timeline.Error("publish")
+					return
+				}
+
+				d := time.Since(sent)
+				step.record(d)
+				b.rtt.Record(d)
+				b.timeline.Sent(d)
+				b.payloadBytes.Add(int64(size))
+			}
+		}()
+	}
+	wg.Wait()
+	return step
+}
@@ -0,0 +1,28 @@
+package bench
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/metrics"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// latencyAttrs returns the distribution of h in milliseconds with every
+// attribute key prefixed by name
+func latencyAttrs(name string, h *metrics.Histogram) []slog.Attr {
+	return []slog.Attr{
+		logger.Float(name+"MinMs", ms(h.Min())),
+		logger.Float(name+"MeanMs", ms(h.Mean())),
+		logger.Float(name+"P50Ms", ms(h.Percentile(50))),
+		logger.Float(name+"P90Ms", ms(h.Percentile(90))),
+		logger.Float(name+"P99Ms", ms(h.Percentile(99))),
+		logger.Float(name+"P999Ms", ms(h.Percentile(99.9))),
+		logger.Float(name+"MaxMs", ms(h.Max())),
+	}
+}
+
+// ms converts a duration to fractional milliseconds
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,72 @@
+package bench
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// node is a broker address clients can be pointed at
+type node struct {
+	host string
+	port uint16
+}
+
+// String returns the host:port form of the node
+func (n node) String() string {
+	return net.JoinHostPort(n.host, strconv.Itoa(int(n.port)))
+}
+
+// parseNode parses a host or host:port address, defaultPort is used when the
+// address has no port. IPv6 hosts may be bracketed with or without a port.
+func parseNode(addr string, defaultPort uint16) (node, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port in the address
+		host = addr
+		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1]
+		}
+		if host == "" {
+			return node{}, invalidNode(addr, er.ErrEmptyHost)
+		}
+		return node{host: host, port: defaultPort}, nil
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port == 0 || host == "" {
+		return node{}, invalidNode(addr, err)
+	}
+	return node{host: host, port: uint16(port)}, nil
+}
+
+// parseNodes parses every address, an empty list yields fallback
+func parseNodes(addrs []string, defaultPort uint16, fallback node) ([]node, error) {
+	if len(addrs) == 0 {
+		return []node{fallback}, nil
+	}
+
+	nodes := make([]node, 0, len(addrs))
+	for _, addr := range addrs {
+		n, err := parseNode(addr, defaultPort)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+func invalidNode(addr string, raw error) error {
+	if raw == nil {
+		raw = er.ErrInvalidNode
+	}
+	return &er.Error{
+		Package: "Bench",
+		Func:    "ParseNode",
+		Message: er.ErrInvalidNode,
+		Raw:     raw,
+	}
+}
@@ -0,0 +1,31 @@
+package bench
+
+import "testing"
+
+func TestParseNode(t *testing.T) {
+	tests := []struct {
+		addr string
+		want node
+	}{
+		{"broker", node{host: "broker", port: 1883}},
+		{"broker:1884", node{host: "broker", port: 1884}},
+		{"10.0.0.1", node{host: "10.0.0.1", port: 1883}},
+		{"::1", node{host: "::1", port: 1883}},
+		{"[::1]", node{host: "::1", port: 1883}},
+		{"[::1]:1884", node{host: "::1", port: 1884}},
+	}
+	for _, tt := range tests {
+		got, err := parseNode(tt.addr, 1883)
+		if err != nil || got != tt.want {
+			t.Errorf("parseNode(%q) = %+v, %v, want %+v", tt.addr, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseNodeInvalid(t *testing.T) {
+	for _, addr := range []string{"", "[]", "broker:0", "broker:x", "[::1]:", ":1883"} {
+		if n, err := parseNode(addr, 1883); err == nil {
+			t.Errorf("parseNode(%q) = %+v, want an error", addr, n)
+		}
+	}
+}
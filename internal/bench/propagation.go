@@ -0,0 +1,228 @@
+package bench
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/metrics"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// probeSize is the length of a propagation probe: send time in unix
+// nanoseconds, publisher node, publisher client and sequence number
+const probeSize = 8 + 4 + 4 + 4
+
+// pairStats collects what one subscriber node received from one publisher node
+type pairStats struct {
+	mu         sync.Mutex
+	seen       map[uint64]struct{}
+	duplicates int64
+	latency    *metrics.Histogram
+}
+
+func newPairStats() *pairStats {
+	return &pairStats{seen: make(map[uint64]struct{}), latency: metrics.NewHistogram()}
+}
+
+// add records a probe, duplicates are counted but not measured again
+func (p *pairStats) add(client, seq uint32, latency time.Duration) {
+	key := uint64(client)<<32 | uint64(seq)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.seen[key]; ok {
+		p.duplicates++
+		return
+	}
+	p.seen[key] = struct{}{}
+	p.latency.Record(latency)
+}
+
+func encodeProbe(node, client, seq int) []byte {
+	buf := make([]byte, 0, probeSize)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(time.Now().UnixNano()))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(node))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(client))
+	return binary.BigEndian.AppendUint32(buf, uint32(seq))
+}
+
+func decodeProbe(payload string) (sent time.Time, node, client, seq uint32, ok bool) {
+	if len(payload) != probeSize {
+		return time.Time{}, 0, 0, 0, false
+	}
+	b := []byte(payload)
+	sent = time.Unix(0, int64(binary.BigEndian.Uint64(b)))
+	return sent, binary.BigEndian.Uint32(b[8:]), binary.BigEndian.Uint32(b[12:]), binary.BigEndian.Uint32(b[16:]), true
+}
+
+// RunPropagation publishes on every publisher node and subscribes on every
+// subscriber node to measure how messages propagate across a broker cluster,
+// latency and loss are reported per node pair
+func (b *Bench) RunPropagation() {
+	// The subscribers of the run are traced, probes carry the trace context
+	b.traceCarry = true
+	if !b.begin() {
+		return
+	}
+	start := time.Now()
+	b.logger.Info("started propagation benchmark",
+		logger.String("start", start.Format(time.RFC3339Nano)),
+		logger.Any("pubNodes", nodeNames(b.pubTargets)),
+		logger.Any("subNodes", nodeNames(b.subTargets)),
+	)
+
+	filter := b.topic + "/#"
+
+	// stats[sub][pub] is indexed by subscriber node, then publisher node
+	stats := make([][]*pairStats, len(b.subTargets))
+	subscribed := make([]bool, len(b.subTargets))
+	for s, target := range b.subTargets {
+		stats[s] = make([]*pairStats, len(b.pubTargets))
+		for p := range b.pubTargets {
+			stats[s][p] = newPairStats()
+		}
+
+		id := fmt.Sprintf("%s-sub-%d", b.clientID, s)
+		client, err := b.newClientAt(id, target)
+		if err != nil {
+			b.timeline.Error("client")
+			b.logger.Error("failed to create client", logger.ClientID(id), logger.ErrorAttr(err))
+			continue
+		}
+		if err := b.connect(client, id, target.String()); err != nil {
+			b.timeline.Error("connect")
+			b.logger.Error("subscriber connection failed", logger.ClientID(id), logger.String("node", target.String()), logger.ErrorAttr(err))
+			continue
+		}
+		b.timeline.Connected()
+		defer b.timeline.Disconnected()
+		defer client.Disconnect()
+
+		row := stats[s]
+		err = client.SubscribeMessages(filter, byte(b.qos), func(topic string, payload []byte) {
+			sent, p, c, seq, ok := decodeProbe(string(b.receive(id, topic, payload)))
+			if !ok || int(p) >= len(row) {
+				return
+			}
+			latency := time.Since(sent)
+			row[p].add(c, seq, latency)
+			b.timeline.Received(latency)
+		})
+		if err != nil {
+			b.timeline.Error("subscribe")
+			b.logger.Error("failed to subscribe", logger.ClientID(id), logger.ErrorAttr(err))
+			continue
+		}
+		subscribed[s] = true
+		b.logger.LogClientConnection(id)
+	}
+
+	// published[p] counts the messages each publisher node accepted
+	published := make([]int64, len(b.pubTargets))
+	var failed int64
+
+	for p, target := range b.pubTargets {
+		for i := 0; i < b.clients; i++ {
+			b.wg.Add(1)
+			go func(p, i int, target node) {
+				defer b.wg.Done()
+
+				id := fmt.Sprintf("%s-pub-%d-%d", b.clientID, p, i)
+				client, err := b.newClientAt(id, target)
+				if err != nil {
+					atomic.AddInt64(&failed, int64(b.messageCount))
+					b.timeline.Error("client")
+					b.logger.Error("failed to create client", logger.ClientID(id), logger.ErrorAttr(err))
+					return
+				}
+				if err := b.connect(client, id, target.String()); err != nil {
+					atomic.AddInt64(&failed, int64(b.messageCount))
+					b.timeline.Error("connect")
+					b.logger.Error("couldn't establish client", logger.ClientID(id), logger.String("node", target.String()), logger.ErrorAttr(err))
+					return
+				}
+				b.timeline.Connected()
+				defer b.timeline.Disconnected()
+				defer client.Disconnect()
+				b.logger.LogClientConnection(id)
+
+				topic := fmt.Sprintf("%s/%d/%d", b.topic, p, i)
+				for j := 0; j < b.messageCount; j++ {
+					if !b.pause() {
+						break
+					}
+					span, data := b.startPublish(id, topic, encodeProbe(p, i, j))
+					ack := b.startAck(span)
+					err := client.Publish(topic, byte(b.qos), false, data, func() {
+						ack.End(nil)
+						atomic.AddInt64(&published[p], 1)
+						b.timeline.Sent(0)
+					})
+					ack.End(err)
+					span.End(err)
+					if err != nil {
+						atomic.AddInt64(&failed, 1)
+						b.timeline.Error("publish")
+						b.logger.Error("failed to publish message", logger.ClientID(id), logger.ErrorAttr(err))
+					}
+				}
+			}(p, i, target)
+		}
+	}
+
+	b.wg.Wait()
+
+	// Give the cluster time to forward the last messages
+	b.sleep(b.drain)
+
+	for s, sub := range b.subTargets {
+		if !subscribed[s] {
+			continue
+		}
+		for p, pub := range b.pubTargets {
+			pair := stats[s][p]
+			pair.mu.Lock()
+			expected := atomic.LoadInt64(&published[p])
+			received := int64(len(pair.seen))
+			lost := max(expected-received, 0)
+			var lossPct float64
+			if expected > 0 {
+				lossPct = float64(lost) / float64(expected) * 100
+			}
+			attrs := append([]slog.Attr{
+				logger.String("pubNode", pub.String()),
+				logger.String("subNode", sub.String()),
+				logger.Any("expected", expected),
+				logger.Any("received", received),
+				logger.Any("lost", lost),
+				logger.Float("lossPct", lossPct),
+				logger.Any("duplicates", pair.duplicates),
+			}, latencyAttrs("latency", pair.latency)...)
+			pair.mu.Unlock()
+
+			b.logger.Info("node pair propagation", attrs...)
+		}
+	}
+
+	elapsed := time.Since(start).Seconds()
+	attrs := []slog.Attr{
+		logger.Int("pubNodes", len(b.pubTargets)),
+		logger.Int("subNodes", len(b.subTargets)),
+		logger.Int("clientsPerNode", b.clients),
+		logger.Any("failed", atomic.LoadInt64(&failed)),
+		logger.Float("elapsedSec", elapsed),
+	}
+	b.end("Propagation benchmark", attrs)
+}
+
+func nodeNames(nodes []node) []string {
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = n.String()
+	}
+	return names
+}
@@ -2,73 +2,194 @@ package bench
 
 import (
 	"fmt"
+	"log/slog"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/sparkplug"
 	"github.com/rayomqio/benchmq/pkg/logger"
 )
 
 func (b *Bench) PublishMessages() {
+	if !b.begin() {
+		return
+	}
 	start := time.Now()
 	b.logger.Info("started publish benchmark", logger.String("start", start.Format(time.RFC3339Nano)))
 
 	var failed int32
 	var succeeded int32
+	var skipped int32 // Messages left out by stopped or removed clients
 
-	for i := 0; i < b.clients; i++ {
+	b.launch(b.clients, func(i int, retired *atomic.Bool) {
 		b.wg.Add(1)
 
 		clientID := fmt.Sprintf("%s-%d", b.clientID, i)
 		go func(id string) {
 			defer b.wg.Done()
+			defer b.done(i)
 
-			cfg := *b.cfg
-			cfg.Client.ClientID = id
-			cfg.Client.CleanSession = *b.cleanSession
-			cfg.Client.KeepAlive = b.keepAlive
-			cfg.Client.Username = b.username
-			cfg.Client.Password = b.password
-			client := mqtt.NewClient(&cfg)
-			if err := client.Connect(); err != nil {
+			var edge *sparkplug.Node
+			var will []mqtt.Option
+			if b.sparkplug {
+				node, option, err := b.newEdgeNode(id)
+				if err != nil {
+					atomic.AddInt32(&failed, int32(b.messageCount))
+					b.logger.Error("failed to create sparkplug edge node", logger.ClientID(id), logger.ErrorAttr(err))
+					return
+				}
+				edge, will = node, []mqtt.Option{option}
+			}
+
+			client, a, err := b.newClient(id, will...)
+			if err != nil {
 				atomic.AddInt32(&failed, int32(b.messageCount))
+				a.failed()
+				b.timeline.Error("client")
+				b.logger.Error("failed to create client", logger.ClientID(id), logger.ErrorAttr(err))
+				return
+			}
+			if err := b.connect(client, id, a.endpoint.url.Host); err != nil {
+				atomic.AddInt32(&failed, int32(b.messageCount))
+				a.failed()
+				b.timeline.Error("connect")
 				b.logger.Error("couldn't establish client", logger.ClientID(id), logger.ErrorAttr(err))
 				return
 			}
-			b.logger.LogClientConnection(cfg.Client.ClientID)
+			a.connected()
+			b.timeline.Connected()
+			b.logger.LogClientConnection(id)
 
+			defer b.timeline.Disconnected()
 			defer client.Disconnect()
 
+			// Edge nodes are born before their data and announce their death
+			// before disconnecting, the broker publishes the will otherwise
+			if edge != nil {
+				b.publishLifecycle(client, id, edge.Births())
+				defer func() {
+					b.publishLifecycle(client, id, edge.DeviceDeaths())
+					b.publishLifecycle(client, id, []sparkplug.Message{edge.Death()})
+				}()
+			}
+
+			// In async mode the window bounds the unacknowledged messages
+			var inflight chan struct{}
+			var pending sync.WaitGroup
+			if b.async {
+				inflight = make(chan struct{}, b.maxInflight)
+			}
+
 			for j := 0; j < b.messageCount; j++ {
-				if b.delay > 0 {
-					time.Sleep(time.Duration(b.delay) * time.Millisecond)
+				if retired.Load() || !b.pause() {
+					atomic.AddInt32(&skipped, int32(b.messageCount-j))
+					break
 				}
 
-				err := client.Publish(b.topic, byte(b.qos), b.retained, b.message, func() {
+				topic := b.topic
+				var msg []byte
+				var err error
+				if edge != nil {
+					data := edge.Data()
+					topic, msg = data.Topic, data.Payload
+				} else {
+					msg, err = b.payload.Next(id, j)
+				}
+				if err != nil {
+					atomic.AddInt32(&failed, 1)
+					b.timeline.Error("payload")
+					b.logger.Error("failed to generate payload", logger.ClientID(id), logger.ErrorAttr(err))
+					continue
+				}
+
+				span, data := b.startPublish(id, topic, msg)
+				ack := b.startAck(span)
+
+				if !b.async {
+					sent := time.Now()
+					err = client.Publish(topic, byte(b.qos), b.retained, data, func() {
+						ack.End(nil)
+						atomic.AddInt32(&succeeded, 1)
+						a.endpoint.messages.Add(1)
+						b.payloadBytes.Add(int64(len(msg)))
+						b.logger.LogPublish(id, topic, int(b.qos))
+					})
+					ack.End(err)
+					span.End(err)
+					if err != nil {
+						atomic.AddInt32(&failed, 1)
+						a.endpoint.errors.Add(1)
+						b.timeline.Error("publish")
+						b.logger.Error("failed to publish message", logger.ErrorAttr(err))
+						continue
+					}
+					rtt := time.Since(sent)
+					b.rtt.Record(rtt)
+					b.timeline.Sent(rtt)
+					continue
+				}
+
+				inflight <- struct{}{}
+				pending.Add(1)
+				err = client.PublishAsync(topic, byte(b.qos), b.retained, data, func(rtt time.Duration, err error) {
+					defer pending.Done()
+					defer func() { <-inflight }()
+					ack.End(err)
+
+					if err != nil {
+						atomic.AddInt32(&failed, 1)
+						a.endpoint.errors.Add(1)
+						b.timeline.Error("publish")
+						b.logger.Error("failed to publish message", logger.ClientID(id), logger.ErrorAttr(err))
+						return
+					}
 					atomic.AddInt32(&succeeded, 1)
-					b.logger.LogPublish(id, b.topic, int(b.qos))
+					a.endpoint.messages.Add(1)
+					b.payloadBytes.Add(int64(len(msg)))
+					b.rtt.Record(rtt)
+					b.timeline.Sent(rtt)
+					b.logger.LogPublish(id, topic, int(b.qos))
 				})
+				span.End(err)
 				if err != nil {
+					ack.End(err)
+					<-inflight
+					pending.Done()
 					atomic.AddInt32(&failed, 1)
-					b.logger.Error("failed to publish message", logger.ErrorAttr(err))
+					a.endpoint.errors.Add(1)
+					b.timeline.Error("publish")
+					b.logger.Error("failed to publish message", logger.ClientID(id), logger.ErrorAttr(err))
 				}
 			}
+
+			pending.Wait()
 		}(clientID)
-	}
+	})
 
 	b.wg.Wait()
 
 	elapsed := time.Since(start).Seconds()
-	total := b.clients * b.messageCount
+	clients := b.spawned()
+	total := clients*b.messageCount - int(skipped)
 	throughput := float64(total) / elapsed
 
-	b.logger.Info("finished publish benchmark",
-		logger.Int("clients", b.clients),
+	attrs := []slog.Attr{
+		logger.Int("clients", clients),
 		logger.Int("messagesPerClient", b.messageCount),
 		logger.Int("totalMessages", total),
 		logger.Int("successful", int(succeeded)),
 		logger.Int("failed", int(failed)),
 		logger.Float("elapsedSec", elapsed),
 		logger.Float("throughputMsgPerSec", throughput),
-	)
+		logger.Bool("async", b.async),
+	}
+	if skipped > 0 {
+		attrs = append(attrs, logger.Int("skipped", int(skipped)))
+	}
+	attrs = append(attrs, b.publisherSparkplugAttrs()...)
+	attrs = append(attrs, latencyAttrs("rtt", b.rtt)...)
+	attrs = append(attrs, b.trafficAttrs(elapsed)...)
+	b.end("Publish benchmark", attrs)
 }
@@ -0,0 +1,140 @@
+package bench
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/report"
+	"github.com/rayomqio/benchmq/internal/timeline"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// startTimeline begins recording the per-interval metrics of a run and
+// streams them to the timeline file if one is set
+func (b *Bench) startTimeline() {
+	b.started = time.Now()
+
+	var options []timeline.Option
+	if b.seriesPath != "" {
+		out, err := timeline.Create(b.seriesPath, b.seriesFormat)
+		if err != nil {
+			b.logger.Error("failed to create timeline file", logger.String("path", b.seriesPath), logger.ErrorAttr(err))
+		} else {
+			b.seriesOut = out
+			failed := false
+			options = append(options, timeline.WithSink(func(in timeline.Interval) {
+				if err := out.Write(in); err != nil && !failed {
+					failed = true
+					b.logger.Error("failed to write timeline", logger.String("path", b.seriesPath), logger.ErrorAttr(err))
+				}
+			}))
+		}
+	}
+
+	b.timeline = timeline.New(b.interval, options...)
+	b.timeline.Start()
+	b.watch(b.timeline)
+}
+
+// stopTimeline closes the last interval and returns the attributes that
+// summarize the intervals of the run
+func (b *Bench) stopTimeline() []slog.Attr {
+	b.intervals = b.timeline.Stop()
+	if b.seriesOut != nil {
+		if err := b.seriesOut.Close(); err != nil {
+			b.logger.Error("failed to close timeline file", logger.String("path", b.seriesPath), logger.ErrorAttr(err))
+		}
+		b.seriesOut = nil
+	}
+
+	s := timeline.Summarize(b.intervals, b.interval)
+	return []slog.Attr{
+		logger.Int("intervals", s.Intervals),
+		logger.String("interval", b.interval.String()),
+		logger.Float("sentPerSecMin", s.SentPerSecMin),
+		logger.Float("sentPerSecMax", s.SentPerSecMax),
+		logger.Float("receivedPerSecMin", s.RecvPerSecMin),
+		logger.Float("receivedPerSecMax", s.RecvPerSecMax),
+		logger.Float("intervalP99MaxMs", ms(s.LatencyP99Max)),
+		logger.Float("errorsPerSecMax", s.ErrorsPerSecMax),
+		logger.Int("stalledIntervals", s.Stalls),
+		logger.Float("longestStallSec", s.LongestStall.Seconds()),
+	}
+}
+
+// begin starts what every run shares, it returns false when the run can't
+// start
+func (b *Bench) begin() bool {
+	if err := b.startChaos(); err != nil {
+		b.fail(err)
+		return false
+	}
+	if err := b.startTracing(); err != nil {
+		b.closeChaos()
+		b.fail(err)
+		return false
+	}
+	b.startCollectors()
+	b.startTimeline()
+	b.startMonitor()
+	return true
+}
+
+// end closes a run: it completes attrs with the timeline summary, records
+// and logs them as the results, stops everything the run started and writes
+// the report
+func (b *Bench) end(title string, attrs []slog.Attr) {
+	b.stopMonitor()
+	attrs = append(attrs, b.stopTimeline()...)
+	b.finish(attrs)
+	b.logger.Info("finished "+strings.ToLower(title), attrs...)
+	b.logEndpoints()
+	b.logSources()
+	b.logChaos()
+	b.logSysStats()
+	b.logResources()
+	b.stopTracing()
+	b.writeReport(title, attrs)
+}
+
+// writeReport writes the HTML report when one is configured, attrs are the
+// final results of the run
+func (b *Bench) writeReport(title string, attrs []slog.Attr) {
+	if b.reportPath == "" {
+		return
+	}
+
+	r := &report.Report{
+		Title:     title,
+		Command:   b.command,
+		Started:   b.started,
+		Elapsed:   time.Since(b.started).Round(time.Millisecond),
+		Settings:  b.settings,
+		Timeline:  b.intervals,
+		Errors:    b.timeline.Errors(),
+		Resources: b.resources,
+	}
+	for _, attr := range attrs {
+		r.Summary = append(r.Summary, report.Setting{Name: attr.Key, Value: formatValue(attr.Value)})
+	}
+	for _, c := range b.collectors {
+		r.Brokers = append(r.Brokers, report.Broker{Endpoint: c.endpoint.url.Redacted(), Series: c.series})
+	}
+
+	if err := report.Write(b.reportPath, r); err != nil {
+		b.logger.Error("failed to write report", logger.String("path", b.reportPath), logger.ErrorAttr(err))
+		return
+	}
+	b.logger.Info("report written", logger.String("path", b.reportPath))
+}
+
+// formatValue renders a result value, floats keep at most three decimals
+func formatValue(v slog.Value) string {
+	if v.Kind() != slog.KindFloat64 {
+		return v.String()
+	}
+	s := strconv.FormatFloat(v.Float64(), 'f', 3, 64)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
@@ -0,0 +1,47 @@
+package bench
+
+import (
+	"github.com/rayomqio/benchmq/internal/monitor"
+	"github.com/rayomqio/benchmq/internal/timeline"
+)
+
+// startMonitor begins sampling the resources of benchmq itself, so a plateau
+// can be told apart from the load generator running out of CPU. Every sample
+// also goes to the timeline, it needs to be started first.
+func (b *Bench) startMonitor() {
+	if b.monitorEvery == 0 {
+		return
+	}
+	recorder := b.timeline
+	b.monitor = monitor.New(b.monitorEvery, monitor.WithSink(func(s monitor.Sample) {
+		recorder.Sampled(timeline.Resources{
+			CPUPercent: s.CPUPercent,
+			RSSBytes:   s.RSSBytes,
+			Goroutines: s.Goroutines,
+			GCPause:    s.GCPause,
+			OpenFDs:    s.OpenFDs,
+		})
+	}))
+	b.monitor.Start()
+}
+
+// stopMonitor ends sampling before the timeline stops, so the last sample is
+// part of its last interval
+func (b *Bench) stopMonitor() {
+	if b.monitor == nil {
+		return
+	}
+	summary := b.monitor.Stop()
+	b.resourceSum = &summary
+	b.resources = b.monitor.Samples()
+	b.monitor = nil
+}
+
+// logResources logs the resource summary of the run
+func (b *Bench) logResources() {
+	if b.resourceSum == nil {
+		return
+	}
+	b.logger.Info("client resources summary", monitor.SummaryAttrs(*b.resourceSum)...)
+	b.resourceSum = nil
+}
@@ -0,0 +1,263 @@
+package bench
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/pkg/er"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// maxSources bounds the addresses a CIDR may expand to
+const maxSources = 1 << 16
+
+// source is a local IP address clients dial from
+type source struct {
+	ip net.IP
+
+	clients   atomic.Int64 // Clients assigned
+	connected atomic.Int64 // Successful connections
+	failed    atomic.Int64 // Failed connections
+}
+
+// sourcePool spreads clients over local addresses, and over a port range when
+// one is set. Each source IP gets its own ephemeral ports from the kernel, so
+// N addresses allow roughly N times the connections to one broker.
+type sourcePool struct {
+	sources []*source
+	minPort int
+	maxPort int
+	next    atomic.Uint64
+}
+
+// newSourcePool parses IPs and CIDRs, and a port range like 20000-30000 or a
+// single port. It returns nil when no address is given.
+func newSourcePool(addrs []string, ports string) (*sourcePool, error) {
+	if len(addrs) == 0 && ports == "" {
+		return nil, nil
+	}
+
+	pool := &sourcePool{}
+	for _, addr := range addrs {
+		ips, err := expandAddr(addr)
+		if err != nil {
+			return nil, &er.Error{
+				Package: "Bench",
+				Func:    "NewSourcePool",
+				Message: er.ErrInvalidLocalAddr,
+				Raw:     err,
+			}
+		}
+		for _, ip := range ips {
+			pool.sources = append(pool.sources, &source{ip: ip})
+		}
+	}
+	if len(pool.sources) > maxSources {
+		return nil, &er.Error{
+			Package: "Bench",
+			Func:    "NewSourcePool",
+			Message: er.ErrInvalidLocalAddr,
+			Raw:     fmt.Errorf("%d addresses exceed the limit of %d", len(pool.sources), maxSources),
+		}
+	}
+	// A port range alone binds the unspecified address
+	if len(pool.sources) == 0 {
+		pool.sources = []*source{{ip: net.IPv4zero}}
+	}
+
+	if ports != "" {
+		var err error
+		if pool.minPort, pool.maxPort, err = parsePortRange(ports); err != nil {
+			return nil, &er.Error{
+				Package: "Bench",
+				Func:    "NewSourcePool",
+				Message: er.ErrInvalidPortRange,
+				Raw:     err,
+			}
+		}
+	}
+	return pool, nil
+}
+
+// pick returns the next source and the local address to bind, ports are
+// handed out in order per source and run out after one pass over the range
+func (p *sourcePool) pick() (*source, *net.TCPAddr, error) {
+	n := p.next.Add(1) - 1
+	src := p.sources[n%uint64(len(p.sources))]
+	src.clients.Add(1)
+
+	addr := &net.TCPAddr{IP: src.ip}
+	if p.minPort > 0 {
+		k := n / uint64(len(p.sources))
+		if k > uint64(p.maxPort-p.minPort) {
+			return src, nil, &er.Error{
+				Package: "Bench",
+				Func:    "PickSource",
+				Message: er.ErrSourcesExhausted,
+				Raw:     er.ErrSourcesExhausted,
+			}
+		}
+		addr.Port = p.minPort + int(k)
+	}
+	return src, addr, nil
+}
+
+// expandAddr returns the IP of addr, or every host address of a CIDR
+func expandAddr(addr string) ([]net.IP, error) {
+	if !strings.Contains(addr, "/") {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP %q", addr)
+		}
+		return []net.IP{ip}, nil
+	}
+
+	prefix, err := netip.ParsePrefix(addr)
+	if err != nil {
+		return nil, err
+	}
+	prefix = prefix.Masked()
+	if bits := prefix.Addr().BitLen() - prefix.Bits(); bits > 16 {
+		return nil, fmt.Errorf("%s has more than %d addresses", addr, maxSources)
+	}
+
+	var ips []net.IP
+	for ip := prefix.Addr(); prefix.Contains(ip); ip = ip.Next() {
+		ips = append(ips, net.IP(ip.AsSlice()))
+	}
+	// The network and broadcast addresses of IPv4 subnets can't be used
+	if prefix.Addr().Is4() && prefix.Bits() < 31 {
+		ips = ips[1 : len(ips)-1]
+	}
+	return ips, nil
+}
+
+func parsePortRange(ports string) (int, int, error) {
+	lo, hi, isRange := strings.Cut(ports, "-")
+	if !isRange {
+		hi = lo
+	}
+
+	minPort, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil {
+		return 0, 0, err
+	}
+	maxPort, err := strconv.Atoi(strings.TrimSpace(hi))
+	if err != nil {
+		return 0, 0, err
+	}
+	if minPort < 1 || maxPort > 65535 || minPort > maxPort {
+		return 0, 0, fmt.Errorf("invalid port range %q", ports)
+	}
+	return minPort, maxPort, nil
+}
+
+// logSources logs the per-source-IP connection counts and the synthetic
+// PROXY protocol addresses
+func (b *Bench) logSources() {
+	if b.sources != nil {
+		for _, src := range b.sources.sources {
+			b.logger.Info("source results", sourceAttrs(src)...)
+		}
+	}
+	if b.proxySources != nil {
+		announced := b.proxySources.next.Load()
+		b.logger.Info("proxy protocol sources",
+			logger.Int("version", int(b.proxySources.version)),
+			logger.String("cidr", b.proxySources.prefix.String()),
+			logger.Any("clients", announced),
+			logger.Any("distinctAddrs", min(announced, b.proxySources.size)),
+		)
+	}
+}
+
+func sourceAttrs(src *source) []slog.Attr {
+	return []slog.Attr{
+		logger.String("localAddr", src.ip.String()),
+		logger.Any("clients", src.clients.Load()),
+		logger.Any("connected", src.connected.Load()),
+		logger.Any("failed", src.failed.Load()),
+	}
+}
+
+// proxySources hands out synthetic client addresses announced with the PROXY
+// protocol, one per client so the broker sees distinct client IPs
+type proxySources struct {
+	version byte
+	prefix  netip.Prefix
+	size    uint64 // Usable addresses in prefix
+	next    atomic.Uint64
+}
+
+// newProxySources parses the PROXY protocol version (v1 or v2) and the CIDR
+// of the synthetic addresses. It returns nil when version is empty.
+func newProxySources(version, cidr string) (*proxySources, error) {
+	if version == "" {
+		return nil, nil
+	}
+
+	ps := &proxySources{}
+	switch strings.TrimPrefix(strings.ToLower(version), "v") {
+	case "1":
+		ps.version = mqtt.ProxyProtocolV1
+	case "2":
+		ps.version = mqtt.ProxyProtocolV2
+	default:
+		return nil, &er.Error{
+			Package: "Bench",
+			Func:    "NewProxySources",
+			Message: er.ErrInvalidProxyProtocol,
+			Raw:     fmt.Errorf("unknown version %q", version),
+		}
+	}
+
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, &er.Error{
+			Package: "Bench",
+			Func:    "NewProxySources",
+			Message: er.ErrInvalidProxyProtocol,
+			Raw:     err,
+		}
+	}
+	ps.prefix = prefix.Masked()
+
+	bits := min(ps.prefix.Addr().BitLen()-ps.prefix.Bits(), 63)
+	ps.size = uint64(1) << bits
+	// Skip the network and broadcast addresses of IPv4 subnets, and the
+	// subnet-router anycast address of IPv6 ones
+	switch {
+	case ps.prefix.Addr().Is4() && bits >= 2:
+		ps.size -= 2
+	case ps.prefix.Addr().Is6() && bits >= 1:
+		ps.size--
+	}
+	return ps, nil
+}
+
+// pick returns the address announced for the next client
+func (ps *proxySources) pick() *net.TCPAddr {
+	n := ps.next.Add(1) - 1
+	offset := n % ps.size
+	if bits := ps.prefix.Addr().BitLen() - ps.prefix.Bits(); bits >= 2 || (ps.prefix.Addr().Is6() && bits >= 1) {
+		offset++
+	}
+
+	ip := ps.prefix.Addr().As16()
+	// Add the offset to the low 64 bits, the prefix keeps the high bits
+	low := binary.BigEndian.Uint64(ip[8:]) + offset
+	binary.BigEndian.PutUint64(ip[8:], low)
+	addr := netip.AddrFrom16(ip)
+	if ps.prefix.Addr().Is4() {
+		addr = addr.Unmap()
+	}
+
+	return &net.TCPAddr{IP: addr.AsSlice(), Port: 1024 + int(n%64512)}
+}
@@ -0,0 +1,109 @@
+package bench
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/sparkplug"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// Sparkplug B defaults
+const (
+	DefaultSparkplugGroup   = "benchmq"
+	DefaultSparkplugDevices = 2
+	DefaultSparkplugMetrics = 10
+)
+
+// lifecycle counts the birth and death messages publishers sent
+type lifecycle struct {
+	births atomic.Int64
+	deaths atomic.Int64
+	failed atomic.Int64
+}
+
+// sessions numbers the sessions of every edge node, the first one of a node
+// has bdSeq 0
+type sessions struct {
+	mu   sync.Mutex
+	next map[string]uint64
+}
+
+// bdSeq returns the bdSeq of the next session of node
+func (s *sessions) bdSeq(node string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next == nil {
+		s.next = make(map[string]uint64)
+	}
+	n := s.next[node]
+	s.next[node] = n + 1
+	return n
+}
+
+// newEdgeNode creates the edge node a publisher plays and the will option
+// registering its NDEATH
+func (b *Bench) newEdgeNode(clientID string) (*sparkplug.Node, mqtt.Option, error) {
+	node, err := sparkplug.NewNode(b.spGroup, clientID, b.spDevices, b.spMetrics, b.spSessions.bdSeq(clientID))
+	if err != nil {
+		return nil, nil, err
+	}
+	death := node.Death()
+	return node, mqtt.WithWill(death.Topic, death.Payload, byte(QoS1), false), nil
+}
+
+// publishLifecycle publishes birth or death messages, they aren't counted as
+// benchmark messages
+func (b *Bench) publishLifecycle(client mqtt.Client, clientID string, messages []sparkplug.Message) {
+	for _, m := range messages {
+		if err := client.Publish(m.Topic, byte(b.qos), false, m.Payload, func() {}); err != nil {
+			b.spSent.failed.Add(1)
+			b.logger.Error("failed to publish sparkplug message", logger.ClientID(clientID), logger.String("type", string(m.Type)), logger.ErrorAttr(err))
+			continue
+		}
+		switch m.Type {
+		case sparkplug.NBIRTH, sparkplug.DBIRTH:
+			b.spSent.births.Add(1)
+		default:
+			b.spSent.deaths.Add(1)
+		}
+	}
+}
+
+// publisherSparkplugAttrs returns the lifecycle messages publishers sent
+func (b *Bench) publisherSparkplugAttrs() []slog.Attr {
+	if !b.sparkplug {
+		return nil
+	}
+	return []slog.Attr{
+		logger.String("sparkplugGroup", b.spGroup),
+		logger.Any("sparkplugBirths", b.spSent.births.Load()),
+		logger.Any("sparkplugDeaths", b.spSent.deaths.Load()),
+		logger.Any("sparkplugFailed", b.spSent.failed.Load()),
+	}
+}
+
+// subscriberSparkplugAttrs sums what the validators of the subscribers saw
+func subscriberSparkplugAttrs(validators []*sparkplug.Validator) []slog.Attr {
+	if len(validators) == 0 {
+		return nil
+	}
+	var s sparkplug.Stats
+	for _, v := range validators {
+		s.Add(v.Stats())
+	}
+	return []slog.Attr{
+		logger.Any("sparkplugNodes", s.Nodes),
+		logger.Any("sparkplugBirths", s.Births),
+		logger.Any("sparkplugDeaths", s.Deaths),
+		logger.Any("sparkplugData", s.Data),
+		logger.Any("sparkplugSeqGaps", s.SeqGaps),
+		logger.Any("sparkplugSeqErrors", s.SeqErrors),
+		logger.Any("sparkplugUnborn", s.Unborn),
+		logger.Any("sparkplugBdSeqMismatches", s.BdSeqMismatches),
+		logger.Any("sparkplugDecodeErrors", s.DecodeErrors),
+		logger.Any("sparkplugTopicErrors", s.TopicErrors),
+	}
+}
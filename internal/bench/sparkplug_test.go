@@ -0,0 +1,21 @@
+package bench
+
+import "testing"
+
+func TestSessionsBdSeq(t *testing.T) {
+	var s sessions
+	for _, tt := range []struct {
+		node string
+		want uint64
+	}{
+		{"edge-0", 0},
+		{"edge-1", 0},
+		{"edge-0", 1},
+		{"edge-0", 2},
+		{"edge-1", 1},
+	} {
+		if got := s.bdSeq(tt.node); got != tt.want {
+			t.Errorf("bdSeq(%q) = %d, want %d", tt.node, got, tt.want)
+		}
+	}
+}
@@ -2,57 +2,131 @@ package bench
 
 import (
 	"fmt"
+	"log/slog"
 	"sync/atomic"
 	"time"
 
+	"github.com/rayomqio/benchmq/internal/metrics"
 	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/sparkplug"
 	"github.com/rayomqio/benchmq/pkg/logger"
 )
 
+// Subscribe subscribes every client and waits until each one received the
+// message count, stayed idle for the idle timeout or reached the deadline,
+// then unsubscribes and disconnects it
 func (b *Bench) Subscribe() {
+	if !b.begin() {
+		return
+	}
 	start := time.Now()
 	b.logger.Info("started subscribe benchmark", logger.String("start", start.Format(time.RFC3339Nano)))
 
 	var received int64
 	var failed int64
+	var valid, invalid int64 // Payloads checked against the schema
+	var ended endReasons
+
+	// Every subscriber checks the edge node lifecycles on its own
+	var validators []*sparkplug.Validator
+	if b.sparkplug {
+		validators = make([]*sparkplug.Validator, b.clients)
+	}
+	filters := b.subFilters()
+	counts := newFilterCounts(filters)
+	subscribeTime := metrics.NewHistogram()
+	if b.sparkplug && b.clientImpl == mqtt.ImplPaho {
+		b.logger.Warn("paho delivers messages concurrently, sparkplug sequence checks may report reordering, use the native client")
+	}
 
 	for i := 0; i < b.clients; i++ {
 		b.wg.Add(1)
 
+		var validator *sparkplug.Validator
+		if validators != nil {
+			validator = sparkplug.NewValidator()
+			validators[i] = validator
+		}
+
 		clientID := fmt.Sprintf("%s-%d", b.clientID, i)
 		go func(id string) {
 			defer b.wg.Done()
 
-			cfg := *b.cfg
-			cfg.Client.ClientID = id
-			cfg.Client.CleanSession = *b.cleanSession
-			cfg.Client.KeepAlive = b.keepAlive
-			cfg.Client.Username = b.username
-			cfg.Client.Password = b.password
-			client := mqtt.NewClient(&cfg)
+			client, a, err := b.newClient(id)
+			if err != nil {
+				atomic.AddInt64(&failed, 1)
+				a.failed()
+				b.timeline.Error("client")
+				b.logger.Error("failed to create client", logger.ClientID(id), logger.ErrorAttr(err))
+				return
+			}
 
-			if err := client.Connect(); err != nil {
+			if err := b.connect(client, id, a.endpoint.url.Host); err != nil {
 				atomic.AddInt64(&failed, 1)
+				a.failed()
+				b.timeline.Error("connect")
 				b.logger.Error("subscriber connection failed", logger.ClientID(id), logger.ErrorAttr(err))
 				return
 			}
-			// defer client.Disconnect()
-			b.logger.LogClientConnection(cfg.Client.ClientID)
+			a.connected()
+			b.timeline.Connected()
+			defer func() {
+				client.Disconnect()
+				b.timeline.Disconnected()
+			}()
+			b.logger.LogClientConnection(id)
 
-			if err := client.Subscribe(b.topic, byte(b.qos), b.retained, func(payload string) {
+			done := newCompletion(b.messageCount)
+			subscribed := time.Now()
+			if err := b.subscribe(client, filters, func(filter, topic string, data []byte) {
+				qos := counts.add(filter)
+				if !firstMatch(filters, filter, topic) {
+					return
+				}
+				if validator != nil {
+					if err := validator.Check(topic, data); err != nil {
+						b.timeline.Error("sparkplug")
+						b.logger.Debug("invalid sparkplug message", logger.ClientID(id), logger.String("topic", topic), logger.ErrorAttr(err))
+					}
+				}
+				payload := b.receive(id, topic, data)
+				if b.schema != nil {
+					if err := b.schema.Validate(payload); err != nil {
+						atomic.AddInt64(&invalid, 1)
+						b.timeline.Error("schema")
+						b.logger.Debug("payload does not match schema", logger.ClientID(id), logger.String("topic", topic), logger.ErrorAttr(err))
+					} else {
+						atomic.AddInt64(&valid, 1)
+					}
+				}
 				atomic.AddInt64(&received, 1)
-				b.logger.LogSubscribe(id, b.topic, int(b.qos), logger.String("payload", payload))
+				done.receive()
+				a.endpoint.messages.Add(1)
+				b.timeline.Received(0)
+				b.payloadBytes.Add(int64(len(payload)))
+				b.logger.LogSubscribe(id, topic, int(qos), payloadAttr(payload))
 			}); err != nil {
 				atomic.AddInt64(&failed, 1)
+				a.endpoint.errors.Add(1)
+				b.timeline.Error("subscribe")
 				b.logger.Error("failed to subscribe", logger.ClientID(id), logger.ErrorAttr(err))
 				return
 			}
+			subscribeTime.Record(time.Since(subscribed))
 
-			if b.delay > 0 {
-				time.Sleep(time.Duration(b.delay) * time.Millisecond * time.Duration(b.messageCount))
-			} else {
-				time.Sleep(time.Second * 5)
+			reason := b.await(done)
+			ended.add(reason)
+			for _, f := range filters {
+				if err := client.Unsubscribe(f.Topic); err != nil {
+					b.timeline.Error("unsubscribe")
+					b.logger.Warn("failed to unsubscribe", logger.ClientID(id), logger.String("filter", f.Topic), logger.ErrorAttr(err))
+				}
 			}
+			b.logger.Info("subscriber finished",
+				logger.ClientID(id),
+				logger.String("reason", string(reason)),
+				logger.Any("received", done.received.Load()),
+			)
 		}(clientID)
 	}
 
@@ -61,12 +135,33 @@ func (b *Bench) Subscribe() {
 	elapsed := time.Since(start).Seconds()
 	expected := int64(b.clients) * int64(b.messageCount)
 	throughput := float64(received) / elapsed
-	b.logger.Info("finished subscribe benchmark",
+	attrs := []slog.Attr{
 		logger.Int("clients", b.clients),
 		logger.Any("expectedMessages", expected),
 		logger.Any("received", received),
 		logger.Any("failed", failed),
 		logger.Float("elapsedSec", elapsed),
 		logger.Float("throughputMsgPerSec", throughput),
-	)
+	}
+	attrs = append(attrs, logger.Int("filters", len(filters)), logger.Bool("subscribePerFilter", b.perFilter))
+	attrs = append(attrs, latencyAttrs("subscribe", subscribeTime)...)
+	attrs = append(attrs, ended.attrs()...)
+	if b.schema != nil {
+		attrs = append(attrs, logger.Any("schemaValid", valid), logger.Any("schemaInvalid", invalid))
+	}
+	attrs = append(attrs, subscriberSparkplugAttrs(validators)...)
+	attrs = append(attrs, b.trafficAttrs(elapsed)...)
+	b.end("Subscribe benchmark", attrs)
+	b.logFilters(counts)
+}
+
+// logPayloadLimit is the payload size up to which received payloads are
+// logged, larger ones are logged by size so they aren't copied into strings
+const logPayloadLimit = 1024
+
+func payloadAttr(payload []byte) slog.Attr {
+	if len(payload) > logPayloadLimit {
+		return logger.Int("payloadSize", len(payload))
+	}
+	return logger.String("payload", string(payload))
 }
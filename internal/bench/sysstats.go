@@ -0,0 +1,89 @@
+package bench
+
+import (
+	"fmt"
+
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/sysstats"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// collector records the broker statistics of one endpoint
+type collector struct {
+	endpoint  *endpoint
+	collector *sysstats.Collector
+	series    []sysstats.Series // Set when the run ended
+}
+
+// startCollectors subscribes to the statistics of every endpoint. The
+// collector clients bypass chaos proxies and aren't counted in the results.
+// A broker that refuses the subscription only costs its statistics.
+func (b *Bench) startCollectors() {
+	if !b.sysStats {
+		return
+	}
+
+	b.collectors = nil
+	for i, ep := range b.balancer.endpoints {
+		clientID := fmt.Sprintf("%s-sys-%d", b.clientID, i)
+		cfg := b.clientConfig(clientID, node{host: b.host, port: b.port})
+		options := []mqtt.Option{mqtt.WithBroker(ep.url)}
+		if b.proxyURL != nil {
+			options = append(options, mqtt.WithProxy(b.proxyURL))
+		}
+
+		client, err := mqtt.New(b.clientImpl, &cfg, options...)
+		if err != nil {
+			b.logger.Warn("failed to create broker stats client", logger.String("endpoint", ep.url.Redacted()), logger.ErrorAttr(err))
+			continue
+		}
+		c := sysstats.New(client, b.sysFilter)
+		if err := c.Start(); err != nil {
+			b.logger.Warn("broker stats unavailable", logger.String("endpoint", ep.url.Redacted()), logger.String("filter", b.sysFilter), logger.ErrorAttr(err))
+			continue
+		}
+		b.collectors = append(b.collectors, &collector{endpoint: ep, collector: c})
+	}
+}
+
+// logSysStats stops the collectors and logs a summary line per statistic
+func (b *Bench) logSysStats() {
+	for _, c := range b.collectors {
+		c.series = c.collector.Stop()
+		messages, skipped := c.collector.Counts()
+		endpoint := c.endpoint.url.Redacted()
+
+		if len(c.series) == 0 {
+			b.logger.Info("no broker stats received",
+				logger.String("endpoint", endpoint),
+				logger.String("filter", b.sysFilter),
+				logger.Any("messages", messages),
+			)
+			continue
+		}
+
+		for _, s := range c.series {
+			first, last := s.Points[0].Value, s.Points[len(s.Points)-1].Value
+			lo, hi := first, first
+			for _, p := range s.Points {
+				lo, hi = min(lo, p.Value), max(hi, p.Value)
+			}
+			b.logger.Info("broker stats",
+				logger.String("endpoint", endpoint),
+				logger.String("topic", s.Topic),
+				logger.Int("samples", len(s.Points)),
+				logger.Float("first", first),
+				logger.Float("last", last),
+				logger.Float("min", lo),
+				logger.Float("max", hi),
+				logger.Float("delta", last-first),
+			)
+		}
+		b.logger.Info("broker stats summary",
+			logger.String("endpoint", endpoint),
+			logger.Int("series", len(c.series)),
+			logger.Any("messages", messages),
+			logger.Any("nonNumeric", skipped),
+		)
+	}
+}
@@ -0,0 +1,86 @@
+package bench
+
+import (
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/tracing"
+)
+
+// connect connects client and records a connect span when tracing is enabled
+func (b *Bench) connect(client mqtt.Client, clientID, server string) error {
+	span := b.tracer.Start("connect", tracing.KindClient, tracing.SpanContext{})
+	span.SetAttr("messaging.system", "mqtt")
+	span.SetAttr("messaging.client.id", clientID)
+	span.SetAttr("server.address", server)
+	err := client.Connect()
+	span.End(err)
+	return err
+}
+
+// startTracing creates the tracer of the run when tracing is enabled
+func (b *Bench) startTracing() error {
+	var err error
+	b.tracer, err = tracing.New(
+		tracing.WithOTLPEndpoint(b.otlpEndpoint),
+		tracing.WithFile(b.traceFile),
+		tracing.WithSampleRatio(b.traceSample),
+		tracing.WithService(b.traceService),
+	)
+	return err
+}
+
+// startPublish begins the publish span of a message and returns the payload
+// to send. Sampled messages carry the span context in an envelope when trace
+// context is enabled, unless the payload is Sparkplug B protobuf.
+func (b *Bench) startPublish(clientID, topic string, msg []byte) (*tracing.Span, []byte) {
+	span := b.tracer.Start("publish "+topic, tracing.KindProducer, tracing.SpanContext{})
+	if span == nil {
+		return nil, msg
+	}
+	span.SetAttr("messaging.system", "mqtt")
+	span.SetAttr("messaging.operation.type", "send")
+	span.SetAttr("messaging.destination.name", topic)
+	span.SetAttr("messaging.client.id", clientID)
+	span.SetAttr("messaging.message.body.size", len(msg))
+	span.SetAttr("messaging.mqtt.qos", int(b.qos))
+	if !b.traceCarry || b.sparkplug {
+		return span, msg
+	}
+	return span, tracing.Wrap(span.Context(), msg)
+}
+
+// startAck begins the span waiting for the acknowledgement of a publish, QoS
+// 0 messages are not acknowledged and get none
+func (b *Bench) startAck(publish *tracing.Span) *tracing.Span {
+	if publish == nil || b.qos == QoS0 {
+		return nil
+	}
+	ack := b.tracer.Start("ack", tracing.KindInternal, publish.Context())
+	ack.SetAttr("messaging.mqtt.qos", int(b.qos))
+	return ack
+}
+
+// receive strips the trace envelope of a received payload and records a
+// receive span as a child of the publish span that sent it
+func (b *Bench) receive(clientID, topic string, payload []byte) []byte {
+	if b.tracer == nil {
+		return payload
+	}
+	parent, body, ok := tracing.Unwrap(payload)
+	if !ok {
+		return payload
+	}
+	span := b.tracer.Start("receive "+topic, tracing.KindConsumer, parent)
+	span.SetAttr("messaging.system", "mqtt")
+	span.SetAttr("messaging.operation.type", "receive")
+	span.SetAttr("messaging.destination.name", topic)
+	span.SetAttr("messaging.client.id", clientID)
+	span.SetAttr("messaging.message.body.size", len(body))
+	span.End(nil)
+	return body
+}
+
+// stopTracing exports the remaining spans
+func (b *Bench) stopTracing() {
+	b.tracer.Close()
+	b.tracer = nil
+}
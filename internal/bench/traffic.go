@@ -0,0 +1,34 @@
+package bench
+
+import (
+	"log/slog"
+
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// trafficAttrs returns the byte throughput and wire-level traffic of a
+// finished run. The overhead ratio is the total wire bytes divided by the
+// payload bytes, so 1.0 means no protocol overhead at all.
+func (b *Bench) trafficAttrs(elapsed float64) []slog.Attr {
+	payloadBytes := b.payloadBytes.Load()
+	sent := b.traffic.Sent()
+	received := b.traffic.Received()
+
+	var payloadRate, wireRate, overhead float64
+	if elapsed > 0 {
+		payloadRate = float64(payloadBytes) / elapsed
+		wireRate = float64(sent+received) / elapsed
+	}
+	if payloadBytes > 0 {
+		overhead = float64(sent+received) / float64(payloadBytes)
+	}
+
+	return []slog.Attr{
+		logger.Any("payloadBytes", payloadBytes),
+		logger.Float("payloadBytesPerSec", payloadRate),
+		logger.Any("wireBytesSent", sent),
+		logger.Any("wireBytesReceived", received),
+		logger.Float("wireBytesPerSec", wireRate),
+		logger.Float("overheadRatio", overhead),
+	}
+}
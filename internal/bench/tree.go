@@ -0,0 +1,438 @@
+package bench
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/metrics"
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// Topic tree defaults
+const (
+	DefaultTreeDepth         = 4
+	DefaultTreeFanout        = 10
+	DefaultTreeSubscriptions = 10000
+	DefaultTreeSteps         = 5
+	DefaultTreeWildcards     = 0.2
+	DefaultTreePublishers    = 4
+)
+
+// subscribeBatch is the most filters sent in one SUBSCRIBE while the tree grows
+const subscribeBatch = 100
+
+// treeProbeSize is the length of a topic tree probe: send time in unix
+// nanoseconds and the step it was published in
+const treeProbeSize = 8 + 4
+
+// TopicTree describes the topic hierarchy of the topic tree benchmark. Leaf
+// topics are the topic followed by one level per depth, each level numbered
+// from 0 to fanout-1.
+type TopicTree struct {
+	Depth         int     // Levels below the topic
+	Fanout        int     // Children per level
+	Leaves        int     // Leaf topics used, all fanout^depth when zero
+	Subscriptions int     // Subscriptions after the last step
+	Steps         int     // Steps the subscriptions grow in
+	Wildcards     float64 // Share of subscriptions with a + or # wildcard
+	Publishers    int     // Clients publishing to the leaves
+}
+
+// leaves returns the number of leaf topics in use
+func (t TopicTree) leaves() int {
+	all := math.Pow(float64(t.Fanout), float64(t.Depth))
+	if t.Leaves > 0 && float64(t.Leaves) <= all {
+		return t.Leaves
+	}
+	return int(min(all, math.MaxInt32))
+}
+
+// leaf returns the levels of leaf k
+func (t TopicTree) leaf(k int) []int {
+	levels := make([]int, t.Depth)
+	for i := t.Depth - 1; i >= 0; i-- {
+		levels[i] = k % t.Fanout
+		k /= t.Fanout
+	}
+	return levels
+}
+
+// leafTopic returns the topic of leaf k under prefix
+func (t TopicTree) leafTopic(prefix string, k int) string {
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	for _, level := range t.leaf(k) {
+		sb.WriteByte('/')
+		sb.WriteString(strconv.Itoa(level))
+	}
+	return sb.String()
+}
+
+// filter returns subscription i, an exact leaf topic or, for the wildcard
+// share, a leaf with one level replaced by + or its last level by #. Either
+// wildcard matches fanout leaves.
+func (t TopicTree) filter(prefix string, i int) (string, bool) {
+	levels := t.leaf(i % t.leaves())
+	// w counts the wildcard subscriptions up to i, + and # alternate
+	w := int(float64(i+1) * t.Wildcards)
+	wildcard := w > int(float64(i)*t.Wildcards)
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	for l, level := range levels {
+		sb.WriteByte('/')
+		switch {
+		case wildcard && w%2 == 1 && l == w/2%t.Depth:
+			sb.WriteByte('+')
+		case wildcard && w%2 == 0 && l == t.Depth-1:
+			sb.WriteByte('#')
+		default:
+			sb.WriteString(strconv.Itoa(level))
+		}
+	}
+	return sb.String(), wildcard
+}
+
+// treeStep collects the results of one step of the growing tree
+type treeStep struct {
+	index       int
+	subscribed  int // Subscriptions held during the step
+	wildcards   int
+	suback      *metrics.Histogram
+	subFailed   atomic.Int64
+	published   atomic.Int64
+	pubFailed   atomic.Int64
+	pubStart    time.Time
+	pubElapsed  time.Duration
+	deliveries  atomic.Int64
+	lastArrival atomic.Int64 // Unix nanoseconds of the last delivery
+	latency     *metrics.Histogram
+}
+
+func (s *treeStep) deliver(latency time.Duration) {
+	s.deliveries.Add(1)
+	s.lastArrival.Store(time.Now().UnixNano())
+	s.latency.Record(latency)
+}
+
+// publishRate returns the messages published per second
+func (s *treeStep) publishRate() float64 {
+	if s.pubElapsed <= 0 {
+		return 0
+	}
+	return float64(s.published.Load()) / s.pubElapsed.Seconds()
+}
+
+// deliveryRate returns the messages delivered per second from the first
+// publish to the last delivery
+func (s *treeStep) deliveryRate() float64 {
+	last := s.lastArrival.Load()
+	if last == 0 {
+		return 0
+	}
+	return float64(s.deliveries.Load()) / time.Unix(0, last).Sub(s.pubStart).Seconds()
+}
+
+func (s *treeStep) attrs(first *treeStep) []slog.Attr {
+	attrs := []slog.Attr{
+		logger.Int("step", s.index),
+		logger.Int("subscriptions", s.subscribed),
+		logger.Int("wildcards", s.wildcards),
+		logger.Any("subscribeFailed", s.subFailed.Load()),
+	}
+	attrs = append(attrs, latencyAttrs("suback", s.suback)...)
+	attrs = append(attrs,
+		logger.Any("published", s.published.Load()),
+		logger.Any("publishFailed", s.pubFailed.Load()),
+		logger.Float("publishMsgPerSec", s.publishRate()),
+		logger.Any("deliveries", s.deliveries.Load()),
+		logger.Float("deliveryMsgPerSec", s.deliveryRate()),
+	)
+	attrs = append(attrs, latencyAttrs("match", s.latency)...)
+
+	// Degradation relative to the step with the fewest subscriptions
+	if first != nil && first != s {
+		if rate := first.publishRate(); rate > 0 {
+			attrs = append(attrs, logger.Float("publishRatePct", s.publishRate()/rate*100))
+		}
+		if p99 := first.latency.Percentile(99); p99 > 0 {
+			attrs = append(attrs, logger.Float("matchP99Ratio", float64(s.latency.Percentile(99))/float64(p99)))
+		}
+	}
+	return attrs
+}
+
+func encodeTreeProbe(step int) []byte {
+	buf := make([]byte, 0, treeProbeSize)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(time.Now().UnixNano()))
+	return binary.BigEndian.AppendUint32(buf, uint32(step))
+}
+
+func decodeTreeProbe(payload []byte) (sent time.Time, step int, ok bool) {
+	if len(payload) != treeProbeSize {
+		return time.Time{}, 0, false
+	}
+	sent = time.Unix(0, int64(binary.BigEndian.Uint64(payload)))
+	return sent, int(binary.BigEndian.Uint32(payload[8:])), true
+}
+
+// treeSubscriber is a subscriber of the topic tree and the filters it holds
+type treeSubscriber struct {
+	client  mqtt.Client
+	seen    map[string]bool
+	filters atomic.Pointer[[]mqtt.Filter] // Replaced as a whole, deliveries read it
+}
+
+// add records the filters of a batch before it is sent, deliveries can arrive
+// before the SUBACK
+func (s *treeSubscriber) add(batch []mqtt.Filter) {
+	held := *s.filters.Load()
+	held = append(held[:len(held):len(held)], batch...)
+	s.filters.Store(&held)
+}
+
+// remove forgets the filters of a batch the broker didn't acknowledge
+func (s *treeSubscriber) remove(batch []mqtt.Filter) {
+	failed := make(map[string]bool, len(batch))
+	for _, f := range batch {
+		failed[f.Topic] = true
+	}
+	var held []mqtt.Filter
+	for _, f := range *s.filters.Load() {
+		if !failed[f.Topic] {
+			held = append(held, f)
+		}
+	}
+	s.filters.Store(&held)
+}
+
+// RunTopicTree grows subscriptions over a topic hierarchy in steps and after
+// each step publishes to its leaves, measuring SUBACK latency, publish
+// throughput and match latency from publish to delivery as the number of
+// subscriptions grows
+func (b *Bench) RunTopicTree() {
+	if !b.begin() {
+		return
+	}
+	start := time.Now()
+	t := b.tree
+	leaves := t.leaves()
+	b.logger.Info("started topic tree benchmark",
+		logger.String("start", start.Format(time.RFC3339Nano)),
+		logger.Int("depth", t.Depth),
+		logger.Int("fanout", t.Fanout),
+		logger.Int("leaves", leaves),
+		logger.Int("subscriptions", t.Subscriptions),
+		logger.Int("steps", t.Steps),
+		logger.Float("wildcards", t.Wildcards),
+	)
+
+	// current is the step deliveries are recorded in, probes of earlier
+	// steps arriving late are ignored
+	var current atomic.Pointer[treeStep]
+	deliver := func(sub *treeSubscriber, filter, topic string, payload []byte) {
+		// A probe matching several filters of a subscriber counts once
+		if !firstMatch(*sub.filters.Load(), filter, topic) {
+			return
+		}
+		sent, index, ok := decodeTreeProbe(payload)
+		step := current.Load()
+		if !ok || step == nil || index != step.index {
+			return
+		}
+		latency := time.Since(sent)
+		step.deliver(latency)
+		b.timeline.Received(latency)
+	}
+
+	var subscribers []*treeSubscriber
+	for _, client := range b.connectClients("sub", b.clients) {
+		sub := &treeSubscriber{client: client, seen: make(map[string]bool)}
+		sub.filters.Store(&[]mqtt.Filter{})
+		subscribers = append(subscribers, sub)
+	}
+	publishers := b.connectClients("pub", t.Publishers)
+
+	var steps []*treeStep
+	var next, subscribed, wildcards int
+	for s := 1; s <= t.Steps && len(subscribers) > 0 && len(publishers) > 0; s++ {
+		if b.Stopped() {
+			break
+		}
+		step := &treeStep{index: s, suback: metrics.NewHistogram(), latency: metrics.NewHistogram()}
+
+		// Spread the new subscriptions over the subscribers round-robin, a
+		// filter a subscriber already holds isn't subscribed again
+		target := t.Subscriptions * s / t.Steps
+		added := make([][]mqtt.Filter, len(subscribers))
+		for ; next < target; next++ {
+			filter, wildcard := t.filter(b.topic, next)
+			c := next % len(subscribers)
+			if subscribers[c].seen[filter] {
+				continue
+			}
+			subscribers[c].seen[filter] = true
+			if wildcard {
+				wildcards++
+			}
+			subscribed++
+			added[c] = append(added[c], mqtt.Filter{Topic: filter, QoS: byte(b.qos)})
+		}
+		step.subscribed, step.wildcards = subscribed, wildcards
+		b.growTree(subscribers, added, step, deliver)
+
+		current.Store(step)
+		b.publishTree(publishers, leaves, step)
+
+		// Give the broker time to deliver the last probes
+		b.sleep(b.drain)
+		current.Store(nil)
+
+		steps = append(steps, step)
+		b.logger.Info("topic tree step", step.attrs(steps[0])...)
+	}
+
+	for _, sub := range subscribers {
+		sub.client.Disconnect()
+		b.timeline.Disconnected()
+	}
+	for _, client := range publishers {
+		client.Disconnect()
+		b.timeline.Disconnected()
+	}
+
+	elapsed := time.Since(start).Seconds()
+	attrs := []slog.Attr{
+		logger.Int("depth", t.Depth),
+		logger.Int("fanout", t.Fanout),
+		logger.Int("leaves", leaves),
+		logger.Int("subscribers", len(subscribers)),
+		logger.Int("publishers", len(publishers)),
+		logger.Int("subscriptions", subscribed),
+		logger.Int("wildcardSubscriptions", wildcards),
+		logger.Int("steps", len(steps)),
+		logger.Float("elapsedSec", elapsed),
+	}
+	if len(steps) > 1 {
+		first, last := steps[0], steps[len(steps)-1]
+		if rate := first.publishRate(); rate > 0 {
+			attrs = append(attrs, logger.Float("publishRatePct", last.publishRate()/rate*100))
+		}
+		if p99 := first.latency.Percentile(99); p99 > 0 {
+			attrs = append(attrs, logger.Float("matchP99Ratio", float64(last.latency.Percentile(99))/float64(p99)))
+		}
+	}
+	attrs = append(attrs, b.trafficAttrs(elapsed)...)
+	b.end("Topic tree benchmark", attrs)
+}
+
+// connectClients connects n clients of role, the ones that failed are left out
+func (b *Bench) connectClients(role string, n int) []mqtt.Client {
+	clients := make([]mqtt.Client, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("%s-%s-%d", b.clientID, role, i)
+			client, a, err := b.newClient(id)
+			if err != nil {
+				a.failed()
+				b.timeline.Error("client")
+				b.logger.Error("failed to create client", logger.ClientID(id), logger.ErrorAttr(err))
+				return
+			}
+			if err := b.connect(client, id, a.endpoint.url.Host); err != nil {
+				a.failed()
+				b.timeline.Error("connect")
+				b.logger.Error("couldn't establish client", logger.ClientID(id), logger.ErrorAttr(err))
+				return
+			}
+			a.connected()
+			b.timeline.Connected()
+			b.logger.LogClientConnection(id)
+			clients[i] = client
+		}()
+	}
+	wg.Wait()
+
+	connected := clients[:0]
+	for _, client := range clients {
+		if client != nil {
+			connected = append(connected, client)
+		}
+	}
+	return connected
+}
+
+// growTree subscribes every subscriber to its added filters in batches of
+// subscribeBatch, recording the SUBACK latency of every batch
+func (b *Bench) growTree(subscribers []*treeSubscriber, added [][]mqtt.Filter, step *treeStep, deliver func(sub *treeSubscriber, filter, topic string, payload []byte)) {
+	var wg sync.WaitGroup
+	for c, sub := range subscribers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callback := func(filter, topic string, payload []byte) {
+				deliver(sub, filter, topic, payload)
+			}
+			filters := added[c]
+			for len(filters) > 0 && !b.Stopped() {
+				batch := filters[:min(subscribeBatch, len(filters))]
+				filters = filters[len(batch):]
+
+				sub.add(batch)
+				sent := time.Now()
+				if err := sub.client.SubscribeFilters(batch, callback); err != nil {
+					sub.remove(batch)
+					step.subFailed.Add(int64(len(batch)))
+					b.timeline.Error("subscribe")
+					b.logger.Debug("failed to subscribe", logger.ErrorAttr(err))
+					continue
+				}
+				step.suback.Record(time.Since(sent))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// publishTree publishes messageCount probes from every publisher, spread
+// over the leaves
+func (b *Bench) publishTree(publishers []mqtt.Client, leaves int, step *treeStep) {
+	step.pubStart = time.Now()
+	var wg sync.WaitGroup
+	for p, client := range publishers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < b.messageCount; j++ {
+				if !b.pause() {
+					return
+				}
+				topic := b.tree.leafTopic(b.topic, (p+j*len(publishers))%leaves)
+				sent := time.Now()
+				err := client.Publish(topic, byte(b.qos), false, encodeTreeProbe(step.index), func() {})
+				if err != nil {
+					step.pubFailed.Add(1)
+					b.timeline.Error("publish")
+					b.logger.Debug("failed to publish message", logger.String("topic", topic), logger.ErrorAttr(err))
+					continue
+				}
+				step.published.Add(1)
+				b.timeline.Sent(time.Since(sent))
+				b.payloadBytes.Add(treeProbeSize)
+			}
+		}()
+	}
+	wg.Wait()
+	step.pubElapsed = time.Since(step.pubStart)
+}
@@ -0,0 +1,149 @@
+package bench
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/mqtt/mqtttest"
+	"github.com/rayomqio/benchmq/pkg/config"
+)
+
+func TestTreeLeaves(t *testing.T) {
+	for _, tt := range []struct {
+		tree TopicTree
+		want int
+	}{
+		{TopicTree{Depth: 3, Fanout: 10}, 1000},
+		{TopicTree{Depth: 3, Fanout: 10, Leaves: 50}, 50},
+		{TopicTree{Depth: 2, Fanout: 4, Leaves: 100}, 16}, // More leaves than the tree has
+		{TopicTree{Depth: 20, Fanout: 10}, 1<<31 - 1},
+	} {
+		if got := tt.tree.leaves(); got != tt.want {
+			t.Errorf("%+v leaves = %d, want %d", tt.tree, got, tt.want)
+		}
+	}
+}
+
+func TestTreeLeafTopic(t *testing.T) {
+	tree := TopicTree{Depth: 3, Fanout: 10}
+	for k, want := range map[int]string{
+		0:   "bench/tree/0/0/0",
+		7:   "bench/tree/0/0/7",
+		307: "bench/tree/3/0/7",
+		999: "bench/tree/9/9/9",
+	} {
+		if got := tree.leafTopic("bench/tree", k); got != want {
+			t.Errorf("leafTopic(%d) = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestTreeFilter(t *testing.T) {
+	tree := TopicTree{Depth: 3, Fanout: 4, Wildcards: 0.25}
+	const n = 400
+
+	var wildcards int
+	for i := range n {
+		filter, wildcard := tree.filter("t", i)
+		if !wildcard {
+			if want := tree.leafTopic("t", i%tree.leaves()); filter != want {
+				t.Errorf("filter(%d) = %q, want the leaf %q", i, filter, want)
+			}
+			continue
+		}
+		wildcards++
+
+		// Either wildcard matches fanout leaves, including leaf i
+		var matched int
+		for k := range tree.leaves() {
+			if mqtt.TopicMatch(filter, tree.leafTopic("t", k)) {
+				matched++
+			}
+		}
+		if matched != tree.Fanout {
+			t.Errorf("filter(%d) = %q matches %d leaves, want %d", i, filter, matched, tree.Fanout)
+		}
+		if !mqtt.TopicMatch(filter, tree.leafTopic("t", i%tree.leaves())) {
+			t.Errorf("filter(%d) = %q doesn't match its leaf", i, filter)
+		}
+	}
+	if want := int(n * tree.Wildcards); wildcards != want {
+		t.Errorf("%d wildcard filters, want %d", wildcards, want)
+	}
+}
+
+func TestTreeProbe(t *testing.T) {
+	before := time.Now()
+	sent, step, ok := decodeTreeProbe(encodeTreeProbe(7))
+	if !ok || step != 7 || sent.Before(before) || sent.After(time.Now()) {
+		t.Errorf("decodeTreeProbe = %s, %d, %v, want now, 7, true", sent, step, ok)
+	}
+	if _, _, ok := decodeTreeProbe([]byte("short")); ok {
+		t.Error("decodeTreeProbe of a short payload succeeded")
+	}
+}
+
+func TestTreeSubscriberFilters(t *testing.T) {
+	sub := &treeSubscriber{}
+	sub.filters.Store(&[]mqtt.Filter{})
+
+	first := []mqtt.Filter{{Topic: "a/+"}, {Topic: "a/1"}}
+	sub.add(first)
+	held := *sub.filters.Load()
+	sub.add([]mqtt.Filter{{Topic: "b/#"}})
+	// Deliveries may still read the earlier slice
+	if len(held) != 2 {
+		t.Errorf("earlier filters changed to %v", held)
+	}
+
+	sub.remove(first)
+	if got := *sub.filters.Load(); !slices.Equal(got, []mqtt.Filter{{Topic: "b/#"}}) {
+		t.Errorf("filters = %v, want [b/#]", got)
+	}
+}
+
+func TestRunTopicTree(t *testing.T) {
+	broker := mqtttest.NewBroker(t)
+	cfg := &config.Config{}
+	cfg.Server.Host = broker.Host()
+	cfg.Server.Port = broker.Port()
+
+	tree := TopicTree{Depth: 2, Fanout: 3, Subscriptions: 12, Steps: 3, Wildcards: 0.25, Publishers: 2}
+	b, err := NewBenchmark(cfg,
+		WithClientImpl(mqtt.ImplNative),
+		WithClients(2),
+		WithMessageCount(5),
+		WithDelay(0),
+		WithDrain(50*time.Millisecond),
+		WithTopic("tree"),
+		WithTopicTree(tree),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.RunTopicTree()
+	if err := b.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(map[string]any)
+	for _, attr := range b.Results() {
+		results[attr.Key] = attr.Value.Any()
+	}
+	for key, want := range map[string]any{
+		"subscribers":           int64(2),
+		"publishers":            int64(2),
+		"subscriptions":         int64(12),
+		"wildcardSubscriptions": int64(3),
+		"steps":                 int64(3),
+	} {
+		if results[key] != want {
+			t.Errorf("%s = %v (%T), want %v", key, results[key], results[key], want)
+		}
+	}
+	if got, want := broker.Published(), tree.Steps*tree.Publishers*5; got != want {
+		t.Errorf("broker received %d probes, want %d", got, want)
+	}
+}
@@ -0,0 +1,212 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// Proxy is a TCP proxy that degrades the link between clients and a broker.
+// Every accepted connection is forwarded to the upstream address with added
+// latency and jitter, a bandwidth limit per direction, and random resets and
+// stalls.
+type Proxy struct {
+	listen        string
+	upstream      string
+	latency       time.Duration
+	jitter        time.Duration
+	bandwidth     int64 // Bytes per second per direction, 0 is unlimited
+	resetProb     float64
+	stallProb     float64
+	stallDuration time.Duration
+
+	listener net.Listener
+	conns    sync.Map // Open connection pairs, closed with the proxy
+	closed   atomic.Bool
+	ctx      context.Context // Cancelled by Close to abort upstream dials
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stats    counters
+	logger   *logger.Logger
+}
+
+// Stats are the totals of a proxy since it started
+type Stats struct {
+	Connections int64 // Accepted client connections
+	Active      int64 // Currently open connections
+	Resets      int64 // Connections reset by fault injection
+	Stalls      int64 // Stalls injected
+	BytesUp     int64 // Bytes forwarded from clients to the broker
+	BytesDown   int64 // Bytes forwarded from the broker to clients
+}
+
+type counters struct {
+	connections atomic.Int64
+	active      atomic.Int64
+	resets      atomic.Int64
+	stalls      atomic.Int64
+	bytesUp     atomic.Int64
+	bytesDown   atomic.Int64
+}
+
+type Option func(*Proxy)
+
+const (
+	DefaultListen        = ":1884"         // Default proxy listen address
+	DefaultStallDuration = 2 * time.Second // Default length of an injected stall
+	dialTimeout          = 10 * time.Second
+)
+
+// New creates a proxy forwarding connections accepted on listen to upstream
+func New(listen, upstream string, options ...Option) (*Proxy, error) {
+	p := &Proxy{
+		listen:        listen,
+		upstream:      upstream,
+		stallDuration: DefaultStallDuration,
+		logger:        logger.NewBenchmarkLogger("chaos"),
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(p)
+		}
+	}
+
+	if p.listen == "" || p.upstream == "" || p.latency < 0 || p.jitter < 0 || p.bandwidth < 0 ||
+		p.resetProb < 0 || p.resetProb > 1 || p.stallProb < 0 || p.stallProb > 1 || p.stallDuration < 0 {
+		return nil, &er.Error{
+			Package: "Chaos",
+			Func:    "New",
+			Message: er.ErrInvalidChaos,
+			Raw:     er.ErrInvalidChaos,
+		}
+	}
+
+	return p, nil
+}
+
+// Start opens the listener and accepts connections in the background
+func (p *Proxy) Start() error {
+	listener, err := net.Listen("tcp", p.listen)
+	if err != nil {
+		return &er.Error{
+			Package: "Chaos",
+			Func:    "Start",
+			Message: er.ErrListenFailed,
+			Raw:     err,
+		}
+	}
+	p.listener = listener
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	p.wg.Add(1)
+	go p.serve()
+	return nil
+}
+
+// Addr returns the address the proxy listens on
+func (p *Proxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Close stops accepting connections and closes the open ones
+func (p *Proxy) Close() error {
+	p.closed.Store(true)
+	p.cancel()
+	err := p.listener.Close()
+	p.conns.Range(func(key, _ any) bool {
+		_ = key.(net.Conn).Close()
+		return true
+	})
+	p.wg.Wait()
+	return err
+}
+
+// Stats returns the current totals
+func (p *Proxy) Stats() Stats {
+	return Stats{
+		Connections: p.stats.connections.Load(),
+		Active:      p.stats.active.Load(),
+		Resets:      p.stats.resets.Load(),
+		Stalls:      p.stats.stalls.Load(),
+		BytesUp:     p.stats.bytesUp.Load(),
+		BytesDown:   p.stats.bytesDown.Load(),
+	}
+}
+
+// track registers conn so Close closes it, a conn registered while the
+// proxy closes is closed right away and track returns false
+func (p *Proxy) track(conn net.Conn) bool {
+	p.conns.Store(conn, struct{}{})
+	if p.closed.Load() {
+		_ = conn.Close()
+		return false
+	}
+	return true
+}
+
+func (p *Proxy) serve() {
+	defer p.wg.Done()
+
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				p.logger.Error("failed to accept connection", logger.ErrorAttr(err))
+			}
+			return
+		}
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.handle(client)
+		}()
+	}
+}
+
+func WithLatency(latency time.Duration) Option {
+	return func(p *Proxy) {
+		p.latency = latency
+	}
+}
+
+func WithJitter(jitter time.Duration) Option {
+	return func(p *Proxy) {
+		p.jitter = jitter
+	}
+}
+
+// WithBandwidth limits each direction of every connection to bytesPerSec
+func WithBandwidth(bytesPerSec int64) Option {
+	return func(p *Proxy) {
+		p.bandwidth = bytesPerSec
+	}
+}
+
+// WithResetProb sets the probability per second that a connection is reset
+func WithResetProb(prob float64) Option {
+	return func(p *Proxy) {
+		p.resetProb = prob
+	}
+}
+
+// WithStallProb sets the probability per second that a connection stops
+// forwarding for the stall duration
+func WithStallProb(prob float64) Option {
+	return func(p *Proxy) {
+		p.stallProb = prob
+	}
+}
+
+func WithStallDuration(duration time.Duration) Option {
+	return func(p *Proxy) {
+		p.stallDuration = duration
+	}
+}
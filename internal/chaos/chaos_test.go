@@ -0,0 +1,171 @@
+package chaos
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// startEcho starts an upstream server echoing every connection
+func startEcho(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// startProxy starts a proxy in front of upstream and connects a client to it
+func startProxy(t *testing.T, upstream string, options ...Option) (*Proxy, net.Conn) {
+	t.Helper()
+	p, err := New("127.0.0.1:0", upstream, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+
+	conn, err := net.Dial("tcp", p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return p, conn
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		listen   string
+		upstream string
+		option   Option
+	}{
+		{"no listen address", "", "127.0.0.1:1883", nil},
+		{"no upstream", ":1884", "", nil},
+		{"negative latency", ":1884", "127.0.0.1:1883", WithLatency(-time.Second)},
+		{"negative bandwidth", ":1884", "127.0.0.1:1883", WithBandwidth(-1)},
+		{"reset above 1", ":1884", "127.0.0.1:1883", WithResetProb(1.5)},
+		{"negative stall", ":1884", "127.0.0.1:1883", WithStallProb(-0.1)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.listen, tt.upstream, tt.option); err == nil {
+				t.Error("New succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestProxyLatency(t *testing.T) {
+	const latency = 50 * time.Millisecond
+	p, conn := startProxy(t, startEcho(t), WithLatency(latency))
+
+	start := time.Now()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	// Both directions are delayed
+	if rtt := time.Since(start); rtt < 2*latency {
+		t.Errorf("round trip = %s, want at least %s", rtt, 2*latency)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("echo = %q, want ping", buf)
+	}
+
+	// Bytes are counted once the write returned, the client may read first
+	want := Stats{Connections: 1, Active: 1, BytesUp: 4, BytesDown: 4}
+	deadline := time.Now().Add(time.Second)
+	for p.Stats() != want && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if st := p.Stats(); st != want {
+		t.Errorf("stats = %+v, want %+v", st, want)
+	}
+}
+
+func TestProxyBandwidth(t *testing.T) {
+	const bandwidth = 256 << 10
+	_, conn := startProxy(t, startEcho(t), WithBandwidth(bandwidth))
+
+	// At least three chunks, the last waits for the first two to be paced
+	data := bytes.Repeat([]byte("x"), 3*chunkSize)
+	start := time.Now()
+	go func() { _, _ = conn.Write(data) }()
+	if _, err := io.ReadFull(conn, make([]byte, len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed, want := time.Since(start), time.Duration(2*chunkSize)*time.Second/bandwidth; elapsed < want {
+		t.Errorf("transfer took %s, want at least %s", elapsed, want)
+	}
+}
+
+func TestProxyReset(t *testing.T) {
+	p, conn := startProxy(t, startEcho(t), WithResetProb(1))
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * faultInterval))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("read succeeded, want the connection reset")
+	}
+	if resets := p.Stats().Resets; resets != 1 {
+		t.Errorf("resets = %d, want 1", resets)
+	}
+}
+
+func TestProxyClose(t *testing.T) {
+	p, conn := startProxy(t, startEcho(t))
+	if _, err := conn.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("read succeeded after Close, want the connection closed")
+	}
+	if active := p.Stats().Active; active != 0 {
+		t.Errorf("active = %d after Close, want 0", active)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	p := &Proxy{latency: 20 * time.Millisecond, jitter: 10 * time.Millisecond}
+	for range 1000 {
+		if d := p.delay(); d < 10*time.Millisecond || d > 30*time.Millisecond {
+			t.Fatalf("delay = %s, want within 10ms..30ms", d)
+		}
+	}
+
+	// Jitter larger than the latency never yields a negative delay
+	p = &Proxy{latency: time.Millisecond, jitter: 10 * time.Millisecond}
+	for range 1000 {
+		if d := p.delay(); d < 0 {
+			t.Fatalf("delay = %s, want >= 0", d)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package chaos
+
+import (
+	"context"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+const (
+	// chunkSize is the largest piece of data delayed as a unit
+	chunkSize = 32 * 1024
+	// queueSize bounds the delayed chunks per direction, a full queue
+	// applies backpressure to the sender
+	queueSize = 256
+	// faultInterval is how often resets and stalls are rolled
+	faultInterval = time.Second
+)
+
+// link is a client connection and its upstream connection
+type link struct {
+	client   net.Conn
+	upstream net.Conn
+	stalled  atomic.Int64 // Unix nanoseconds until which forwarding pauses
+	done     chan struct{}
+}
+
+// chunk is data read from one side, due for delivery at a point in time
+type chunk struct {
+	data []byte
+	due  time.Time
+}
+
+// handle forwards one client connection until either side closes it
+func (p *Proxy) handle(client net.Conn) {
+	p.stats.connections.Add(1)
+	p.stats.active.Add(1)
+	defer p.stats.active.Add(-1)
+
+	// The client is registered before dialing, so Close never waits on a
+	// connection it can't see
+	defer p.conns.Delete(client)
+	if !p.track(client) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, dialTimeout)
+	upstream, err := (&net.Dialer{}).DialContext(ctx, "tcp", p.upstream)
+	cancel()
+	if err != nil {
+		_ = client.Close()
+		if !p.closed.Load() {
+			p.logger.Error("failed to dial upstream", logger.String("upstream", p.upstream), logger.ErrorAttr(err))
+		}
+		return
+	}
+	defer p.conns.Delete(upstream)
+	if !p.track(upstream) {
+		_ = client.Close()
+		return
+	}
+
+	l := &link{client: client, upstream: upstream, done: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		p.pipe(l, upstream, client, &p.stats.bytesUp)
+	}()
+	go func() {
+		defer wg.Done()
+		p.pipe(l, client, upstream, &p.stats.bytesDown)
+	}()
+
+	if p.resetProb > 0 || p.stallProb > 0 {
+		go p.faults(l)
+	}
+
+	wg.Wait()
+	close(l.done)
+	_ = client.Close()
+	_ = upstream.Close()
+}
+
+// pipe copies src to dst, delaying every chunk by the latency and jitter and
+// pacing the writes to the bandwidth limit
+func (p *Proxy) pipe(l *link, dst, src net.Conn, bytes *atomic.Int64) {
+	queue := make(chan chunk, queueSize)
+
+	go func() {
+		defer close(queue)
+
+		var last time.Time
+		for {
+			buf := make([]byte, chunkSize)
+			n, err := src.Read(buf)
+			if n > 0 {
+				// Jitter never reorders data within a connection
+				due := time.Now().Add(p.delay())
+				if due.Before(last) {
+					due = last
+				}
+				last = due
+				queue <- chunk{data: buf[:n], due: due}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var next time.Time
+	for c := range queue {
+		wait(time.Until(c.due))
+		if until := l.stalled.Load(); until > 0 {
+			wait(time.Until(time.Unix(0, until)))
+		}
+
+		if _, err := dst.Write(c.data); err != nil {
+			// Unblock the reader, then drain it
+			_ = src.Close()
+			for range queue {
+			}
+			return
+		}
+		bytes.Add(int64(len(c.data)))
+
+		if p.bandwidth > 0 {
+			now := time.Now()
+			if next.Before(now) {
+				next = now
+			}
+			next = next.Add(time.Duration(int64(len(c.data)) * int64(time.Second) / p.bandwidth))
+			wait(time.Until(next))
+		}
+	}
+
+	// Pass the end of stream on while the other direction may still be open
+	if tcp, ok := dst.(*net.TCPConn); ok {
+		_ = tcp.CloseWrite()
+	} else {
+		_ = dst.Close()
+	}
+}
+
+// delay returns the latency with a uniformly distributed jitter
+func (p *Proxy) delay() time.Duration {
+	d := p.latency
+	if p.jitter > 0 {
+		d += time.Duration(rand.Int64N(int64(2*p.jitter+1))) - p.jitter
+	}
+	return max(d, 0)
+}
+
+// faults rolls the reset and stall probabilities once per interval
+func (p *Proxy) faults(l *link) {
+	ticker := time.NewTicker(faultInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+		}
+
+		if p.resetProb > 0 && rand.Float64() < p.resetProb {
+			p.stats.resets.Add(1)
+			reset(l.client)
+			reset(l.upstream)
+			return
+		}
+		if p.stallProb > 0 && rand.Float64() < p.stallProb {
+			p.stats.stalls.Add(1)
+			l.stalled.Store(time.Now().Add(p.stallDuration).UnixNano())
+		}
+	}
+}
+
+// reset closes conn with a TCP RST instead of a FIN
+func reset(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+func wait(d time.Duration) {
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
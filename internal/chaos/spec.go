@@ -0,0 +1,95 @@
+package chaos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// ParseSpec parses a comma separated fault list like
+// "latency=50ms,jitter=10ms,bandwidth=1MB,reset=0.01,stall=0.05,stall-duration=2s"
+func ParseSpec(spec string) ([]Option, error) {
+	var options []Option
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, invalidSpec(fmt.Errorf("%q is not key=value", field))
+		}
+
+		var err error
+		switch key {
+		case "latency", "jitter", "stall-duration":
+			var d time.Duration
+			if d, err = time.ParseDuration(value); err == nil {
+				switch key {
+				case "latency":
+					options = append(options, WithLatency(d))
+				case "jitter":
+					options = append(options, WithJitter(d))
+				default:
+					options = append(options, WithStallDuration(d))
+				}
+			}
+		case "bandwidth":
+			var bw int64
+			if bw, err = ParseBandwidth(value); err == nil {
+				options = append(options, WithBandwidth(bw))
+			}
+		case "reset", "stall":
+			var prob float64
+			if prob, err = strconv.ParseFloat(value, 64); err == nil {
+				if key == "reset" {
+					options = append(options, WithResetProb(prob))
+				} else {
+					options = append(options, WithStallProb(prob))
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown fault %q", key)
+		}
+		if err != nil {
+			return nil, invalidSpec(err)
+		}
+	}
+	return options, nil
+}
+
+// ParseBandwidth parses bytes per second with an optional KB, MB or GB suffix
+// (powers of 1024)
+func ParseBandwidth(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSuffix(value, unit.suffix)
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || n < 0 {
+		return 0, invalidSpec(fmt.Errorf("invalid bandwidth %q", value))
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+func invalidSpec(raw error) error {
+	return &er.Error{
+		Package: "Chaos",
+		Func:    "ParseSpec",
+		Message: er.ErrInvalidChaos,
+		Raw:     raw,
+	}
+}
@@ -0,0 +1,70 @@
+package chaos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSpec(t *testing.T) {
+	options, err := ParseSpec("latency=50ms, jitter=10ms,bandwidth=1MB,reset=0.01,stall=0.5,stall-duration=3s,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New("127.0.0.1:0", "127.0.0.1:1883", options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.latency != 50*time.Millisecond || p.jitter != 10*time.Millisecond {
+		t.Errorf("latency, jitter = %s, %s, want 50ms, 10ms", p.latency, p.jitter)
+	}
+	if p.bandwidth != 1<<20 {
+		t.Errorf("bandwidth = %d, want %d", p.bandwidth, 1<<20)
+	}
+	if p.resetProb != 0.01 || p.stallProb != 0.5 || p.stallDuration != 3*time.Second {
+		t.Errorf("reset, stall, stall duration = %v, %v, %s, want 0.01, 0.5, 3s", p.resetProb, p.stallProb, p.stallDuration)
+	}
+}
+
+func TestParseSpecInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"latency",
+		"latency=fast",
+		"bandwidth=-1KB",
+		"reset=often",
+		"loss=0.1",
+	} {
+		if _, err := ParseSpec(spec); err == nil {
+			t.Errorf("ParseSpec(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestParseBandwidth(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  int64
+	}{
+		{"1000", 1000},
+		{"512B", 512},
+		{"64kb", 64 << 10},
+		{"1.5MB", 3 << 19},
+		{" 2 GB ", 2 << 30},
+		{"0", 0},
+	} {
+		got, err := ParseBandwidth(tt.value)
+		if err != nil {
+			t.Errorf("ParseBandwidth(%q): %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBandwidth(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "MB", "fast", "-5"} {
+		if _, err := ParseBandwidth(value); err == nil {
+			t.Errorf("ParseBandwidth(%q) succeeded, want error", value)
+		}
+	}
+}
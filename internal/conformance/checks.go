@@ -0,0 +1,322 @@
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/mqtt"
+)
+
+// wildcards checks + and # matching, each filter gets its own subscriber so
+// overlapping subscriptions don't interfere
+func (s *Suite) wildcards() (string, error) {
+	filters := []string{"+/x", "#", "a/+", "+/+", "a/x", "a/#"}
+	topics := []string{"a/x", "a/y", "c/x", "a/x/z", "a"}
+
+	inboxes := make([]*inbox, len(filters))
+	for i, filter := range filters {
+		sub, err := s.paho(fmt.Sprintf("wildcard-sub%d", i))
+		if err != nil {
+			return "", err
+		}
+		defer sub.Disconnect()
+
+		inboxes[i] = newInbox()
+		if err := sub.Subscribe(s.topic("wild/"+filter), 1, false, inboxes[i].add); err != nil {
+			return "", err
+		}
+	}
+
+	pub, err := s.paho("wildcard-pub")
+	if err != nil {
+		return "", err
+	}
+	defer pub.Disconnect()
+
+	for _, topic := range topics {
+		if err := pub.Publish(s.topic("wild/"+topic), 1, false, topic, func() {}); err != nil {
+			return "", err
+		}
+	}
+
+	for i, filter := range filters {
+		var expected []string
+		for _, topic := range topics {
+			if mqtt.TopicMatch(filter, topic) {
+				expected = append(expected, topic)
+			}
+		}
+
+		inboxes[i].wait(len(expected), s.timeout)
+		time.Sleep(settleTime / time.Duration(len(filters)))
+		got := inboxes[i].received()
+
+		slices.Sort(expected)
+		slices.Sort(got)
+		if !slices.Equal(expected, got) {
+			return "", fmt.Errorf("filter %q: expected %v, received %v", filter, expected, got)
+		}
+	}
+
+	return fmt.Sprintf("%d filters x %d topics matched", len(filters), len(topics)), nil
+}
+
+// retained checks that a retained message reaches new subscribers and that an
+// empty retained message clears it
+func (s *Suite) retained() (string, error) {
+	topic := s.topic("retain")
+	payload := "retained-" + s.runID
+
+	pub, err := s.paho("retain-pub")
+	if err != nil {
+		return "", err
+	}
+	defer pub.Disconnect()
+
+	if err := pub.Publish(topic, 1, true, payload, func() {}); err != nil {
+		return "", err
+	}
+
+	first, err := s.paho("retain-sub1")
+	if err != nil {
+		return "", err
+	}
+	defer first.Disconnect()
+
+	box := newInbox()
+	if err := first.Subscribe(topic, 1, false, box.add); err != nil {
+		return "", err
+	}
+	if got := box.wait(1, s.timeout); len(got) != 1 || got[0] != payload {
+		return "", fmt.Errorf("new subscriber expected retained %q, received %v", payload, got)
+	}
+
+	// An empty retained payload removes the retained message
+	if err := pub.Publish(topic, 1, true, []byte{}, func() {}); err != nil {
+		return "", err
+	}
+
+	second, err := s.paho("retain-sub2")
+	if err != nil {
+		return "", err
+	}
+	defer second.Disconnect()
+
+	cleared := newInbox()
+	if err := second.Subscribe(topic, 1, false, cleared.add); err != nil {
+		return "", err
+	}
+	if got := cleared.wait(1, settleTime*2); len(got) != 0 {
+		return "", fmt.Errorf("retained message not cleared, received %v", got)
+	}
+
+	return "delivered to new subscribers and cleared by empty payload", nil
+}
+
+// exactlyOnce checks that QoS 2 messages arrive once without duplicates
+func (s *Suite) exactlyOnce() (string, error) {
+	const count = 100
+	topic := s.topic("qos2")
+
+	sub, err := s.paho("qos2-sub")
+	if err != nil {
+		return "", err
+	}
+	defer sub.Disconnect()
+
+	box := newInbox()
+	if err := sub.Subscribe(topic, 2, false, box.add); err != nil {
+		return "", err
+	}
+
+	pub, err := s.paho("qos2-pub")
+	if err != nil {
+		return "", err
+	}
+	defer pub.Disconnect()
+
+	for i := 0; i < count; i++ {
+		if err := pub.Publish(topic, 2, false, strconv.Itoa(i), func() {}); err != nil {
+			return "", err
+		}
+	}
+
+	box.wait(count, s.timeout)
+	time.Sleep(settleTime)
+	got := box.received()
+
+	seen := make(map[string]int, count)
+	for _, payload := range got {
+		seen[payload]++
+	}
+	var duplicates, missing int
+	for i := 0; i < count; i++ {
+		switch n := seen[strconv.Itoa(i)]; {
+		case n == 0:
+			missing++
+		case n > 1:
+			duplicates += n - 1
+		}
+	}
+	if duplicates > 0 || missing > 0 {
+		return "", fmt.Errorf("%d messages: %d missing, %d duplicates", count, missing, duplicates)
+	}
+
+	return fmt.Sprintf("%d messages delivered exactly once", count), nil
+}
+
+// will checks that the will is published on an abnormal disconnect and
+// discarded on a graceful one
+func (s *Suite) will() (string, error) {
+	topic := s.topic("will")
+
+	sub, err := s.paho("will-sub")
+	if err != nil {
+		return "", err
+	}
+	defer sub.Disconnect()
+
+	box := newInbox()
+	if err := sub.Subscribe(topic, 1, false, box.add); err != nil {
+		return "", err
+	}
+
+	graceful, err := s.native("will-graceful", mqtt.WithWill(topic, []byte("graceful"), 1, false))
+	if err != nil {
+		return "", err
+	}
+	graceful.Disconnect()
+
+	abnormal, err := s.native("will-abnormal", mqtt.WithWill(topic, []byte("abnormal"), 1, false))
+	if err != nil {
+		return "", err
+	}
+	abnormal.Close()
+
+	box.wait(1, s.timeout)
+	time.Sleep(settleTime)
+	got := box.received()
+	if !slices.Equal(got, []string{"abnormal"}) {
+		return "", fmt.Errorf("expected only the abnormal disconnect will, received %v", got)
+	}
+
+	return "published on abnormal disconnect, discarded on DISCONNECT", nil
+}
+
+// takeover checks that a second connection with the same client ID
+// disconnects the first one
+func (s *Suite) takeover() (string, error) {
+	first, err := s.native("takeover")
+	if err != nil {
+		return "", err
+	}
+	defer first.Close()
+
+	second, err := s.native("takeover")
+	if err != nil {
+		return "", err
+	}
+	defer second.Disconnect()
+
+	if !s.closed(first) {
+		return "", fmt.Errorf("first connection still open after takeover")
+	}
+	if err := second.Publish(s.topic("takeover"), 1, false, "alive", func() {}); err != nil {
+		return "", fmt.Errorf("new connection unusable after takeover: %w", err)
+	}
+
+	return "existing session disconnected", nil
+}
+
+// topicValidation checks that wildcards in PUBLISH topics and malformed
+// subscription filters are rejected
+func (s *Suite) topicValidation() (string, error) {
+	watcher, err := s.paho("topic-watch")
+	if err != nil {
+		return "", err
+	}
+	defer watcher.Disconnect()
+
+	box := newInbox()
+	if err := watcher.Subscribe(s.topic("topics/#"), 0, false, box.add); err != nil {
+		return "", err
+	}
+
+	pub, err := s.native("topic-pub")
+	if err != nil {
+		return "", err
+	}
+	defer pub.Close()
+
+	if err := pub.WriteRaw(mqtt.EncodePublish(s.topic("topics/+/bad"), 0, false, 0, []byte("wildcard"))); err != nil {
+		return "", err
+	}
+	if !s.closed(pub) {
+		return "", fmt.Errorf("connection not closed after PUBLISH with wildcard topic")
+	}
+	if got := box.received(); len(got) > 0 {
+		return "", fmt.Errorf("PUBLISH with wildcard topic was delivered")
+	}
+
+	sub, err := s.native("topic-sub")
+	if err != nil {
+		return "", err
+	}
+	defer sub.Close()
+
+	if err := sub.Subscribe(s.topic("topics/#/bad"), 0, false, func(string) {}); err == nil {
+		return "", fmt.Errorf("SUBSCRIBE with misplaced # was accepted")
+	}
+
+	return "wildcard topic and malformed filter rejected", nil
+}
+
+// packetLimits checks that a malformed packet length closes the connection
+// and that a large packet is delivered intact
+func (s *Suite) packetLimits() (string, error) {
+	malformed, err := s.native("packet-malformed")
+	if err != nil {
+		return "", err
+	}
+	defer malformed.Close()
+
+	// Five continuation bytes exceed the four byte remaining length limit
+	if err := malformed.WriteRaw([]byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01}); err != nil {
+		return "", err
+	}
+	if !s.closed(malformed) {
+		return "", fmt.Errorf("connection not closed after malformed remaining length")
+	}
+
+	topic := s.topic("large")
+	sub, err := s.native("packet-sub")
+	if err != nil {
+		return "", err
+	}
+	defer sub.Disconnect()
+
+	box := newInbox()
+	if err := sub.Subscribe(topic, 1, false, box.add); err != nil {
+		return "", err
+	}
+
+	pub, err := s.native("packet-pub")
+	if err != nil {
+		return "", err
+	}
+	defer pub.Disconnect()
+
+	payload := bytes.Repeat([]byte{'x'}, s.maxPacket)
+	if err := pub.Publish(topic, 1, false, payload, func() {}); err != nil {
+		return "", fmt.Errorf("broker rejected %d byte payload: %w", s.maxPacket, err)
+	}
+	got := box.wait(1, s.timeout)
+	if len(got) != 1 || got[0] != string(payload) {
+		return "", fmt.Errorf("%d byte payload not delivered intact", s.maxPacket)
+	}
+
+	return fmt.Sprintf("malformed length rejected, %d byte payload delivered", s.maxPacket), nil
+}
@@ -0,0 +1,218 @@
+package conformance
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/pkg/config"
+	"github.com/rayomqio/benchmq/pkg/er"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// Result represents the outcome of a single conformance check
+type Result struct {
+	Name     string
+	Passed   bool
+	Detail   string
+	Duration time.Duration
+}
+
+// Suite runs MQTT 3.1.1 protocol checks against the configured broker
+type Suite struct {
+	cfg       *config.Config
+	prefix    string
+	runID     string
+	timeout   time.Duration
+	maxPacket int
+	logger    *logger.Logger
+}
+
+type Option func(*Suite)
+
+const (
+	DefaultTimeout     = 5 * time.Second        // Default wait for expected messages
+	DefaultMaxPacket   = 1024 * 1024            // Default large packet payload size
+	DefaultTopicPrefix = "benchmq/conformance"  // Default topic prefix of all checks
+	settleTime         = 500 * time.Millisecond // Wait for unexpected extra messages
+)
+
+// check is a named conformance check, it returns a detail message on success
+// and an error describing the violation on failure
+type check struct {
+	name string
+	run  func(*Suite) (string, error)
+}
+
+var checks = []check{
+	{"wildcard matching", (*Suite).wildcards},
+	{"retained messages", (*Suite).retained},
+	{"qos 2 exactly once", (*Suite).exactlyOnce},
+	{"will messages", (*Suite).will},
+	{"session takeover", (*Suite).takeover},
+	{"topic validation", (*Suite).topicValidation},
+	{"max packet handling", (*Suite).packetLimits},
+}
+
+// NewSuite creates a conformance suite for the broker in cfg
+func NewSuite(cfg *config.Config, options ...Option) (*Suite, error) {
+	if cfg == nil {
+		return nil, &er.Error{
+			Package: "Conformance",
+			Func:    "NewSuite",
+			Message: er.ErrNilConfig,
+			Raw:     er.ErrNilConfig,
+		}
+	}
+
+	s := &Suite{
+		cfg:       cfg,
+		prefix:    DefaultTopicPrefix,
+		runID:     fmt.Sprintf("%x", time.Now().UnixNano()),
+		timeout:   DefaultTimeout,
+		maxPacket: DefaultMaxPacket,
+		logger:    logger.NewBenchmarkLogger("conformance"),
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(s)
+		}
+	}
+
+	if s.timeout <= 0 || s.maxPacket <= 0 || s.prefix == "" {
+		return nil, &er.Error{
+			Package: "Conformance",
+			Func:    "NewSuite",
+			Message: er.ErrInvalidConformance,
+			Raw:     er.ErrInvalidConformance,
+		}
+	}
+
+	return s, nil
+}
+
+// Run executes every check in order and returns their results
+func (s *Suite) Run() []Result {
+	results := make([]Result, 0, len(checks))
+
+	for _, c := range checks {
+		start := time.Now()
+		detail, err := c.run(s)
+		result := Result{Name: c.name, Passed: err == nil, Detail: detail, Duration: time.Since(start)}
+		if err != nil {
+			result.Detail = err.Error()
+			s.logger.Warn("check failed", logger.String("check", c.name), logger.ErrorAttr(err))
+		} else {
+			s.logger.Info("check passed", logger.String("check", c.name))
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Suite) {
+		s.timeout = timeout
+	}
+}
+
+func WithMaxPacket(size int) Option {
+	return func(s *Suite) {
+		s.maxPacket = size
+	}
+}
+
+func WithTopicPrefix(prefix string) Option {
+	return func(s *Suite) {
+		s.prefix = prefix
+	}
+}
+
+// topic returns a topic unique to this run
+func (s *Suite) topic(levels string) string {
+	return fmt.Sprintf("%s/%s/%s", s.prefix, s.runID, levels)
+}
+
+// config returns the client configuration for the named client
+func (s *Suite) config(name string) *config.Config {
+	cfg := *s.cfg
+	cfg.Client.ClientID = fmt.Sprintf("benchmq-conformance-%s-%s", name, s.runID)
+	cfg.Client.CleanSession = true
+	return &cfg
+}
+
+// paho connects a paho based client
+func (s *Suite) paho(name string, options ...mqtt.Option) (*mqtt.Adapter, error) {
+	client := mqtt.NewClient(s.config(name), options...)
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// native connects a native client, used where raw packet access is needed
+func (s *Suite) native(name string, options ...mqtt.Option) (*mqtt.Native, error) {
+	client := mqtt.NewNativeClient(s.config(name), options...)
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// closed reports whether the broker closed the connection within the timeout
+func (s *Suite) closed(client *mqtt.Native) bool {
+	select {
+	case <-client.Done():
+		return true
+	case <-time.After(s.timeout):
+		return false
+	}
+}
+
+// inbox collects received payloads
+type inbox struct {
+	mu       sync.Mutex
+	payloads []string
+	notify   chan struct{}
+}
+
+func newInbox() *inbox {
+	return &inbox{notify: make(chan struct{}, 1)}
+}
+
+// add is the subscription callback
+func (i *inbox) add(payload string) {
+	i.mu.Lock()
+	i.payloads = append(i.payloads, payload)
+	i.mu.Unlock()
+
+	select {
+	case i.notify <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks until n payloads arrived or the timeout passed, then returns
+// everything received so far
+func (i *inbox) wait(n int, timeout time.Duration) []string {
+	deadline := time.After(timeout)
+	for {
+		if payloads := i.received(); len(payloads) >= n {
+			return payloads
+		}
+		select {
+		case <-i.notify:
+		case <-deadline:
+			return i.received()
+		}
+	}
+}
+
+func (i *inbox) received() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]string(nil), i.payloads...)
+}
@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// subBuckets is the number of linear buckets per power of two, it bounds the
+// relative error of recorded values to about 1/subBuckets
+const subBuckets = 32
+
+// subBucketBits is log2(subBuckets)
+const subBucketBits = 5
+
+// Histogram records durations in log-linear buckets with constant memory,
+// it is safe for concurrent use
+type Histogram struct {
+	mu     sync.Mutex
+	counts [64 * subBuckets]uint64
+	count  uint64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// NewHistogram creates an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// Record adds a duration sample
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	h.mu.Lock()
+	h.counts[bucketIndex(uint64(d))]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+	h.mu.Unlock()
+}
+
+// Merge adds all samples of other into h
+func (h *Histogram) Merge(other *Histogram) {
+	snap := other.Snapshot()
+	if snap.count == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	for i, c := range snap.counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || snap.min < h.min {
+		h.min = snap.min
+	}
+	if snap.max > h.max {
+		h.max = snap.max
+	}
+	h.count += snap.count
+	h.sum += snap.sum
+	h.mu.Unlock()
+}
+
+// Snapshot returns a copy of the histogram
+func (h *Histogram) Snapshot() *Histogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return &Histogram{counts: h.counts, count: h.count, sum: h.sum, min: h.min, max: h.max}
+}
+
+// Reset removes all samples and returns the histogram as it was before
+func (h *Histogram) Reset() *Histogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	prev := &Histogram{counts: h.counts, count: h.count, sum: h.sum, min: h.min, max: h.max}
+	h.counts = [64 * subBuckets]uint64{}
+	h.count, h.sum, h.min, h.max = 0, 0, 0, 0
+	return prev
+}
+
+// Count returns the number of samples
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Min returns the smallest sample
+func (h *Histogram) Min() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.min
+}
+
+// Max returns the largest sample
+func (h *Histogram) Max() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.max
+}
+
+// Mean returns the average of all samples
+func (h *Histogram) Mean() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Percentile returns the approximated value below which p percent (0-100)
+// of the samples fall
+func (h *Histogram) Percentile(p float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p / 100 * float64(h.count)))
+	rank = max(1, min(rank, h.count))
+
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			// Clamp the bucket midpoint to the observed range
+			v := time.Duration(bucketMidpoint(i))
+			return max(h.min, min(h.max, v))
+		}
+	}
+	return h.max
+}
+
+// bucketIndex maps a value to its log-linear bucket
+func bucketIndex(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	exp := bits.Len64(v) - subBucketBits - 1
+	sub := int(v>>uint(exp)) & (subBuckets - 1)
+	return (exp+1)*subBuckets + sub
+}
+
+// bucketMidpoint returns the value in the middle of bucket i
+func bucketMidpoint(i int) uint64 {
+	if i < subBuckets {
+		return uint64(i)
+	}
+	exp := i/subBuckets - 1
+	sub := uint64(i%subBuckets) | subBuckets
+	lower := sub << uint(exp)
+	return lower + (uint64(1)<<uint(exp))/2
+}
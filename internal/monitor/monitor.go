@@ -0,0 +1,323 @@
+package monitor
+
+import (
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// Sample is the resource usage of the benchmq process over one interval.
+// Values that can't be read on the platform are -1.
+type Sample struct {
+	Time       time.Time
+	Elapsed    time.Duration // Wall time since the previous sample
+	CPUPercent float64       // CPU time used per wall time, 100 is one full core
+	RSSBytes   int64         // Resident memory, the Go runtime's memory where RSS is unavailable
+	Goroutines int           // Goroutines at the end of the interval
+	GCCount    uint32        // Garbage collections in the interval
+	GCPause    time.Duration // Total stop-the-world GC pause in the interval
+	GCPauseMax time.Duration // Longest GC pause in the interval
+	OpenFDs    int           // Open file descriptors
+}
+
+// Summary aggregates all samples of a run
+type Summary struct {
+	Samples       int
+	CPUPercentAvg float64
+	CPUPercentMax float64
+	RSSBytesMax   int64
+	GoroutinesMax int
+	GCCount       uint32
+	GCPause       time.Duration
+	GCPauseMax    time.Duration
+	OpenFDsMax    int
+	Saturated     bool // The load generator was saturated at some point
+}
+
+// Monitor periodically samples the resource usage of the process and warns
+// when the load generator itself looks like the bottleneck
+type Monitor struct {
+	interval time.Duration
+	cores    int
+	fdLimit  int
+
+	mu      sync.Mutex
+	samples []Sample
+	busy    int  // Consecutive samples with saturated CPU
+	calm    int  // Consecutive samples without saturation
+	warned  bool // A saturation warning is active
+	hit     bool // The process was saturated at some point
+
+	sink func(Sample) // Receives every sample as it is taken
+
+	stop   chan struct{}
+	done   chan struct{}
+	logger *logger.Logger
+}
+
+type Option func(*Monitor)
+
+// WithSink passes every sample to sink as soon as it is taken
+func WithSink(sink func(Sample)) Option {
+	return func(m *Monitor) {
+		m.sink = sink
+	}
+}
+
+const (
+	DefaultInterval = time.Second // Default sampling interval
+	// cpuSaturation is the share of the available cores above which the
+	// process counts as CPU bound
+	cpuSaturation = 0.85
+	// lagSaturation is how late a sample may be, relative to the interval,
+	// before the scheduler counts as overloaded
+	lagSaturation = 2
+	// saturationSamples is how many consecutive CPU bound samples trigger a
+	// warning, short spikes are ignored. As many calm samples end it.
+	saturationSamples = 3
+	// gcSaturation is the share of an interval spent in GC pauses above
+	// which latencies are distorted by the client
+	gcSaturation = 0.05
+	// fdSaturation is the share of the file descriptor limit in use that
+	// triggers a warning
+	fdSaturation = 0.9
+)
+
+// New creates a monitor sampling every interval
+func New(interval time.Duration, options ...Option) *Monitor {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	m := &Monitor{
+		interval: interval,
+		cores:    runtime.GOMAXPROCS(0),
+		fdLimit:  fdLimit(),
+		logger:   logger.NewBenchmarkLogger("monitor"),
+	}
+	for _, option := range options {
+		if option != nil {
+			option(m)
+		}
+	}
+	return m
+}
+
+// Start begins sampling in the background
+func (m *Monitor) Start() {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run()
+}
+
+// Stop ends sampling and returns the summary of all samples
+func (m *Monitor) Stop() Summary {
+	close(m.stop)
+	<-m.done
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return summarize(m.samples, m.hit)
+}
+
+// Samples returns the samples taken so far
+func (m *Monitor) Samples() []Sample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Sample(nil), m.samples...)
+}
+
+func (m *Monitor) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	prev := read()
+	for {
+		select {
+		case <-m.stop:
+			// Close the last partial interval so short runs get a sample
+			m.record(prev, read())
+			return
+		case <-ticker.C:
+		}
+
+		cur := read()
+		m.record(prev, cur)
+		prev = cur
+	}
+}
+
+// record stores and logs the sample between two readings
+func (m *Monitor) record(prev, cur reading) {
+	s := sample(prev, cur)
+
+	m.mu.Lock()
+	m.samples = append(m.samples, s)
+	m.mu.Unlock()
+
+	m.logger.Info("client resources", Attrs(s)...)
+	m.check(s)
+	if m.sink != nil {
+		m.sink(s)
+	}
+}
+
+// check warns when a sample shows the process as the bottleneck
+func (m *Monitor) check(s Sample) {
+	busy := s.CPUPercent >= cpuSaturation*100*float64(m.cores)
+	// A sampler that can't keep its own schedule means runnable goroutines
+	// are queueing for a CPU
+	late := s.Elapsed >= lagSaturation*m.interval
+
+	if busy {
+		m.busy++
+	} else {
+		m.busy = 0
+	}
+	if busy || late {
+		m.calm = 0
+	} else if m.calm++; m.calm >= saturationSamples {
+		m.warned = false
+	}
+
+	if (late || m.busy >= saturationSamples) && !m.warned {
+		m.warned = true
+		m.hit = true
+		m.logger.Warn("load generator appears saturated, results may be limited by benchmq rather than the broker",
+			logger.Float("cpuPercent", s.CPUPercent),
+			logger.Int("cores", m.cores),
+			logger.String("sampleDelay", (s.Elapsed-m.interval).String()),
+		)
+	}
+
+	if s.GCPause > time.Duration(gcSaturation*float64(s.Elapsed)) {
+		m.logger.Warn("garbage collection pauses are distorting client timings",
+			logger.String("gcPause", s.GCPause.String()),
+			logger.String("interval", s.Elapsed.String()),
+		)
+	}
+
+	if m.fdLimit > 0 && s.OpenFDs >= int(fdSaturation*float64(m.fdLimit)) {
+		m.logger.Warn("open file descriptors close to the limit",
+			logger.Int("openFDs", s.OpenFDs),
+			logger.Int("limit", m.fdLimit),
+		)
+	}
+}
+
+// reading is a point-in-time snapshot of the counters
+type reading struct {
+	at       time.Time
+	cpu      time.Duration
+	cpuOK    bool
+	numGC    uint32
+	pauseNs  [256]uint64
+	pauseTot uint64
+	rss      int64
+}
+
+func read() reading {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	r := reading{
+		at:       time.Now(),
+		numGC:    stats.NumGC,
+		pauseNs:  stats.PauseNs,
+		pauseTot: stats.PauseTotalNs,
+		rss:      residentMemory(),
+	}
+	if r.rss < 0 {
+		// Memory obtained from the OS and not returned to it
+		r.rss = int64(stats.Sys - stats.HeapReleased)
+	}
+	r.cpu, r.cpuOK = cpuTime()
+	return r
+}
+
+// sample derives the interval values between two readings
+func sample(prev, cur reading) Sample {
+	s := Sample{
+		Time:       cur.at,
+		Elapsed:    cur.at.Sub(prev.at),
+		CPUPercent: -1,
+		RSSBytes:   cur.rss,
+		Goroutines: runtime.NumGoroutine(),
+		GCCount:    cur.numGC - prev.numGC,
+		GCPause:    time.Duration(cur.pauseTot - prev.pauseTot),
+		OpenFDs:    openFDs(),
+	}
+
+	if cur.cpuOK && prev.cpuOK && s.Elapsed > 0 {
+		s.CPUPercent = float64(cur.cpu-prev.cpu) / float64(s.Elapsed) * 100
+	}
+
+	// PauseNs is a ring buffer of the most recent 256 pauses
+	for i := uint32(0); i < s.GCCount && i < 256; i++ {
+		pause := time.Duration(cur.pauseNs[(cur.numGC-i+255)%256])
+		s.GCPauseMax = max(s.GCPauseMax, pause)
+	}
+	return s
+}
+
+func summarize(samples []Sample, saturated bool) Summary {
+	sum := Summary{Samples: len(samples), Saturated: saturated, OpenFDsMax: -1, CPUPercentAvg: -1, CPUPercentMax: -1}
+
+	var cpuTotal float64
+	var cpuSamples int
+	for _, s := range samples {
+		if s.CPUPercent >= 0 {
+			cpuTotal += s.CPUPercent
+			cpuSamples++
+			sum.CPUPercentMax = max(sum.CPUPercentMax, s.CPUPercent)
+		}
+		sum.RSSBytesMax = max(sum.RSSBytesMax, s.RSSBytes)
+		sum.GoroutinesMax = max(sum.GoroutinesMax, s.Goroutines)
+		sum.GCCount += s.GCCount
+		sum.GCPause += s.GCPause
+		sum.GCPauseMax = max(sum.GCPauseMax, s.GCPauseMax)
+		sum.OpenFDsMax = max(sum.OpenFDsMax, s.OpenFDs)
+	}
+	if cpuSamples > 0 {
+		sum.CPUPercentAvg = cpuTotal / float64(cpuSamples)
+	}
+	return sum
+}
+
+// Attrs returns the log attributes of a sample
+func Attrs(s Sample) []slog.Attr {
+	return []slog.Attr{
+		logger.Float("intervalMs", ms(s.Elapsed)),
+		logger.Float("cpuPercent", s.CPUPercent),
+		logger.Any("rssBytes", s.RSSBytes),
+		logger.Int("goroutines", s.Goroutines),
+		logger.Any("gcCount", s.GCCount),
+		logger.Float("gcPauseMs", ms(s.GCPause)),
+		logger.Float("gcPauseMaxMs", ms(s.GCPauseMax)),
+		logger.Int("openFDs", s.OpenFDs),
+	}
+}
+
+// SummaryAttrs returns the log attributes of a summary
+func SummaryAttrs(s Summary) []slog.Attr {
+	return []slog.Attr{
+		logger.Int("samples", s.Samples),
+		logger.Float("cpuPercentAvg", s.CPUPercentAvg),
+		logger.Float("cpuPercentMax", s.CPUPercentMax),
+		logger.Any("rssBytesMax", s.RSSBytesMax),
+		logger.Int("goroutinesMax", s.GoroutinesMax),
+		logger.Any("gcCount", s.GCCount),
+		logger.Float("gcPauseMs", ms(s.GCPause)),
+		logger.Float("gcPauseMaxMs", ms(s.GCPauseMax)),
+		logger.Int("openFDsMax", s.OpenFDsMax),
+		logger.Bool("saturated", s.Saturated),
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,40 @@
+package monitor
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// residentMemory returns the resident set size from /proc/self/statm
+func residentMemory() int64 {
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return -1
+	}
+	fields := bytes.Fields(data)
+	if len(fields) < 2 {
+		return -1
+	}
+	pages, err := strconv.ParseInt(string(fields[1]), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return pages * int64(os.Getpagesize())
+}
+
+// openFDs counts the entries of /proc/self/fd
+func openFDs() int {
+	f, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return -1
+	}
+	// The directory handle itself is one of the entries
+	return len(names) - 1
+}
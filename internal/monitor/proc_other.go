@@ -0,0 +1,33 @@
+//go:build !linux
+
+package monitor
+
+import (
+	"os"
+	"runtime"
+)
+
+// residentMemory is unavailable without /proc, the caller falls back to the
+// memory held by the Go runtime
+func residentMemory() int64 {
+	return -1
+}
+
+// openFDs counts the entries of /dev/fd on BSDs and macOS
+func openFDs() int {
+	if runtime.GOOS == "windows" {
+		return -1
+	}
+	f, err := os.Open("/dev/fd")
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return -1
+	}
+	// The directory handle itself is one of the entries
+	return len(names) - 1
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package monitor
+
+import "time"
+
+// cpuTime is unavailable without getrusage
+func cpuTime() (time.Duration, bool) {
+	return 0, false
+}
+
+// fdLimit is unknown without getrlimit
+func fdLimit() int {
+	return 0
+}
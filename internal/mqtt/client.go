@@ -0,0 +1,156 @@
+package mqtt
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/config"
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Client is the MQTT client used by the benchmarks
+type Client interface {
+	// Connect establishes a connection to the MQTT broker
+	Connect() error
+	// Publish publishes a message and waits for its acknowledgement
+	Publish(topic string, qos byte, retained bool, payload any, callback func()) error
+	// PublishAsync publishes a message and reports its acknowledgement later
+	PublishAsync(topic string, qos byte, retained bool, payload any, callback func(rtt time.Duration, err error)) error
+	// Subscribe subscribes to a topic filter
+	Subscribe(topic string, qos byte, retained bool, callback func(payload string)) error
+	// SubscribeMessages subscribes to a topic filter, the callback receives the
+	// topic of every message along with its payload
+	SubscribeMessages(filter string, qos byte, callback func(topic string, payload []byte)) error
+	// SubscribeFilters subscribes to several topic filters with a single
+	// SUBSCRIBE, the callback receives the filter a message matched
+	SubscribeFilters(filters []Filter, callback func(filter, topic string, payload []byte)) error
+	// Unsubscribe unsubscribes from a topic filter
+	Unsubscribe(topic string) error
+	// Disconnect disconnects the client from the MQTT broker
+	Disconnect()
+}
+
+// Pinger is implemented by clients that can wait for a PINGRESP
+type Pinger interface {
+	// Ping sends PINGREQ and waits for the PINGRESP
+	Ping() error
+}
+
+// AckedUnsubscriber is implemented by clients that can run a hook in order
+// with the subscription callbacks when the UNSUBACK is handled
+type AckedUnsubscriber interface {
+	// UnsubscribeAcked unsubscribes from a topic filter, acked runs after the
+	// callbacks of the messages received before the UNSUBACK and before those
+	// of the messages received after it
+	UnsubscribeAcked(topic string, acked func()) error
+}
+
+// Filter is a topic filter and the maximum QoS it is subscribed with
+type Filter struct {
+	Topic string
+	QoS   byte
+}
+
+// Implementation represents an MQTT client implementation
+type Implementation string
+
+const (
+	ImplPaho   Implementation = "paho"   // Eclipse paho client
+	ImplNative Implementation = "native" // Built-in lightweight client
+)
+
+// New creates a client of the given implementation
+func New(impl Implementation, cfg *config.Config, options ...Option) (Client, error) {
+	switch impl {
+	case ImplPaho, "":
+		return NewClient(cfg, options...), nil
+	case ImplNative:
+		return NewNativeClient(cfg, options...), nil
+	default:
+		return nil, &er.Error{
+			Package: "MQTT",
+			Func:    "New",
+			Message: er.ErrUnknownClientImpl,
+			Raw:     er.ErrUnknownClientImpl,
+		}
+	}
+}
+
+// TopicMatch reports whether topic matches the subscription filter,
+// including the + and # wildcards and the $-prefixed topic rule
+func TopicMatch(filter, topic string) bool {
+	if filter == topic {
+		return true
+	}
+	// Wildcards at the first level never match topics starting with $
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+
+	for {
+		fLevel, fRest, fMore := strings.Cut(filter, "/")
+		tLevel, tRest, tMore := strings.Cut(topic, "/")
+
+		switch {
+		case fLevel == "#":
+			return true
+		case fLevel != "+" && fLevel != tLevel:
+			return false
+		case !fMore && !tMore:
+			return true
+		case !fMore:
+			return false
+		case !tMore:
+			// "a/#" also matches the parent level "a"
+			return fRest == "#"
+		}
+		filter, topic = fRest, tRest
+	}
+}
+
+// Stream is a payload of Len bytes written by WriteTo, the native client
+// streams it to the connection so it never has to be held in memory
+type Stream interface {
+	io.WriterTo
+	Len() int
+}
+
+// MaxPayloadSize returns the largest payload a PUBLISH to topic can carry
+// within the MQTT 3.1.1 remaining length limit
+func MaxPayloadSize(topic string, qos byte) int {
+	size := maxRemainingLength - 2 - len(topic)
+	if qos > 0 {
+		size -= 2
+	}
+	return size
+}
+
+// payloadBytes converts a publish payload to bytes, streams are read into
+// memory
+func payloadBytes(payload any) ([]byte, bool) {
+	switch p := payload.(type) {
+	case []byte:
+		return p, true
+	case string:
+		return []byte(p), true
+	case Stream:
+		var buf bytes.Buffer
+		buf.Grow(p.Len())
+		if _, err := p.WriteTo(&buf); err != nil {
+			return nil, false
+		}
+		return buf.Bytes(), true
+	default:
+		return nil, false
+	}
+}
+
+// Compile-time interface checks
+var (
+	_ Client            = (*Adapter)(nil)
+	_ Client            = (*Native)(nil)
+	_ Pinger            = (*Native)(nil)
+	_ AckedUnsubscriber = (*Native)(nil)
+)
@@ -0,0 +1,43 @@
+package mqtt
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// Traffic accumulates the bytes written to and read from the network by every
+// client sharing it, including all MQTT protocol overhead
+type Traffic struct {
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+// Sent returns the number of bytes written on the wire
+func (t *Traffic) Sent() int64 {
+	return t.sent.Load()
+}
+
+// Received returns the number of bytes read from the wire
+func (t *Traffic) Received() int64 {
+	return t.received.Load()
+}
+
+// countingConn wraps a net.Conn and records its traffic
+type countingConn struct {
+	net.Conn
+	traffic *Traffic
+}
+
+// Read reads from the underlying connection and counts the bytes read
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.traffic.received.Add(int64(n))
+	return n, err
+}
+
+// Write writes to the underlying connection and counts the bytes written
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.traffic.sent.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,260 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	mq "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gorilla/websocket"
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Phases is the time spent in each step of establishing a connection, steps
+// that don't apply to the transport are zero
+type Phases struct {
+	DNS       time.Duration // Resolving the broker host
+	TCP       time.Duration // TCP three-way handshake
+	Proxy     time.Duration // Tunnel setup through the proxy
+	TLS       time.Duration // TLS handshake
+	WebSocket time.Duration // HTTP upgrade to WebSocket
+	Connack   time.Duration // MQTT CONNECT until CONNACK
+}
+
+// dialer opens the network connection of a client. It replaces paho's
+// built-in opener so the connection can be instrumented, and is shared with
+// the native client.
+type dialer struct {
+	traffic       *Traffic
+	timeout       time.Duration
+	localAddr     *net.TCPAddr
+	via           string // Address dialed instead of the broker host
+	proxy         *url.URL
+	proxyProto    byte         // PROXY protocol version, 0 sends no header
+	proxyProtoSrc *net.TCPAddr // Source address announced in the header
+
+	// latest holds the timings of the connection paho opened last, paho
+	// dials itself and reconnects from its own goroutines
+	latest atomic.Pointer[dialTiming]
+}
+
+// dialTiming is the phases of one connection up to its CONNACK
+type dialTiming struct {
+	phases Phases
+	up     time.Time // When the network connection was established
+}
+
+// connected completes the phases once CONNACK arrived and returns them
+func (t *dialTiming) connected() Phases {
+	phases := t.phases
+	phases.Connack = time.Since(t.up)
+	return phases
+}
+
+// open establishes the network connection for uri and returns its timings
+func (d *dialer) open(uri *url.URL) (net.Conn, *dialTiming, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	timing := &dialTiming{}
+	conn, err := d.dial(ctx, uri, &timing.phases)
+	if err != nil {
+		return nil, nil, &er.Error{
+			Package: "MQTT",
+			Func:    "Dial",
+			Message: er.ErrDialFailed,
+			Raw:     err,
+		}
+	}
+
+	timing.up = time.Now()
+	return conn, timing, nil
+}
+
+// openPaho matches the mq.OpenConnectionFunc signature
+func (d *dialer) openPaho(uri *url.URL, _ mq.ClientOptions) (net.Conn, error) {
+	conn, timing, err := d.open(uri)
+	if err != nil {
+		return nil, err
+	}
+	d.latest.Store(timing)
+	return conn, nil
+}
+
+// dial connects to uri and records the time of each step in phases
+func (d *dialer) dial(ctx context.Context, uri *url.URL, phases *Phases) (net.Conn, error) {
+	switch uri.Scheme {
+	case "tcp", "mqtt":
+		return d.dialTCP(ctx, uri.Host, phases)
+	case "ssl", "tls", "mqtts", "tcps":
+		return d.dialTLS(ctx, uri.Host, phases)
+	case "ws", "wss":
+		return d.dialWebsocket(ctx, uri, phases)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", uri.Scheme)
+	}
+}
+
+// dialTCP resolves the host and connects to the first reachable address, or
+// tunnels through the proxy when one is set. The traffic is counted on the
+// raw connection so proxy, TLS and WebSocket overhead are included.
+func (d *dialer) dialTCP(ctx context.Context, address string, phases *Phases) (net.Conn, error) {
+	target := address
+	switch {
+	case d.proxy != nil:
+		address = d.proxy.Host
+	case d.via != "":
+		address = d.via
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	phases.DNS = time.Since(start)
+
+	netDialer := net.Dialer{}
+	if d.localAddr != nil {
+		netDialer.LocalAddr = d.localAddr
+		// Reconnects bind the same fixed port again
+		if d.localAddr.Port != 0 {
+			netDialer.Control = reuseAddr
+		}
+	}
+	start = time.Now()
+	var conn net.Conn
+	for _, addr := range addrs {
+		if conn, err = netDialer.DialContext(ctx, "tcp", net.JoinHostPort(addr.IP.String(), port)); err == nil {
+			break
+		}
+	}
+	if conn == nil {
+		return nil, err
+	}
+	phases.TCP = time.Since(start)
+
+	if d.traffic != nil {
+		conn = &countingConn{Conn: conn, traffic: d.traffic}
+	}
+	if d.proxy != nil {
+		start = time.Now()
+		tunneled, err := tunnel(ctx, conn, d.proxy, target)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		phases.Proxy = time.Since(start)
+		conn = tunneled
+	}
+
+	if d.proxyProto > 0 {
+		if err := d.writeProxyHeader(ctx, conn, target, address == target); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// writeProxyHeader announces the synthetic client address to the broker at
+// target. A connection through a proxy ends at the proxy, the broker address
+// is resolved then.
+func (d *dialer) writeProxyHeader(ctx context.Context, conn net.Conn, target string, direct bool) error {
+	dst, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !direct || !ok {
+		var err error
+		if dst, err = d.resolveBroker(ctx, target); err != nil {
+			return err
+		}
+	}
+	header, err := proxyHeader(d.proxyProto, d.proxyProtoSrc, dst)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(header)
+	return err
+}
+
+// resolveBroker resolves the broker address, preferring an IP of the family
+// of the PROXY protocol source
+func (d *dialer) resolveBroker(ctx context.Context, target string) (*net.TCPAddr, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	portNum, err := net.LookupPort("tcp", port)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	v4 := d.proxyProtoSrc.IP.To4() != nil
+	for _, addr := range addrs {
+		if (addr.IP.To4() != nil) == v4 {
+			return &net.TCPAddr{IP: addr.IP, Port: portNum}, nil
+		}
+	}
+	return &net.TCPAddr{IP: addrs[0].IP, Port: portNum}, nil
+}
+
+// dialTLS connects and runs the TLS handshake
+func (d *dialer) dialTLS(ctx context.Context, address string, phases *Phases) (net.Conn, error) {
+	conn, err := d.dialTCP(ctx, address, phases)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, _ := net.SplitHostPort(address)
+	cfg := &tls.Config{ServerName: host}
+
+	start := time.Now()
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	phases.TLS = time.Since(start)
+	return tlsConn, nil
+}
+
+// dialWebsocket upgrades a TCP or TLS connection to a WebSocket carrying MQTT
+func (d *dialer) dialWebsocket(ctx context.Context, uri *url.URL, phases *Phases) (net.Conn, error) {
+	// Gorilla websockets reject URLs carrying user info
+	dialURI := *uri
+	dialURI.User = nil
+
+	var dialed time.Time
+	wsDialer := &websocket.Dialer{
+		Subprotocols: []string{"mqtt"},
+		NetDialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			defer func() { dialed = time.Now() }()
+			return d.dialTCP(ctx, addr, phases)
+		},
+		NetDialTLSContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			defer func() { dialed = time.Now() }()
+			return d.dialTLS(ctx, addr, phases)
+		},
+	}
+
+	ws, resp, err := wsDialer.DialContext(ctx, dialURI.String(), nil)
+	if err != nil {
+		if resp != nil {
+			err = fmt.Errorf("%w: %s", err, resp.Status)
+		}
+		return nil, err
+	}
+	phases.WebSocket = time.Since(dialed)
+	return &wsConn{Conn: ws}, nil
+}
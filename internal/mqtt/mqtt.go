@@ -2,6 +2,8 @@ package mqtt
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,31 +16,47 @@ import (
 // Adapter represents an MQTT adapter instance
 type Adapter struct {
 	client mq.Client
+	dialer *dialer
+	phases func(Phases)
 	wg     sync.WaitGroup
 }
 
 // NewClient creates a new MQTT adapter instance
-func NewClient(cfg *config.Config) *Adapter {
+func NewClient(cfg *config.Config, options ...Option) *Adapter {
+	o := newOptions(options)
+	d := o.dialer()
+
 	// Initialize MQTT client options
 	opts := mq.NewClientOptions()
 
-	opts.AddBroker(fmt.Sprintf("tcp://%s:%d", cfg.Server.Host, cfg.Server.Port))
+	opts.AddBroker(o.brokerURL(cfg).String())
 	opts.SetClientID(cfg.Client.ClientID)
 	opts.SetKeepAlive(time.Duration(cfg.Client.KeepAlive) * time.Second)
 	opts.SetCleanSession(cfg.Client.CleanSession)
 	opts.SetUsername(cfg.Client.Username)
 	opts.SetPassword(cfg.Client.Password)
 	opts.SetProtocolVersion(4) // Default set to MQTT 3.1.1
+	if o.noRoute != nil {
+		opts.SetDefaultPublishHandler(func(_ mq.Client, msg mq.Message) {
+			o.noRoute(msg.Topic(), msg.Payload())
+		})
+	}
+	if o.will != nil {
+		opts.SetBinaryWill(o.will.topic, o.will.payload, o.will.qos, o.will.retained)
+	}
+	opts.SetConnectTimeout(d.timeout)
+	opts.SetCustomOpenConnectionFn(d.openPaho)
 
 	// Create a new MQTT client instance
 	client := mq.NewClient(opts)
 
 	// Return the initialized MQTT adapter
-	return &Adapter{client: client}
+	return &Adapter{client: client, dialer: d, phases: o.phases}
 }
 
 // Connect establishes a connection to the MQTT broker
 func (a *Adapter) Connect() error {
+	a.dialer.latest.Store(nil)
 	if token := a.client.Connect(); token.Wait() && token.Error() != nil {
 		tErr := token.Error()
 		return &er.Error{
@@ -48,6 +66,11 @@ func (a *Adapter) Connect() error {
 			Raw:     tErr,
 		}
 	}
+	// Paho reconnects only after Connect succeeded, the latest connection
+	// is the one it established
+	if timing := a.dialer.latest.Load(); a.phases != nil && timing != nil {
+		a.phases(timing.connected())
+	}
 	return nil
 }
 
@@ -65,6 +88,10 @@ func (a *Adapter) Publish(topic string, qos byte, retained bool, payload any, ca
 		return err
 	}
 
+	payload, err := inMemory("Publish", payload)
+	if err != nil {
+		return err
+	}
 	token := a.client.Publish(topic, qos, retained, payload)
 	token.Wait()
 
@@ -102,6 +129,67 @@ func (a *Adapter) Publish(topic string, qos byte, retained bool, payload any, ca
 	return nil
 }
 
+// PublishAsync publishes a message without waiting for its acknowledgement.
+// The callback is invoked once the broker acknowledged the message (PUBACK for
+// QoS 1, PUBCOMP for QoS 2, network write for QoS 0) with the round-trip time.
+func (a *Adapter) PublishAsync(topic string, qos byte, retained bool, payload any, callback func(rtt time.Duration, err error)) error {
+	if callback == nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "PublishAsync",
+			Message: er.ErrNilCallback,
+		}
+	}
+
+	if err := a.Validate(topic, qos); err != nil {
+		return err
+	}
+
+	payload, err := inMemory("PublishAsync", payload)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	token := a.client.Publish(topic, qos, retained, payload)
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("panic in publish callback",
+					logger.Any("recover", r),
+				)
+			}
+		}()
+
+		if !token.WaitTimeout(30 * time.Second) {
+			callback(time.Since(start), &er.Error{
+				Package: "MQTT",
+				Func:    "PublishAsync",
+				Message: er.ErrPublishFailed,
+				Raw:     fmt.Errorf("timeout waiting for publish token"),
+			})
+			return
+		}
+		rtt := time.Since(start)
+
+		if err := token.Error(); err != nil {
+			callback(rtt, &er.Error{
+				Package: "MQTT",
+				Func:    "PublishAsync",
+				Message: er.ErrPublishFailed,
+				Raw:     err,
+			})
+			return
+		}
+		callback(rtt, nil)
+	}()
+
+	return nil
+}
+
 // Unsubscribe unsubscribes from the specified topic
 func (a *Adapter) Unsubscribe(topic string) error {
 	if err := a.Validate(topic, 0); err != nil {
@@ -141,13 +229,27 @@ func (a *Adapter) Subscribe(topic string, qos byte, retained bool, callback func
 			Message: er.ErrNilCallback,
 		}
 	}
+	return a.SubscribeMessages(topic, qos, func(_ string, payload []byte) {
+		callback(string(payload))
+	})
+}
+
+// SubscribeMessages subscribes to a topic filter and passes the topic and
+// payload of every message to callback
+func (a *Adapter) SubscribeMessages(topic string, qos byte, callback func(topic string, payload []byte)) error {
+	if callback == nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Subscribe",
+			Message: er.ErrNilCallback,
+		}
+	}
 
 	if err := a.Validate(topic, qos); err != nil {
 		return err
 	}
 
 	token := a.client.Subscribe(topic, qos, func(client mq.Client, msg mq.Message) {
-		payload := string(msg.Payload())
 		a.wg.Add(1)
 		go func() {
 			defer a.wg.Done()
@@ -159,7 +261,7 @@ func (a *Adapter) Subscribe(topic string, qos byte, retained bool, callback func
 					)
 				}
 			}()
-			callback(payload)
+			callback(msg.Topic(), msg.Payload())
 		}()
 	})
 	token.Wait()
@@ -182,7 +284,93 @@ func (a *Adapter) Subscribe(topic string, qos byte, retained bool, callback func
 		}
 	}
 
-	return nil
+	return a.checkSuback(token)
+}
+
+// SubscribeFilters subscribes to every filter with one SUBSCRIBE, a message
+// matching several filters is passed to callback once per filter
+func (a *Adapter) SubscribeFilters(filters []Filter, callback func(filter, topic string, payload []byte)) error {
+	if callback == nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Subscribe",
+			Message: er.ErrNilCallback,
+		}
+	}
+
+	topics := make(map[string]byte, len(filters))
+	for _, f := range filters {
+		if err := a.Validate(f.Topic, f.QoS); err != nil {
+			return err
+		}
+		topics[f.Topic] = f.QoS
+		// Routes tell the callback which filter a message matched
+		a.client.AddRoute(f.Topic, func(client mq.Client, msg mq.Message) {
+			a.wg.Add(1)
+			go func() {
+				defer a.wg.Done()
+				defer func() {
+					if r := recover(); r != nil {
+						logger.Error("panic in subscription callback",
+							logger.Any("recover", r),
+							logger.String("topic", f.Topic),
+						)
+					}
+				}()
+				callback(f.Topic, msg.Topic(), msg.Payload())
+			}()
+		})
+	}
+
+	token := a.client.SubscribeMultiple(topics, nil)
+	if !token.WaitTimeout(30 * time.Second) {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Subscribe",
+			Message: er.ErrSubscribeFailed,
+			Raw:     fmt.Errorf("timeout waiting for subscribe token"),
+		}
+	}
+
+	if err := token.Error(); err != nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Subscribe",
+			Message: er.ErrSubscribeFailed,
+			Raw:     err,
+		}
+	}
+
+	return a.checkSuback(token)
+}
+
+// checkSuback fails the subscription if the broker refused any of its
+// filters. Paho reports the SUBACK return codes without an error and only
+// drops message routes on unsubscribe, so the refused filters are
+// unsubscribed to remove their routes.
+func (a *Adapter) checkSuback(token mq.Token) error {
+	sub, ok := token.(*mq.SubscribeToken)
+	if !ok {
+		return nil
+	}
+	var rejected []string
+	for filter, code := range sub.Result() {
+		if code == 0x80 {
+			rejected = append(rejected, filter)
+		}
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	slices.Sort(rejected)
+	a.client.Unsubscribe(rejected...).WaitTimeout(30 * time.Second)
+	return &er.Error{
+		Package: "MQTT",
+		Func:    "Subscribe",
+		Message: er.ErrSubscribeFailed,
+		Raw:     fmt.Errorf("subscription rejected by broker: %s", strings.Join(rejected, ", ")),
+	}
 }
 
 // Validate validates the topic and QoS level
@@ -210,3 +398,20 @@ func (a *Adapter) Disconnect() {
 	a.client.Disconnect(200)
 	a.wg.Wait()
 }
+
+// inMemory reads stream payloads into memory, paho can't write them itself
+func inMemory(fn string, payload any) (any, error) {
+	stream, ok := payload.(Stream)
+	if !ok {
+		return payload, nil
+	}
+	b, ok := payloadBytes(stream)
+	if !ok {
+		return nil, &er.Error{
+			Package: "MQTT",
+			Func:    fn,
+			Message: er.ErrInvalidPayload,
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,47 @@
+package mqtt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/mqtt/mqtttest"
+	"github.com/rayomqio/benchmq/pkg/config"
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+func TestAdapterSubscribeRejected(t *testing.T) {
+	broker := mqtttest.NewBroker(t)
+	broker.Reject = func(filter string) bool { return filter == "a/rejected" }
+	// Sent once the rejected filter's route is gone, paho delivers in order
+	broker.AfterUnsuback = func(c *mqtttest.Conn, _ []string) {
+		_ = c.Publish("a/rejected", []byte("x"))
+		_ = c.Publish("a/ok", []byte("x"))
+	}
+
+	cfg := &config.Config{Client: config.Client{ClientID: t.Name(), CleanSession: true}}
+	cfg.Server.Host = broker.Host()
+	cfg.Server.Port = broker.Port()
+	a := NewClient(cfg)
+	if err := a.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Disconnect()
+
+	routed := make(chan string, 2)
+	err := a.SubscribeFilters([]Filter{{Topic: "a/ok"}, {Topic: "a/rejected"}}, func(filter, _ string, _ []byte) {
+		routed <- filter
+	})
+	if !errors.Is(err, er.ErrSubscribeFailed) {
+		t.Fatalf("SubscribeFilters error = %v, want %v", err, er.ErrSubscribeFailed)
+	}
+
+	select {
+	case filter := <-routed:
+		if filter != "a/ok" {
+			t.Errorf("message routed to rejected filter %q", filter)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message")
+	}
+}
@@ -0,0 +1,361 @@
+// Package mqtttest provides a minimal in-process MQTT 3.1.1 broker for tests
+package mqtttest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Packet types of MQTT 3.1.1
+const (
+	connect     = 1
+	connack     = 2
+	publish     = 3
+	puback      = 4
+	pubrec      = 5
+	pubrel      = 6
+	pubcomp     = 7
+	subscribe   = 8
+	suback      = 9
+	unsubscribe = 10
+	unsuback    = 11
+	pingreq     = 12
+	pingresp    = 13
+	disconnect  = 14
+)
+
+// Broker accepts MQTT connections on the loopback interface. It routes
+// messages to matching subscriptions at QoS 0 and acknowledges everything
+// else, hooks let tests inject broker behavior.
+type Broker struct {
+	// Reject makes the SUBACK refuse the filters it returns true for
+	Reject func(filter string) bool
+	// AfterUnsuback runs after the UNSUBACK of every UNSUBSCRIBE was written,
+	// on the connection's read goroutine
+	AfterUnsuback func(c *Conn, filters []string)
+
+	ln net.Listener
+
+	mu        sync.Mutex
+	conns     map[*Conn]bool
+	published int
+	wg        sync.WaitGroup
+}
+
+// Conn is a client connection of the broker
+type Conn struct {
+	broker *Broker
+	conn   net.Conn
+	wmu    sync.Mutex
+	ID     string // Client ID of the CONNECT
+
+	mu   sync.Mutex
+	subs map[string]bool
+}
+
+// NewBroker starts a broker on a free loopback port, it is closed when the
+// test ends
+func NewBroker(t testing.TB) *Broker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Broker{ln: ln, conns: make(map[*Conn]bool)}
+	b.wg.Add(1)
+	go b.accept()
+	t.Cleanup(b.Close)
+	return b
+}
+
+// Addr returns the host:port the broker listens on
+func (b *Broker) Addr() string {
+	return b.ln.Addr().String()
+}
+
+// Host returns the IP address the broker listens on
+func (b *Broker) Host() string {
+	return b.ln.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port the broker listens on
+func (b *Broker) Port() uint16 {
+	return uint16(b.ln.Addr().(*net.TCPAddr).Port)
+}
+
+// URL returns the tcp:// broker URL
+func (b *Broker) URL() string {
+	return "tcp://" + b.Addr()
+}
+
+// Published returns the number of PUBLISH packets received from clients
+func (b *Broker) Published() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.published
+}
+
+// Connections returns the number of open client connections
+func (b *Broker) Connections() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.conns)
+}
+
+// Close stops accepting connections and closes the open ones
+func (b *Broker) Close() {
+	_ = b.ln.Close()
+	b.mu.Lock()
+	for c := range b.conns {
+		_ = c.conn.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+func (b *Broker) accept() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &Conn{broker: b, conn: conn, subs: make(map[string]bool)}
+		b.mu.Lock()
+		b.conns[c] = true
+		b.mu.Unlock()
+		b.wg.Add(1)
+		go c.serve()
+	}
+}
+
+// Publish sends a QoS 0 PUBLISH of topic to the client
+func (c *Conn) Publish(topic string, payload []byte) error {
+	body := appendString(nil, topic)
+	return c.write(publish<<4, append(body, payload...))
+}
+
+func (c *Conn) serve() {
+	defer c.broker.wg.Done()
+	defer func() {
+		_ = c.conn.Close()
+		c.broker.mu.Lock()
+		delete(c.broker.conns, c)
+		c.broker.mu.Unlock()
+	}()
+
+	r := bufio.NewReader(c.conn)
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case connect:
+			c.ID = connectClientID(body)
+			err = c.write(connack<<4, []byte{0, 0})
+		case publish:
+			err = c.handlePublish(header, body)
+		case pubrel:
+			err = c.write(pubcomp<<4, body[:2])
+		case subscribe:
+			err = c.handleSubscribe(body)
+		case unsubscribe:
+			err = c.handleUnsubscribe(body)
+		case pingreq:
+			err = c.write(pingresp<<4, nil)
+		case disconnect:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *Conn) handlePublish(header byte, body []byte) error {
+	topic, rest, err := readString(body)
+	if err != nil {
+		return err
+	}
+	switch qos := (header >> 1) & 0x03; qos {
+	case 1:
+		err = c.write(puback<<4, rest[:2])
+		rest = rest[2:]
+	case 2:
+		err = c.write(pubrec<<4, rest[:2])
+		rest = rest[2:]
+	}
+	if err != nil {
+		return err
+	}
+
+	b := c.broker
+	b.mu.Lock()
+	b.published++
+	conns := make([]*Conn, 0, len(b.conns))
+	for conn := range b.conns {
+		conns = append(conns, conn)
+	}
+	b.mu.Unlock()
+
+	for _, conn := range conns {
+		if conn.subscribed(topic) {
+			_ = conn.Publish(topic, rest)
+		}
+	}
+	return nil
+}
+
+func (c *Conn) handleSubscribe(body []byte) error {
+	id, rest := body[:2], body[2:]
+	codes := []byte{}
+	for len(rest) > 0 {
+		filter, next, err := readString(rest)
+		if err != nil || len(next) == 0 {
+			return errors.New("malformed SUBSCRIBE")
+		}
+		qos := min(next[0], 2)
+		rest = next[1:]
+
+		if c.broker.Reject != nil && c.broker.Reject(filter) {
+			codes = append(codes, 0x80)
+			continue
+		}
+		c.mu.Lock()
+		c.subs[filter] = true
+		c.mu.Unlock()
+		codes = append(codes, qos)
+	}
+	return c.write(suback<<4, append(append([]byte{}, id...), codes...))
+}
+
+func (c *Conn) handleUnsubscribe(body []byte) error {
+	id, rest := body[:2], body[2:]
+	var filters []string
+	for len(rest) > 0 {
+		filter, next, err := readString(rest)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, filter)
+		rest = next
+	}
+
+	c.mu.Lock()
+	for _, f := range filters {
+		delete(c.subs, f)
+	}
+	c.mu.Unlock()
+
+	if err := c.write(unsuback<<4, id); err != nil {
+		return err
+	}
+	if c.broker.AfterUnsuback != nil {
+		c.broker.AfterUnsuback(c, filters)
+	}
+	return nil
+}
+
+func (c *Conn) subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for filter := range c.subs {
+		if match(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Conn) write(header byte, body []byte) error {
+	packet := []byte{header}
+	for n := len(body); ; {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.conn.Write(append(packet, body...))
+	return err
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+// connectClientID returns the client ID of a CONNECT body
+func connectClientID(body []byte) string {
+	// Protocol name, level, flags and keep alive precede the payload
+	_, rest, err := readString(body)
+	if err != nil || len(rest) < 4 {
+		return ""
+	}
+	id, _, _ := readString(rest[4:])
+	return id
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("short string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("short string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// match reports whether topic matches filter with the + and # wildcards
+func match(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		switch {
+		case level == "#":
+			return true
+		case i >= len(t):
+			return false
+		case level != "+" && level != t[i]:
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
@@ -0,0 +1,966 @@
+package mqtt
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/config"
+	"github.com/rayomqio/benchmq/pkg/er"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+const (
+	// ackTimeout bounds the wait for acknowledgements
+	ackTimeout = 30 * time.Second
+	// readBufferSize keeps the per-connection read buffer small, packet
+	// bodies get their own allocation
+	readBufferSize = 512
+	// coalesceLimit is the payload size up to which the PUBLISH header and
+	// payload are written with a single call
+	coalesceLimit = 4096
+	// chunkTimeout bounds every write of a streamed payload, a stalled
+	// broker can't hold the connection's writes forever
+	chunkTimeout = 30 * time.Second
+)
+
+// Native is a minimal MQTT 3.1.1 client designed for a low memory footprint
+// per connection. It runs a reader goroutine and a dispatcher goroutine for
+// subscription callbacks, writes packets straight to the connection and
+// supports publishing with QoS 0 and 1.
+type Native struct {
+	uri     *url.URL
+	connect connectPacket
+	dialer  *dialer
+	phases  func(Phases)
+	noRoute func(topic string, payload []byte) // Receives messages no route matches
+
+	conn net.Conn
+	wmu  sync.Mutex // Serializes packet writes
+
+	mu      sync.Mutex // Guards the fields below
+	nextID  uint16
+	pending map[uint16]*pendingAck
+	routes  []*route
+	closed  bool
+	ping    *time.Timer
+	pong    *time.Timer     // Closes the connection when a PINGRESP is overdue
+	pings   []chan struct{} // Closed by the PINGRESPs in the order the PINGREQs went out
+	inbox   *inbox
+	done    chan struct{}
+}
+
+// pendingAck is an outstanding PUBACK, SUBACK or UNSUBACK
+type pendingAck struct {
+	callback func(err error)
+	timer    *time.Timer
+}
+
+// route dispatches messages matching filter to callback
+type route struct {
+	filter   string
+	callback func(topic string, payload []byte)
+}
+
+// delivery is a received message and the routes it matched, or an
+// acknowledgement that completes in order with the messages
+type delivery struct {
+	topic   string
+	payload []byte
+	routes  []route
+	ack     func()
+}
+
+// inbox queues the messages of a connection for its dispatcher, so callbacks
+// run in order without blocking the read loop
+type inbox struct {
+	mu      sync.Mutex
+	queue   []delivery
+	wake    chan struct{}
+	drained chan struct{} // Closed once the last message was dispatched
+}
+
+func newInbox() *inbox {
+	return &inbox{wake: make(chan struct{}, 1), drained: make(chan struct{})}
+}
+
+func (in *inbox) push(d delivery) {
+	in.mu.Lock()
+	in.queue = append(in.queue, d)
+	in.mu.Unlock()
+
+	select {
+	case in.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (in *inbox) take() []delivery {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	queue := in.queue
+	in.queue = nil
+	return queue
+}
+
+// NewNativeClient creates a new native MQTT client instance
+func NewNativeClient(cfg *config.Config, options ...Option) *Native {
+	o := newOptions(options)
+
+	n := &Native{
+		uri: o.brokerURL(cfg),
+		connect: connectPacket{
+			clientID:     cfg.Client.ClientID,
+			keepAlive:    cfg.Client.KeepAlive,
+			cleanSession: cfg.Client.CleanSession,
+			username:     cfg.Client.Username,
+			password:     cfg.Client.Password,
+		},
+		dialer:  o.dialer(),
+		phases:  o.phases,
+		noRoute: o.noRoute,
+		pending: make(map[uint16]*pendingAck),
+		closed:  true,
+	}
+	if o.will != nil {
+		n.connect.willTopic = o.will.topic
+		n.connect.willPayload = o.will.payload
+		n.connect.willQoS = o.will.qos
+		n.connect.willRetain = o.will.retained
+	}
+	return n
+}
+
+// Connect establishes a connection to the MQTT broker and waits for CONNACK
+func (n *Native) Connect() error {
+	// MQTT-3.1.2-22: the password flag needs the user name flag
+	if n.connect.password != "" && n.connect.username == "" {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Connect",
+			Message: er.ErrPasswordWithoutUser,
+		}
+	}
+
+	conn, timing, err := n.dialer.open(n.uri)
+	if err != nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Connect",
+			Message: er.ErrMqttConnectionFailed,
+			Raw:     err,
+		}
+	}
+
+	r := bufio.NewReaderSize(conn, readBufferSize)
+	if err := n.handshake(conn, r); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if n.phases != nil {
+		n.phases(timing.connected())
+	}
+
+	in := newInbox()
+	n.mu.Lock()
+	n.conn = conn
+	n.closed = false
+	n.inbox = in
+	n.done = make(chan struct{})
+	done := n.done
+	if n.connect.keepAlive > 0 {
+		n.ping = time.AfterFunc(n.pingInterval(), n.keepAlive)
+	}
+	n.mu.Unlock()
+
+	go n.readLoop(r, in)
+	go n.dispatchLoop(in, done)
+	return nil
+}
+
+// handshake sends CONNECT and validates the CONNACK
+func (n *Native) handshake(conn net.Conn, r *bufio.Reader) error {
+	_ = conn.SetDeadline(time.Now().Add(n.dialer.timeout))
+	defer func() { _ = conn.SetDeadline(time.Time{}) }()
+
+	if _, err := conn.Write(n.connect.encode()); err != nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Connect",
+			Message: er.ErrMqttConnectionFailed,
+			Raw:     err,
+		}
+	}
+
+	header, body, err := readPacket(r)
+	if err != nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Connect",
+			Message: er.ErrMqttConnectionFailed,
+			Raw:     err,
+		}
+	}
+	if header>>4 != packetConnack || len(body) < 2 {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Connect",
+			Message: er.ErrMqttConnectionFailed,
+			Raw:     fmt.Errorf("expected CONNACK, got packet type %d", header>>4),
+		}
+	}
+	if body[1] != 0 {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Connect",
+			Message: er.ErrConnectionRefused,
+			Raw:     fmt.Errorf("return code %d", body[1]),
+		}
+	}
+	return nil
+}
+
+// Publish publishes a message and waits for its acknowledgement
+func (n *Native) Publish(topic string, qos byte, retained bool, payload any, callback func()) error {
+	if callback == nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Publish",
+			Message: er.ErrNilCallback,
+		}
+	}
+
+	done := make(chan error, 1)
+	if err := n.publish("Publish", topic, qos, retained, payload, func(err error) { done <- err }); err != nil {
+		return err
+	}
+	if err := <-done; err != nil {
+		return err
+	}
+
+	callback()
+	return nil
+}
+
+// PublishAsync publishes a message without waiting for its acknowledgement,
+// the callback receives the PUBACK round-trip time (network write for QoS 0)
+func (n *Native) PublishAsync(topic string, qos byte, retained bool, payload any, callback func(rtt time.Duration, err error)) error {
+	if callback == nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "PublishAsync",
+			Message: er.ErrNilCallback,
+		}
+	}
+
+	start := time.Now()
+	return n.publish("PublishAsync", topic, qos, retained, payload, func(err error) {
+		callback(time.Since(start), err)
+	})
+}
+
+// publish writes a PUBLISH packet, done is called once it is acknowledged
+func (n *Native) publish(fn, topic string, qos byte, retained bool, payload any, done func(err error)) error {
+	if err := n.Validate(topic, qos); err != nil {
+		return err
+	}
+	if qos > 1 {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    fn,
+			Message: er.ErrUnsupportedQoS,
+			Raw:     fmt.Errorf("qos %d", qos),
+		}
+	}
+	// Streams are written in chunks as they are produced
+	stream, streamed := payload.(Stream)
+	var body []byte
+	size := 0
+	if streamed {
+		size = stream.Len()
+	} else {
+		var ok bool
+		if body, ok = payloadBytes(payload); !ok {
+			return &er.Error{
+				Package: "MQTT",
+				Func:    fn,
+				Message: er.ErrInvalidPayload,
+			}
+		}
+		size = len(body)
+	}
+
+	if size > MaxPayloadSize(topic, qos) {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    fn,
+			Message: er.ErrPublishFailed,
+			Raw:     fmt.Errorf("packet exceeds the maximum MQTT packet size"),
+		}
+	}
+
+	var id uint16
+	if qos > 0 {
+		var err error
+		id, err = n.register(fn, func(err error) {
+			if err != nil {
+				err = &er.Error{Package: "MQTT", Func: fn, Message: er.ErrPublishFailed, Raw: err}
+			}
+			done(err)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	header := publishHeader(topic, qos, retained, false, id, size)
+	var err error
+	switch {
+	case streamed:
+		err = n.writeStream(header, stream)
+	case len(body) <= coalesceLimit:
+		err = n.write(append(header, body...))
+	default:
+		err = n.write(header, body)
+	}
+	if err != nil {
+		if qos > 0 {
+			n.drop(id)
+		}
+		return &er.Error{
+			Package: "MQTT",
+			Func:    fn,
+			Message: er.ErrPublishFailed,
+			Raw:     err,
+		}
+	}
+
+	if qos == 0 {
+		done(nil)
+	}
+	return nil
+}
+
+// Subscribe subscribes to the topic filter and waits for the SUBACK
+func (n *Native) Subscribe(topic string, qos byte, retained bool, callback func(payload string)) error {
+	if callback == nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Subscribe",
+			Message: er.ErrNilCallback,
+		}
+	}
+	return n.SubscribeMessages(topic, qos, func(_ string, payload []byte) {
+		callback(string(payload))
+	})
+}
+
+// SubscribeMessages subscribes to the topic filter and waits for the SUBACK,
+// callback receives the topic and payload of every matching message
+func (n *Native) SubscribeMessages(topic string, qos byte, callback func(topic string, payload []byte)) error {
+	if callback == nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Subscribe",
+			Message: er.ErrNilCallback,
+		}
+	}
+	if err := n.Validate(topic, qos); err != nil {
+		return err
+	}
+
+	r := &route{filter: topic, callback: callback}
+	n.mu.Lock()
+	n.routes = append(n.routes, r)
+	n.mu.Unlock()
+
+	err := n.request("Subscribe", func(id uint16) []byte {
+		return encodeSubscribe(id, []string{topic}, []byte{qos})
+	}, nil)
+	if err != nil {
+		n.removeRoutes(r)
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Subscribe",
+			Message: er.ErrSubscribeFailed,
+			Raw:     err,
+		}
+	}
+	return nil
+}
+
+// SubscribeFilters subscribes to every filter with one SUBSCRIBE and waits
+// for the SUBACK, a message matching several filters is passed to callback
+// once per filter
+func (n *Native) SubscribeFilters(filters []Filter, callback func(filter, topic string, payload []byte)) error {
+	if callback == nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Subscribe",
+			Message: er.ErrNilCallback,
+		}
+	}
+
+	topics := make([]string, len(filters))
+	qos := make([]byte, len(filters))
+	routes := make([]*route, len(filters))
+	for i, f := range filters {
+		if err := n.Validate(f.Topic, f.QoS); err != nil {
+			return err
+		}
+		topics[i], qos[i] = f.Topic, f.QoS
+		routes[i] = &route{filter: f.Topic, callback: func(topic string, payload []byte) {
+			callback(f.Topic, topic, payload)
+		}}
+	}
+
+	n.mu.Lock()
+	n.routes = append(n.routes, routes...)
+	n.mu.Unlock()
+
+	err := n.request("Subscribe", func(id uint16) []byte {
+		return encodeSubscribe(id, topics, qos)
+	}, nil)
+	if err != nil {
+		n.removeRoutes(routes...)
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Subscribe",
+			Message: er.ErrSubscribeFailed,
+			Raw:     err,
+		}
+	}
+	return nil
+}
+
+// Unsubscribe unsubscribes from the topic filter and waits for the UNSUBACK
+// and the callbacks of the messages received before it, so it can't be called
+// from a subscription callback
+func (n *Native) Unsubscribe(topic string) error {
+	return n.UnsubscribeAcked(topic, nil)
+}
+
+// UnsubscribeAcked unsubscribes like Unsubscribe, acked runs on the dispatcher
+// after the callbacks of the messages received before the UNSUBACK and before
+// those of the messages received after it
+func (n *Native) UnsubscribeAcked(topic string, acked func()) error {
+	if err := n.Validate(topic, 0); err != nil {
+		return err
+	}
+
+	err := n.request("Unsubscribe", func(id uint16) []byte {
+		return encodeUnsubscribe(id, []string{topic})
+	}, acked)
+	if err != nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Unsubscribe",
+			Message: er.ErrUnsubscribeFailed,
+			Raw:     err,
+		}
+	}
+	n.removeRoute(topic)
+	return nil
+}
+
+// Validate validates the topic and QoS level
+func (n *Native) Validate(topic string, qos byte) error {
+	if topic == "" {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Validate",
+			Message: er.ErrEmptyTopic,
+		}
+	}
+	if qos > 2 {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Validate",
+			Message: er.ErrInvalidQoS,
+		}
+	}
+	return nil
+}
+
+// Disconnect sends DISCONNECT, closes the connection and waits until the
+// messages received before were dispatched
+func (n *Native) Disconnect() {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	in := n.inbox
+	n.mu.Unlock()
+
+	_ = n.write(disconnectPacket)
+	n.close(nil)
+	<-in.drained
+}
+
+// Close drops the network connection without sending DISCONNECT, so the
+// broker treats it as an abnormal disconnect
+func (n *Native) Close() {
+	n.mu.Lock()
+	in := n.inbox
+	n.mu.Unlock()
+
+	n.close(nil)
+	if in != nil {
+		<-in.drained
+	}
+}
+
+// Done returns a channel that is closed once the connection is gone, either
+// closed locally or by the broker
+func (n *Native) Done() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.done
+}
+
+// WriteRaw writes an already encoded packet to the connection without any
+// validation, it gives protocol tests access to malformed packets
+func (n *Native) WriteRaw(packet []byte) error {
+	return n.write(packet)
+}
+
+// request writes a packet built around a fresh packet id and waits for the
+// acknowledgement carrying the same id. acked, if set, runs where a successful
+// acknowledgement is handled before request returns.
+func (n *Native) request(fn string, build func(id uint16) []byte, acked func()) error {
+	done := make(chan error, 1)
+	id, err := n.register(fn, func(err error) {
+		if err == nil && acked != nil {
+			acked()
+		}
+		done <- err
+	})
+	if err != nil {
+		return err
+	}
+	if err := n.write(build(id)); err != nil {
+		n.drop(id)
+		return err
+	}
+	return <-done
+}
+
+// register reserves a packet id for an outstanding acknowledgement
+func (n *Native) register(fn string, callback func(err error)) (uint16, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return 0, &er.Error{
+			Package: "MQTT",
+			Func:    fn,
+			Message: er.ErrNotConnected,
+		}
+	}
+
+	for range 65535 {
+		n.nextID++
+		if n.nextID == 0 {
+			n.nextID = 1
+		}
+		if _, used := n.pending[n.nextID]; used {
+			continue
+		}
+
+		id := n.nextID
+		n.pending[id] = &pendingAck{
+			callback: callback,
+			timer: time.AfterFunc(ackTimeout, func() {
+				n.complete(id, er.ErrAckTimeout)
+			}),
+		}
+		return id, nil
+	}
+
+	return 0, &er.Error{
+		Package: "MQTT",
+		Func:    fn,
+		Message: er.ErrPublishFailed,
+		Raw:     fmt.Errorf("no packet identifiers available"),
+	}
+}
+
+// complete resolves the outstanding acknowledgement id with err
+func (n *Native) complete(id uint16, err error) {
+	n.mu.Lock()
+	ack, ok := n.pending[id]
+	delete(n.pending, id)
+	n.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	ack.timer.Stop()
+	ack.callback(err)
+}
+
+// drop releases the packet id of a packet that was never sent
+func (n *Native) drop(id uint16) {
+	n.mu.Lock()
+	ack, ok := n.pending[id]
+	delete(n.pending, id)
+	n.mu.Unlock()
+
+	if ok {
+		ack.timer.Stop()
+	}
+}
+
+// write writes the packet parts to the connection as one unit
+func (n *Native) write(parts ...[]byte) error {
+	n.mu.Lock()
+	conn, closed := n.conn, n.closed
+	n.mu.Unlock()
+	if closed {
+		return er.ErrNotConnected
+	}
+
+	n.wmu.Lock()
+	defer n.wmu.Unlock()
+	for _, part := range parts {
+		if _, err := conn.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStream writes a packet header followed by a streamed payload. A
+// stream that fails halfway leaves a truncated packet behind, the connection
+// is closed as it can't carry further packets.
+func (n *Native) writeStream(header []byte, stream Stream) error {
+	n.mu.Lock()
+	conn, closed := n.conn, n.closed
+	n.mu.Unlock()
+	if closed {
+		return er.ErrNotConnected
+	}
+
+	n.wmu.Lock()
+	defer n.wmu.Unlock()
+	defer func() { _ = conn.SetWriteDeadline(time.Time{}) }()
+	w := &deadlineWriter{conn: conn, timeout: chunkTimeout}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	written, err := stream.WriteTo(w)
+	if err == nil && written != int64(stream.Len()) {
+		err = fmt.Errorf("stream wrote %d of %d bytes", written, stream.Len())
+	}
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	return nil
+}
+
+// deadlineWriter sets a fresh write deadline before every write
+type deadlineWriter struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	if err := w.conn.SetWriteDeadline(time.Now().Add(w.timeout)); err != nil {
+		return 0, err
+	}
+	return w.conn.Write(p)
+}
+
+// Ping sends PINGREQ and waits for the PINGRESP. The broker answers once it
+// read every packet sent before, so the round trip confirms it received them.
+func (n *Native) Ping() error {
+	pong, err := n.sendPing()
+	if err != nil {
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Ping",
+			Message: er.ErrNotConnected,
+			Raw:     err,
+		}
+	}
+
+	n.mu.Lock()
+	done := n.done
+	n.mu.Unlock()
+
+	timer := time.NewTimer(ackTimeout)
+	defer timer.Stop()
+	select {
+	case <-pong:
+		return nil
+	case <-done:
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Ping",
+			Message: er.ErrConnectionLost,
+		}
+	case <-timer.C:
+		return &er.Error{
+			Package: "MQTT",
+			Func:    "Ping",
+			Message: er.ErrAckTimeout,
+		}
+	}
+}
+
+// sendPing writes PINGREQ, the returned channel is closed by its PINGRESP
+func (n *Native) sendPing() (<-chan struct{}, error) {
+	n.mu.Lock()
+	conn, closed := n.conn, n.closed
+	n.mu.Unlock()
+	if closed {
+		return nil, er.ErrNotConnected
+	}
+
+	// Queued under the write lock, so waiters are in the order of the PINGREQs
+	pong := make(chan struct{})
+	n.wmu.Lock()
+	defer n.wmu.Unlock()
+	n.mu.Lock()
+	n.pings = append(n.pings, pong)
+	n.mu.Unlock()
+	if _, err := conn.Write(pingreqPacket); err != nil {
+		return nil, err
+	}
+	return pong, nil
+}
+
+// readLoop reads incoming packets until the connection closes, messages are
+// queued in the inbox
+func (n *Native) readLoop(r *bufio.Reader, in *inbox) {
+	n.mu.Lock()
+	done := n.done
+	n.mu.Unlock()
+	defer close(done)
+
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			n.close(err)
+			return
+		}
+
+		switch header >> 4 {
+		case packetPublish:
+			n.handlePublish(header, body, in)
+		case packetPuback:
+			if id, err := packetID(body); err == nil {
+				n.complete(id, nil)
+			}
+		case packetUnsuback:
+			// Messages received before the UNSUBACK are dispatched before
+			// Unsubscribe returns
+			if id, err := packetID(body); err == nil {
+				in.push(delivery{ack: func() { n.complete(id, nil) }})
+			}
+		case packetSuback:
+			id, err := packetID(body)
+			if err != nil {
+				continue
+			}
+			var ackErr error
+			for _, code := range body[2:] {
+				if code == 0x80 {
+					ackErr = fmt.Errorf("subscription rejected by broker")
+				}
+			}
+			n.complete(id, ackErr)
+		case packetPubrel:
+			// Inbound QoS 2 flow, delivery already happened on PUBLISH
+			if id, err := packetID(body); err == nil {
+				_ = n.write(encodeAck(packetPubcomp, id))
+			}
+		case packetPingresp:
+			n.mu.Lock()
+			if n.pong != nil {
+				n.pong.Stop()
+				n.pong = nil
+			}
+			if len(n.pings) > 0 {
+				close(n.pings[0])
+				n.pings = n.pings[1:]
+			}
+			n.mu.Unlock()
+		}
+	}
+}
+
+// handlePublish acknowledges an incoming PUBLISH and queues it for the
+// matching subscriptions
+func (n *Native) handlePublish(header byte, body []byte, in *inbox) {
+	topic, id, payload, err := parsePublish(header, body)
+	if err != nil {
+		return
+	}
+
+	switch (header >> 1) & 0x03 {
+	case 1:
+		_ = n.write(encodeAck(packetPuback, id))
+	case 2:
+		_ = n.write(encodeAck(packetPubrec, id))
+	}
+
+	n.mu.Lock()
+	routes := n.routes
+	n.mu.Unlock()
+
+	var matched []route
+	for _, r := range routes {
+		if TopicMatch(r.filter, topic) {
+			matched = append(matched, *r)
+		}
+	}
+	if len(matched) == 0 && n.noRoute != nil {
+		matched = append(matched, route{callback: n.noRoute})
+	}
+	if len(matched) > 0 {
+		in.push(delivery{topic: topic, payload: payload, routes: matched})
+	}
+}
+
+// dispatchLoop runs the callbacks of queued messages in the order they were
+// received, after the read loop ended it dispatches what is left and closes
+// drained
+func (n *Native) dispatchLoop(in *inbox, done <-chan struct{}) {
+	defer close(in.drained)
+
+	deliver := func(queue []delivery) {
+		for _, d := range queue {
+			if d.ack != nil {
+				d.ack()
+			}
+			for _, r := range d.routes {
+				n.dispatch(r, d.topic, d.payload)
+			}
+		}
+	}
+	for {
+		if queue := in.take(); len(queue) > 0 {
+			deliver(queue)
+			continue
+		}
+		select {
+		case <-in.wake:
+		case <-done:
+			// The read loop queues nothing after done is closed
+			deliver(in.take())
+			return
+		}
+	}
+}
+
+func (n *Native) dispatch(r route, topic string, payload []byte) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			logger.Error("panic in subscription callback",
+				logger.Any("recover", rec),
+				logger.String("topic", topic),
+			)
+		}
+	}()
+	r.callback(topic, payload)
+}
+
+// removeRoute drops the subscription route of filter
+func (n *Native) removeRoute(filter string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	routes := make([]*route, 0, len(n.routes))
+	for _, r := range n.routes {
+		if r.filter != filter {
+			routes = append(routes, r)
+		}
+	}
+	n.routes = routes
+}
+
+// removeRoutes drops the given routes, other routes of the same filters stay
+func (n *Native) removeRoutes(remove ...*route) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	routes := make([]*route, 0, len(n.routes))
+	for _, r := range n.routes {
+		if !slices.Contains(remove, r) {
+			routes = append(routes, r)
+		}
+	}
+	n.routes = routes
+}
+
+// keepAlive sends PINGREQ and re-arms the timer. The connection is closed
+// when no PINGRESP arrives within one and a half keep alive periods.
+func (n *Native) keepAlive() {
+	if _, err := n.sendPing(); err != nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	if n.pong == nil {
+		timeout := time.Duration(n.connect.keepAlive) * time.Second * 3 / 2
+		conn := n.conn
+		n.pong = time.AfterFunc(timeout, func() {
+			n.mu.Lock()
+			current := n.conn == conn
+			n.mu.Unlock()
+			if current {
+				n.close(fmt.Errorf("no PINGRESP within %s", timeout))
+			}
+		})
+	}
+	n.ping.Reset(n.pingInterval())
+}
+
+// pingInterval sends pings ahead of the keep alive deadline
+func (n *Native) pingInterval() time.Duration {
+	return time.Duration(n.connect.keepAlive) * time.Second * 3 / 4
+}
+
+// close shuts the connection down and fails all outstanding acknowledgements
+func (n *Native) close(cause error) {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	if n.ping != nil {
+		n.ping.Stop()
+	}
+	if n.pong != nil {
+		n.pong.Stop()
+		n.pong = nil
+	}
+	n.pings = nil
+	pending := n.pending
+	n.pending = make(map[uint16]*pendingAck)
+	conn := n.conn
+	n.mu.Unlock()
+
+	_ = conn.Close()
+
+	err := error(er.ErrConnectionLost)
+	if cause == nil {
+		err = er.ErrNotConnected
+	}
+	for _, ack := range pending {
+		ack.timer.Stop()
+		ack.callback(err)
+	}
+}
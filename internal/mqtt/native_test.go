@@ -0,0 +1,86 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/mqtt/mqtttest"
+	"github.com/rayomqio/benchmq/pkg/config"
+)
+
+// newTestNative connects a native client to broker
+func newTestNative(t *testing.T, broker *mqtttest.Broker, options ...Option) *Native {
+	t.Helper()
+	cfg := &config.Config{Client: config.Client{ClientID: t.Name(), CleanSession: true}}
+	cfg.Server.Host = broker.Host()
+	cfg.Server.Port = broker.Port()
+	n := NewNativeClient(cfg, options...)
+	if err := n.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(n.Disconnect)
+	return n
+}
+
+func TestUnsubscribeAcked(t *testing.T) {
+	broker := mqtttest.NewBroker(t)
+	// A message the broker sends right after the UNSUBACK
+	broker.AfterUnsuback = func(c *mqtttest.Conn, filters []string) {
+		_ = c.Publish(filters[0], []byte("late"))
+	}
+
+	// Like the churn benchmark, routed and unrouted messages share a callback
+	events := make(chan string, 2)
+	receive := func(topic string, _ []byte) { events <- topic }
+	n := newTestNative(t, broker, WithUnrouted(receive))
+
+	if err := n.SubscribeMessages("churn/0", 0, receive); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.UnsubscribeAcked("churn/0", func() { events <- "acked" }); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"acked", "churn/0"}
+	for _, w := range want {
+		select {
+		case got := <-events:
+			if got != w {
+				t.Fatalf("event = %q, want %q", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", w)
+		}
+	}
+}
+
+func TestFailedSubscribeKeepsRoutes(t *testing.T) {
+	broker := mqtttest.NewBroker(t)
+	n := newTestNative(t, broker)
+
+	received := make(chan string, 1)
+	if err := n.SubscribeMessages("a/b", 0, func(topic string, _ []byte) { received <- topic }); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rejected subscription to the same filter drops only its own route
+	broker.Reject = func(string) bool { return true }
+	if err := n.SubscribeMessages("a/b", 1, func(string, []byte) {}); err == nil {
+		t.Fatal("SubscribeMessages succeeded, want rejected")
+	}
+	if err := n.SubscribeFilters([]Filter{{Topic: "a/b"}, {Topic: "a/c"}}, func(string, string, []byte) {}); err == nil {
+		t.Fatal("SubscribeFilters succeeded, want rejected")
+	}
+
+	if err := n.Publish("a/b", 0, false, []byte("x"), func() {}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case topic := <-received:
+		if topic != "a/b" {
+			t.Errorf("topic = %q, want a/b", topic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("route of the first subscription was removed")
+	}
+}
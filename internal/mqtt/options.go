@@ -0,0 +1,142 @@
+package mqtt
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/config"
+)
+
+// defaultConnectTimeout bounds dialing and the CONNECT handshake
+const defaultConnectTimeout = 30 * time.Second
+
+// options holds the optional client settings
+type options struct {
+	traffic *Traffic
+	will    *will
+	broker  *url.URL
+	phases  func(Phases)
+	local   *net.TCPAddr
+	proxy   *url.URL
+	pp      byte
+	ppSrc   *net.TCPAddr
+	via     string                             // Address dialed instead of the broker host
+	noRoute func(topic string, payload []byte) // Receives messages no subscription matches
+}
+
+// will holds the last will and testament of a client
+type will struct {
+	topic    string
+	payload  []byte
+	qos      byte
+	retained bool
+}
+
+// Option configures optional client behaviour
+type Option func(*options)
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, option := range opts {
+		if option != nil {
+			option(&o)
+		}
+	}
+	return o
+}
+
+// WithTraffic records the wire-level bytes of the connection into traffic
+func WithTraffic(traffic *Traffic) Option {
+	return func(o *options) {
+		o.traffic = traffic
+	}
+}
+
+// WithWill sets the message the broker publishes when the client disconnects
+// without sending DISCONNECT
+func WithWill(topic string, payload []byte, qos byte, retained bool) Option {
+	return func(o *options) {
+		o.will = &will{topic: topic, payload: payload, qos: qos, retained: retained}
+	}
+}
+
+// WithBroker connects to uri instead of the server in the config, the scheme
+// selects the transport (tcp, ssl, ws, wss)
+func WithBroker(uri *url.URL) Option {
+	return func(o *options) {
+		o.broker = uri
+	}
+}
+
+// WithHandshake passes the phase timings of every successful connection to
+// record
+func WithHandshake(record func(Phases)) Option {
+	return func(o *options) {
+		o.phases = record
+	}
+}
+
+// WithLocalAddr binds the connection to a local address, a zero port lets
+// the kernel choose one
+func WithLocalAddr(addr *net.TCPAddr) Option {
+	return func(o *options) {
+		o.local = addr
+	}
+}
+
+// WithProxy connects through a SOCKS5 or HTTP CONNECT proxy, see ParseProxy
+func WithProxy(proxy *url.URL) Option {
+	return func(o *options) {
+		o.proxy = proxy
+	}
+}
+
+// WithProxyProtocol sends a PROXY protocol header of the given version right
+// after connecting, announcing src as the client address
+func WithProxyProtocol(version byte, src *net.TCPAddr) Option {
+	return func(o *options) {
+		o.pp = version
+		o.ppSrc = src
+	}
+}
+
+// WithDialAddr connects the TCP socket to addr, like a local chaos proxy,
+// instead of the broker host. TLS and WebSocket keep the broker host for the
+// server name and the Host header. A proxy takes precedence.
+func WithDialAddr(addr string) Option {
+	return func(o *options) {
+		o.via = addr
+	}
+}
+
+// WithUnrouted passes messages that match none of the subscriptions of the
+// client to handler instead of dropping them, like messages a broker sends
+// after acknowledging an UNSUBSCRIBE
+func WithUnrouted(handler func(topic string, payload []byte)) Option {
+	return func(o *options) {
+		o.noRoute = handler
+	}
+}
+
+// dialer builds the connection dialer from the options
+func (o *options) dialer() *dialer {
+	return &dialer{
+		traffic:       o.traffic,
+		timeout:       defaultConnectTimeout,
+		localAddr:     o.local,
+		via:           o.via,
+		proxy:         o.proxy,
+		proxyProto:    o.pp,
+		proxyProtoSrc: o.ppSrc,
+	}
+}
+
+// brokerURL returns the broker set with WithBroker, or the address of cfg
+func (o *options) brokerURL(cfg *config.Config) *url.URL {
+	if o.broker != nil {
+		return o.broker
+	}
+	return &url.URL{Scheme: "tcp", Host: fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)}
+}
@@ -0,0 +1,249 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	packetConnect     byte = 1
+	packetConnack     byte = 2
+	packetPublish     byte = 3
+	packetPuback      byte = 4
+	packetPubrec      byte = 5
+	packetPubrel      byte = 6
+	packetPubcomp     byte = 7
+	packetSubscribe   byte = 8
+	packetSuback      byte = 9
+	packetUnsubscribe byte = 10
+	packetUnsuback    byte = 11
+	packetPingreq     byte = 12
+	packetPingresp    byte = 13
+	packetDisconnect  byte = 14
+)
+
+// maxRemainingLength is the largest remaining length the protocol can encode
+const maxRemainingLength = 268435455
+
+var (
+	pingreqPacket    = []byte{packetPingreq << 4, 0}
+	disconnectPacket = []byte{packetDisconnect << 4, 0}
+)
+
+// connectPacket holds the CONNECT fields
+type connectPacket struct {
+	clientID     string
+	keepAlive    uint16
+	cleanSession bool
+	username     string
+	password     string
+	willTopic    string
+	willPayload  []byte
+	willQoS      byte
+	willRetain   bool
+}
+
+// encode serializes the CONNECT packet
+func (c *connectPacket) encode() []byte {
+	var flags byte
+	if c.cleanSession {
+		flags |= 0x02
+	}
+	if c.willTopic != "" {
+		flags |= 0x04 | c.willQoS<<3
+		if c.willRetain {
+			flags |= 0x20
+		}
+	}
+	if c.username != "" {
+		flags |= 0x80
+	}
+	if c.password != "" {
+		flags |= 0x40
+	}
+
+	body := make([]byte, 0, 16+len(c.clientID)+len(c.username)+len(c.password))
+	body = appendString(body, "MQTT")
+	body = append(body, 4, flags) // Protocol level 4 is MQTT 3.1.1
+	body = binary.BigEndian.AppendUint16(body, c.keepAlive)
+	body = appendString(body, c.clientID)
+	if c.willTopic != "" {
+		body = appendString(body, c.willTopic)
+		body = appendBytes(body, c.willPayload)
+	}
+	if c.username != "" {
+		body = appendString(body, c.username)
+	}
+	if c.password != "" {
+		body = appendString(body, c.password)
+	}
+
+	return appendPacket(packetConnect<<4, body)
+}
+
+// publishHeader serializes the PUBLISH fixed and variable header for a
+// payload of the given size, the payload itself is written separately
+func publishHeader(topic string, qos byte, retain, dup bool, id uint16, payloadSize int) []byte {
+	header := packetPublish<<4 | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	if dup {
+		header |= 0x08
+	}
+
+	length := 2 + len(topic) + payloadSize
+	if qos > 0 {
+		length += 2
+	}
+
+	buf := make([]byte, 0, 5+2+len(topic)+2)
+	buf = append(buf, header)
+	buf = appendRemainingLength(buf, length)
+	buf = appendString(buf, topic)
+	if qos > 0 {
+		buf = binary.BigEndian.AppendUint16(buf, id)
+	}
+	return buf
+}
+
+// EncodePublish serializes a complete PUBLISH packet without validating the
+// topic, it is meant for raw protocol tests
+func EncodePublish(topic string, qos byte, retained bool, id uint16, payload []byte) []byte {
+	return append(publishHeader(topic, qos, retained, false, id, len(payload)), payload...)
+}
+
+// encodeAck serializes the two byte acknowledgement packets (PUBACK, PUBREC,
+// PUBREL, PUBCOMP, UNSUBACK)
+func encodeAck(packetType byte, id uint16) []byte {
+	header := packetType << 4
+	if packetType == packetPubrel {
+		header |= 0x02
+	}
+	return []byte{header, 2, byte(id >> 8), byte(id)}
+}
+
+// encodeSubscribe serializes a SUBSCRIBE packet with one entry per filter
+func encodeSubscribe(id uint16, filters []string, qos []byte) []byte {
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for i, filter := range filters {
+		body = appendString(body, filter)
+		body = append(body, qos[i])
+	}
+	return appendPacket(packetSubscribe<<4|0x02, body)
+}
+
+// encodeUnsubscribe serializes an UNSUBSCRIBE packet
+func encodeUnsubscribe(id uint16, filters []string) []byte {
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for _, filter := range filters {
+		body = appendString(body, filter)
+	}
+	return appendPacket(packetUnsubscribe<<4|0x02, body)
+}
+
+// readPacket reads a full control packet and returns its first header byte
+// and body
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, err := readRemainingLength(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// readRemainingLength decodes the variable length integer of the fixed header
+func readRemainingLength(r io.ByteReader) (int, error) {
+	var length, multiplier int = 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			return length, nil
+		}
+		multiplier *= 128
+	}
+	return 0, fmt.Errorf("malformed remaining length")
+}
+
+// parsePublish splits a PUBLISH body into topic, packet id and payload
+func parsePublish(header byte, body []byte) (topic string, id uint16, payload []byte, err error) {
+	topic, rest, err := readString(body)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if (header>>1)&0x03 > 0 {
+		if len(rest) < 2 {
+			return "", 0, nil, fmt.Errorf("malformed publish packet")
+		}
+		id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	return topic, id, rest, nil
+}
+
+// packetID returns the packet identifier leading the body of ack packets
+func packetID(body []byte) (uint16, error) {
+	if len(body) < 2 {
+		return 0, fmt.Errorf("malformed packet identifier")
+	}
+	return binary.BigEndian.Uint16(body), nil
+}
+
+func appendPacket(header byte, body []byte) []byte {
+	buf := make([]byte, 0, 5+len(body))
+	buf = append(buf, header)
+	buf = appendRemainingLength(buf, len(body))
+	return append(buf, body...)
+}
+
+func appendRemainingLength(buf []byte, length int) []byte {
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			return buf
+		}
+	}
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(b)))
+	return append(buf, b...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, fmt.Errorf("malformed string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, fmt.Errorf("malformed string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
@@ -0,0 +1,96 @@
+package mqtt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRemainingLength(t *testing.T) {
+	tests := []struct {
+		length  int
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097151, []byte{0xff, 0xff, 0x7f}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+		{maxRemainingLength, []byte{0xff, 0xff, 0xff, 0x7f}},
+	}
+	for _, tt := range tests {
+		if got := appendRemainingLength(nil, tt.length); !bytes.Equal(got, tt.encoded) {
+			t.Errorf("appendRemainingLength(%d) = %x, want %x", tt.length, got, tt.encoded)
+		}
+		got, err := readRemainingLength(bytes.NewReader(tt.encoded))
+		if err != nil || got != tt.length {
+			t.Errorf("readRemainingLength(%x) = %d, %v, want %d", tt.encoded, got, err, tt.length)
+		}
+	}
+}
+
+func TestRemainingLengthMalformed(t *testing.T) {
+	tests := [][]byte{
+		{0xff, 0xff, 0xff, 0xff, 0x01}, // Five bytes
+		{0x80},                         // Truncated
+	}
+	for _, encoded := range tests {
+		if _, err := readRemainingLength(bytes.NewReader(encoded)); err == nil {
+			t.Errorf("readRemainingLength(%x) succeeded", encoded)
+		}
+	}
+}
+
+func TestConnectFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		connect connectPacket
+		flags   byte
+	}{
+		{"none", connectPacket{clientID: "c"}, 0x00},
+		{"clean session", connectPacket{clientID: "c", cleanSession: true}, 0x02},
+		{"will qos 0", connectPacket{clientID: "c", willTopic: "w"}, 0x04},
+		{"will qos 1", connectPacket{clientID: "c", willTopic: "w", willQoS: 1}, 0x0c},
+		{"will qos 2 retained", connectPacket{clientID: "c", willTopic: "w", willQoS: 2, willRetain: true}, 0x34},
+		{"will retain without topic", connectPacket{clientID: "c", willRetain: true}, 0x00},
+		{"username", connectPacket{clientID: "c", username: "u"}, 0x80},
+		{"username and password", connectPacket{clientID: "c", username: "u", password: "p"}, 0xc0},
+		{"all", connectPacket{clientID: "c", cleanSession: true, willTopic: "w", willQoS: 1, willRetain: true, username: "u", password: "p"}, 0xee},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet := tt.connect.encode()
+			if packet[0] != packetConnect<<4 {
+				t.Fatalf("header = %#x, want %#x", packet[0], packetConnect<<4)
+			}
+			// Fixed header, protocol name and level precede the flags
+			if got := packet[2+6+1]; got != tt.flags {
+				t.Errorf("flags = %08b, want %08b", got, tt.flags)
+			}
+		})
+	}
+}
+
+func TestConnectPayload(t *testing.T) {
+	c := connectPacket{
+		clientID:    "id",
+		keepAlive:   30,
+		willTopic:   "w",
+		willPayload: []byte{1, 2},
+		username:    "u",
+		password:    "pw",
+	}
+	want := []byte{
+		0x10, 28,
+		0, 4, 'M', 'Q', 'T', 'T', 4, 0xc4, 0, 30,
+		0, 2, 'i', 'd',
+		0, 1, 'w',
+		0, 2, 1, 2,
+		0, 1, 'u',
+		0, 2, 'p', 'w',
+	}
+	if got := c.encode(); !bytes.Equal(got, want) {
+		t.Errorf("encode() = %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// PROXY protocol versions, see the HAProxy PROXY protocol specification
+const (
+	ProxyProtocolV1 byte = 1 // Human-readable header
+	ProxyProtocolV2 byte = 2 // Binary header
+)
+
+// proxyProtocolV2Signature starts every version 2 header
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyHeader encodes a PROXY protocol header announcing a TCP connection
+// from src to dst, both of the same address family
+func proxyHeader(version byte, src, dst *net.TCPAddr) ([]byte, error) {
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	v4 := srcIP != nil
+	if !v4 && dstIP == nil {
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+	}
+	if srcIP == nil || dstIP == nil {
+		return nil, fmt.Errorf("PROXY protocol source %s and destination %s aren't of the same address family", src.IP, dst.IP)
+	}
+
+	switch version {
+	case ProxyProtocolV1:
+		family := "TCP4"
+		if !v4 {
+			family = "TCP6"
+		}
+		return fmt.Appendf(nil, "PROXY %s %s %s %d %d\r\n", family, srcIP, dstIP, src.Port, dst.Port), nil
+	case ProxyProtocolV2:
+		buf := append([]byte(nil), proxyProtocolV2Signature...)
+		buf = append(buf, 0x21) // Version 2, PROXY command
+		if v4 {
+			buf = append(buf, 0x11) // TCP over IPv4
+			buf = binary.BigEndian.AppendUint16(buf, 12)
+		} else {
+			buf = append(buf, 0x21) // TCP over IPv6
+			buf = binary.BigEndian.AppendUint16(buf, 36)
+		}
+		buf = append(buf, srcIP...)
+		buf = append(buf, dstIP...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(src.Port))
+		return binary.BigEndian.AppendUint16(buf, uint16(dst.Port)), nil
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", version)
+	}
+}
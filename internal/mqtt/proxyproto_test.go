@@ -0,0 +1,70 @@
+package mqtt
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestProxyHeader(t *testing.T) {
+	v4src := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40000}
+	v4dst := &net.TCPAddr{IP: net.ParseIP("192.168.1.2"), Port: 1883}
+	v6src := &net.TCPAddr{IP: net.ParseIP("fd00::1"), Port: 40000}
+	v6dst := &net.TCPAddr{IP: net.ParseIP("::1"), Port: 8883}
+
+	v2 := func(family byte, length uint16, rest ...byte) []byte {
+		b := append([]byte("\r\n\r\n\x00\r\nQUIT\n"), 0x21, family, byte(length>>8), byte(length))
+		return append(b, rest...)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		version  byte
+		src, dst *net.TCPAddr
+		want     []byte
+	}{
+		{"v1 ipv4", ProxyProtocolV1, v4src, v4dst, []byte("PROXY TCP4 10.0.0.1 192.168.1.2 40000 1883\r\n")},
+		{"v1 ipv6", ProxyProtocolV1, v6src, v6dst, []byte("PROXY TCP6 fd00::1 ::1 40000 8883\r\n")},
+		{"v2 ipv4", ProxyProtocolV2, v4src, v4dst, v2(0x11, 12,
+			10, 0, 0, 1,
+			192, 168, 1, 2,
+			0x9c, 0x40, 0x07, 0x5b,
+		)},
+		{"v2 ipv6", ProxyProtocolV2, v6src, v6dst, v2(0x21, 36, append(append(
+			append([]byte(nil), net.ParseIP("fd00::1")...),
+			net.IPv6loopback...),
+			0x9c, 0x40, 0x22, 0xb3)...,
+		)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := proxyHeader(tt.version, tt.src, tt.dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("header = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyHeaderInvalid(t *testing.T) {
+	v4 := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40000}
+	v6 := &net.TCPAddr{IP: net.ParseIP("::1"), Port: 1883}
+
+	for _, tt := range []struct {
+		name     string
+		version  byte
+		src, dst *net.TCPAddr
+	}{
+		{"ipv4 to ipv6", ProxyProtocolV1, v4, v6},
+		{"ipv6 to ipv4", ProxyProtocolV2, v6, v4},
+		{"unknown version", 3, v4, v4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := proxyHeader(tt.version, tt.src, tt.dst); err == nil {
+				t.Error("proxyHeader succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package mqtt
+
+import "syscall"
+
+// reuseAddr leaves the socket alone where SO_REUSEADDR has other semantics
+func reuseAddr(_, _ string, _ syscall.RawConn) error {
+	return nil
+}
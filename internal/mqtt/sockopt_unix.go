@@ -0,0 +1,17 @@
+//go:build unix
+
+package mqtt
+
+import "syscall"
+
+// reuseAddr sets SO_REUSEADDR so a fixed source port can be bound again while
+// the previous connection from it is still in TIME_WAIT
+func reuseAddr(_, _ string, c syscall.RawConn) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	}); cerr != nil {
+		return cerr
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package mqtt
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsConn adapts a WebSocket to net.Conn, every write is sent as one binary
+// message and reads continue across message boundaries
+type wsConn struct {
+	*websocket.Conn
+	r   io.Reader
+	rmu sync.Mutex
+	wmu sync.Mutex
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	for {
+		if c.r == nil {
+			var err error
+			if _, c.r, err = c.NextReader(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := c.r.Read(p)
+		if err == io.EOF {
+			// End of the current message, an empty read moves on to the next
+			c.r = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if err := c.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
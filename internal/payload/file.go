@@ -0,0 +1,89 @@
+package payload
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Files rotates through a set of payloads loaded from disk
+type Files struct {
+	payloads [][]byte
+}
+
+// NewFile creates a generator that publishes the content of a single file,
+// an empty file is rejected
+func NewFile(path string) (*Files, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &er.Error{
+			Package: "Payload",
+			Func:    "NewFile",
+			Message: er.ErrPayloadReadFailed,
+			Raw:     err,
+		}
+	}
+	if len(raw) == 0 {
+		return nil, &er.Error{
+			Package: "Payload",
+			Func:    "NewFile",
+			Message: er.ErrEmptyPayloadFile,
+			Raw:     er.ErrEmptyPayloadFile,
+		}
+	}
+	return &Files{payloads: [][]byte{raw}}, nil
+}
+
+// NewDir creates a generator that rotates through the regular files of dir
+// in lexical order, one file per message
+func NewDir(dir string) (*Files, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, &er.Error{
+			Package: "Payload",
+			Func:    "NewDir",
+			Message: er.ErrPayloadReadFailed,
+			Raw:     err,
+		}
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return nil, &er.Error{
+			Package: "Payload",
+			Func:    "NewDir",
+			Message: er.ErrEmptyPayloadDir,
+			Raw:     er.ErrEmptyPayloadDir,
+		}
+	}
+
+	f := &Files{payloads: make([][]byte, 0, len(names))}
+	for _, name := range names {
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, &er.Error{
+				Package: "Payload",
+				Func:    "NewDir",
+				Message: er.ErrPayloadReadFailed,
+				Raw:     err,
+			}
+		}
+		f.payloads = append(f.payloads, raw)
+	}
+
+	return f, nil
+}
+
+// Next returns the file content for message seq
+func (f *Files) Next(_ string, seq int) ([]byte, error) {
+	return f.payloads[seq%len(f.payloads)], nil
+}
@@ -0,0 +1,98 @@
+package payload
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payload.json")
+	if err := os.WriteFile(path, []byte(`{"a":1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seq := range 3 {
+		if got, _ := f.Next("c", seq); string(got) != `{"a":1}` {
+			t.Errorf("Next(%d) = %q", seq, got)
+		}
+	}
+}
+
+func TestFileInvalid(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		path string
+		want error
+	}{
+		{"missing", filepath.Join(dir, "missing"), er.ErrPayloadReadFailed},
+		{"empty", empty, er.ErrEmptyPayloadFile},
+		{"directory", dir, er.ErrPayloadReadFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFile(tt.path)
+			var e *er.Error
+			if !errors.As(err, &e) || e.Message != tt.want {
+				t.Errorf("NewFile() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"b": "second", "a": "first", "c": "third"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seq, want := range []string{"first", "second", "third", "first"} {
+		if got, _ := f.Next("c", seq); string(got) != want {
+			t.Errorf("Next(%d) = %q, want %q", seq, got, want)
+		}
+	}
+}
+
+func TestDirInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		path string
+		want error
+	}{
+		{"missing", filepath.Join(dir, "missing"), er.ErrPayloadReadFailed},
+		{"no regular files", dir, er.ErrEmptyPayloadDir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDir(tt.path)
+			var e *er.Error
+			if !errors.As(err, &e) || e.Message != tt.want {
+				t.Errorf("NewDir() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package payload
+
+import (
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Generator produces the payload of every published message
+type Generator interface {
+	// Next returns the payload for message seq of the given client
+	Next(clientID string, seq int) ([]byte, error)
+}
+
+// Distribution represents how generated payload sizes are spread
+type Distribution string
+
+const (
+	DistFixed   Distribution = "fixed"   // Every payload has the same size
+	DistUniform Distribution = "uniform" // Sizes are uniformly spread between min and max
+	DistNormal  Distribution = "normal"  // Sizes follow a normal distribution around size
+)
+
+// Spec describes which payload generator to build
+type Spec struct {
+	Message  string       // Static message payload
+	Size     int          // Payload size in bytes (mean for normal distribution)
+	Dist     Distribution // Size distribution
+	MinSize  int          // Lower size bound for uniform and normal distributions
+	MaxSize  int          // Upper size bound for uniform and normal distributions
+	StdDev   float64      // Standard deviation for normal distribution
+	Random   bool         // Fill sized payloads with random bytes instead of printable filler
+	File     string       // Path of a file used as payload
+	Dir      string       // Directory of files rotated per message
+	Template string       // Template with placeholders rendered per message
+	Schema   string       // JSON Schema or .proto file payloads are generated from
+	ProtoMsg string       // Protobuf message of a .proto schema
+	Seed     int64        // Seed of the schema generator
+}
+
+// sized reports whether any of the generated size settings is set, each of
+// them selects the sized generator
+func (s Spec) sized() bool {
+	return s.Size > 0 || (s.Dist != "" && s.Dist != DistFixed) || s.MinSize > 0 || s.MaxSize > 0 || s.StdDev > 0 || s.Random
+}
+
+// New builds a generator from the spec. Only one payload source may be set,
+// an empty spec falls back to the static message.
+func New(spec Spec) (Generator, error) {
+	sources := 0
+	for _, set := range []bool{spec.sized(), spec.File != "", spec.Dir != "", spec.Template != "", spec.Schema != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, &er.Error{
+			Package: "Payload",
+			Func:    "New",
+			Message: er.ErrConflictingPayloads,
+			Raw:     er.ErrConflictingPayloads,
+		}
+	}
+
+	switch {
+	case spec.sized():
+		return NewSized(spec.Size, spec.Dist, spec.MinSize, spec.MaxSize, spec.StdDev, spec.Random)
+	case spec.File != "":
+		return NewFile(spec.File)
+	case spec.Dir != "":
+		return NewDir(spec.Dir)
+	case spec.Template != "":
+		return NewTemplate(spec.Template)
+	case spec.Schema != "":
+		return NewStructured(spec.Schema, spec.ProtoMsg, spec.Seed)
+	case spec.Size < 0:
+		return nil, &er.Error{
+			Package: "Payload",
+			Func:    "New",
+			Message: er.ErrInvalidPayloadSize,
+			Raw:     er.ErrInvalidPayloadSize,
+		}
+	default:
+		return NewStatic([]byte(spec.Message)), nil
+	}
+}
+
+// Static returns the same payload for every message
+type Static struct {
+	payload []byte
+}
+
+// NewStatic creates a generator that always returns payload
+func NewStatic(payload []byte) *Static {
+	return &Static{payload: payload}
+}
+
+// Next returns the static payload
+func (s *Static) Next(string, int) ([]byte, error) {
+	return s.payload, nil
+}
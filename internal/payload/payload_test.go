@@ -0,0 +1,60 @@
+package payload
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+		want string // Generator type
+	}{
+		{"empty", Spec{}, "*payload.Static"},
+		{"message", Spec{Message: "hi"}, "*payload.Static"},
+		{"size", Spec{Size: 10}, "*payload.Sized"},
+		{"uniform max only", Spec{Dist: DistUniform, MaxSize: 10}, "*payload.Sized"},
+		{"uniform bounds", Spec{Dist: DistUniform, MinSize: 2, MaxSize: 10}, "*payload.Sized"},
+		{"random with size", Spec{Size: 8, Random: true}, "*payload.Sized"},
+		{"template", Spec{Template: "{seq}"}, "*payload.Template"},
+		{"message with size", Spec{Message: "ignored", Size: 4}, "*payload.Sized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(tt.spec)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got := fmt.Sprintf("%T", g); got != tt.want {
+				t.Errorf("New() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+	}{
+		// Size flags without --payload-size
+		{"min only", Spec{MinSize: 10}},
+		{"max only", Spec{MaxSize: 10}},
+		{"stddev only", Spec{StdDev: 5}},
+		{"random only", Spec{Random: true}},
+		{"normal without size", Spec{Dist: DistNormal, StdDev: 5, MaxSize: 100}},
+		{"uniform without max", Spec{Dist: DistUniform, MinSize: 10}},
+		{"negative size", Spec{Size: -1}},
+		{"size and template", Spec{Size: 10, Template: "{seq}"}},
+		{"file and dir", Spec{File: "a", Dir: "b"}},
+		{"bad template", Spec{Template: "{nope}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if g, err := New(tt.spec); err == nil {
+				t.Errorf("New() = %s, want an error", fmt.Sprintf("%T", g))
+			}
+		})
+	}
+}
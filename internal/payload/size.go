@@ -0,0 +1,113 @@
+package payload
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// filler is the printable pattern used for non-random sized payloads
+const filler = "benchmq-payload-"
+
+// Sized generates payloads whose size follows a distribution
+type Sized struct {
+	size   int
+	dist   Distribution
+	min    int
+	max    int
+	stddev float64
+	random bool
+	pool   []byte // Pre-generated content, payloads are windows into it
+}
+
+// NewSized creates a sized payload generator. For the uniform distribution
+// sizes are drawn from [minSize, maxSize] (maxSize defaults to size), for the
+// normal distribution around size with stddev and clamped to [minSize,
+// maxSize] (maxSize defaults to size + 6 stddev). Fixed and normal sizes
+// need a size, uniform sizes a maximum.
+func NewSized(size int, dist Distribution, minSize, maxSize int, stddev float64, random bool) (*Sized, error) {
+	if dist == "" {
+		dist = DistFixed
+	}
+
+	s := &Sized{size: size, dist: dist, min: minSize, max: maxSize, stddev: stddev, random: random}
+
+	switch dist {
+	case DistFixed:
+		s.min, s.max = size, size
+	case DistUniform:
+		if s.max == 0 {
+			s.max = size
+		}
+	case DistNormal:
+		if s.max == 0 {
+			s.max = size + int(math.Ceil(6*stddev))
+		}
+	default:
+		return nil, &er.Error{
+			Package: "Payload",
+			Func:    "NewSized",
+			Message: er.ErrInvalidDistribution,
+			Raw:     er.ErrInvalidDistribution,
+		}
+	}
+
+	if size < 0 || s.min < 0 || s.min > s.max || stddev < 0 {
+		return nil, &er.Error{
+			Package: "Payload",
+			Func:    "NewSized",
+			Message: er.ErrInvalidPayloadSize,
+			Raw:     er.ErrInvalidPayloadSize,
+		}
+	}
+	if (dist == DistUniform && s.max == 0) || (dist != DistUniform && size == 0) {
+		need := "size"
+		if dist == DistUniform {
+			need = "maximum size"
+		}
+		return nil, &er.Error{
+			Package: "Payload",
+			Func:    "NewSized",
+			Message: er.ErrInvalidPayloadSize,
+			Raw:     fmt.Errorf("%s payloads need a %s > 0", dist, need),
+		}
+	}
+
+	// The pool is twice the largest payload so random windows still differ
+	s.pool = make([]byte, 2*s.max)
+	if random {
+		for i := range s.pool {
+			s.pool[i] = byte(rand.Uint32())
+		}
+	} else {
+		for i := range s.pool {
+			s.pool[i] = filler[i%len(filler)]
+		}
+	}
+
+	return s, nil
+}
+
+// Next returns a payload whose size is drawn from the distribution
+func (s *Sized) Next(string, int) ([]byte, error) {
+	n := s.nextSize()
+	if !s.random {
+		return s.pool[:n], nil
+	}
+	offset := rand.IntN(len(s.pool) - n + 1)
+	return s.pool[offset : offset+n], nil
+}
+
+func (s *Sized) nextSize() int {
+	switch s.dist {
+	case DistUniform:
+		return s.min + rand.IntN(s.max-s.min+1)
+	case DistNormal:
+		n := int(math.Round(rand.NormFloat64()*s.stddev + float64(s.size)))
+		return max(s.min, min(s.max, n))
+	default:
+		return s.size
+	}
+}
@@ -0,0 +1,113 @@
+package payload
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestSizedBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		dist     Distribution
+		min, max int
+		stddev   float64
+		lo, hi   int
+	}{
+		{"fixed", 100, DistFixed, 0, 0, 0, 100, 100},
+		{"default dist", 10, "", 0, 0, 0, 10, 10},
+		{"fixed ignores bounds", 100, DistFixed, 5, 500, 0, 100, 100},
+		{"uniform", 0, DistUniform, 10, 20, 0, 10, 20},
+		{"uniform max from size", 50, DistUniform, 0, 0, 0, 0, 50},
+		{"normal clamped", 100, DistNormal, 90, 110, 50, 90, 110},
+		{"normal default max", 100, DistNormal, 0, 0, 10, 0, 160},
+		{"normal without spread", 100, DistNormal, 0, 0, 0, 100, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, random := range []bool{false, true} {
+				s, err := NewSized(tt.size, tt.dist, tt.min, tt.max, tt.stddev, random)
+				if err != nil {
+					t.Fatalf("NewSized() error = %v", err)
+				}
+				for i := range 1000 {
+					p, err := s.Next("c", i)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(p) < tt.lo || len(p) > tt.hi {
+						t.Fatalf("len = %d, want within [%d, %d]", len(p), tt.lo, tt.hi)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSizedDistribution(t *testing.T) {
+	const n = 20000
+	uniform, err := NewSized(0, DistUniform, 0, 99, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normal, err := NewSized(1000, DistNormal, 0, 2000, 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sum, sumSq float64
+	seen := make(map[int]bool)
+	for i := range n {
+		p, _ := uniform.Next("c", i)
+		seen[len(p)] = true
+		q, _ := normal.Next("c", i)
+		sum += float64(len(q))
+		sumSq += float64(len(q)) * float64(len(q))
+	}
+	if len(seen) != 100 {
+		t.Errorf("uniform produced %d distinct sizes, want 100", len(seen))
+	}
+	mean := sum / n
+	stddev := math.Sqrt(sumSq/n - mean*mean)
+	if math.Abs(mean-1000) > 5 || math.Abs(stddev-100) > 5 {
+		t.Errorf("normal mean %.1f stddev %.1f, want about 1000 and 100", mean, stddev)
+	}
+}
+
+func TestSizedContent(t *testing.T) {
+	s, err := NewSized(40, DistFixed, 0, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := s.Next("c", 0)
+	if want := bytes.Repeat([]byte(filler), 3)[:40]; !bytes.Equal(p, want) {
+		t.Errorf("Next() = %q, want %q", p, want)
+	}
+}
+
+func TestSizedInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		dist     Distribution
+		min, max int
+		stddev   float64
+	}{
+		{"negative size", -1, DistFixed, 0, 0, 0},
+		{"unknown dist", 10, "poisson", 0, 0, 0},
+		{"min above max", 0, DistUniform, 20, 10, 0},
+		{"negative min", 0, DistUniform, -1, 10, 0},
+		{"negative stddev", 10, DistNormal, 0, 0, -1},
+		{"fixed without size", 0, DistFixed, 0, 0, 0},
+		{"normal without size", 0, DistNormal, 0, 100, 10},
+		{"uniform without max", 0, DistUniform, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSized(tt.size, tt.dist, tt.min, tt.max, tt.stddev, false); err == nil {
+				t.Error("NewSized() succeeded")
+			}
+		})
+	}
+}
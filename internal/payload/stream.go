@@ -0,0 +1,47 @@
+package payload
+
+import (
+	"io"
+)
+
+// chunkSize is the size of the block streams are written from
+const chunkSize = 64 * 1024
+
+// chunk is the shared block of filler every stream writes
+var chunk = func() []byte {
+	b := make([]byte, chunkSize)
+	for i := range b {
+		b[i] = filler[i%len(filler)]
+	}
+	return b
+}()
+
+// Stream is a payload of filler written in chunks, large payloads never have
+// to be held in memory. It satisfies mqtt.Stream.
+type Stream struct {
+	size int
+}
+
+// NewStream creates a stream payload of size bytes
+func NewStream(size int) *Stream {
+	return &Stream{size: size}
+}
+
+// Len returns the payload size
+func (s *Stream) Len() int {
+	return s.size
+}
+
+// WriteTo writes the payload to w
+func (s *Stream) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for remaining := s.size; remaining > 0; {
+		n, err := w.Write(chunk[:min(remaining, chunkSize)])
+		written += int64(n)
+		remaining -= n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
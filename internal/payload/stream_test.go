@@ -0,0 +1,20 @@
+package payload
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, 3*chunkSize + 17} {
+		var buf bytes.Buffer
+		s := NewStream(size)
+		n, err := s.WriteTo(&buf)
+		if err != nil || n != int64(size) || buf.Len() != size || s.Len() != size {
+			t.Errorf("size %d: wrote %d, %v, buffer %d", size, n, err, buf.Len())
+		}
+		if size > len(filler) && !bytes.HasPrefix(buf.Bytes(), []byte(filler)) {
+			t.Errorf("size %d: content doesn't start with the filler", size)
+		}
+	}
+}
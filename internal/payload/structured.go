@@ -0,0 +1,29 @@
+package payload
+
+import (
+	"github.com/rayomqio/benchmq/internal/schema"
+)
+
+// Structured generates random payloads conforming to a JSON Schema or a
+// protobuf message. Every message draws from its own random source derived
+// from the seed, the client and the sequence number, so runs with the same
+// seed publish the same payloads.
+type Structured struct {
+	schema schema.Schema
+	seed   int64
+}
+
+// NewStructured loads the schema at path, message selects the protobuf
+// message of a .proto file
+func NewStructured(path, message string, seed int64) (*Structured, error) {
+	s, err := schema.Load(path, message)
+	if err != nil {
+		return nil, err
+	}
+	return &Structured{schema: s, seed: seed}, nil
+}
+
+// Next returns a random payload for message seq of clientID
+func (s *Structured) Next(clientID string, seq int) ([]byte, error) {
+	return s.schema.Generate(schema.NewRand(s.seed, clientID, seq)), nil
+}
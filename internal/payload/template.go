@@ -0,0 +1,160 @@
+package payload
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Template renders a payload with placeholders for every message.
+//
+// Supported placeholders:
+//
+//	{timestamp}             Unix time in milliseconds
+//	{timestamp_ns}          Unix time in nanoseconds
+//	{iso_time}              RFC 3339 time with nanoseconds
+//	{seq}                   Message sequence number within the client
+//	{client_id}             ID of the publishing client
+//	{rand_int:min:max}      Random integer in [min, max]
+//	{rand_float:min:max}    Random float in [min, max)
+type Template struct {
+	segments []segment
+}
+
+// segment is either literal text or a placeholder renderer
+type segment struct {
+	literal string
+	render  func(buf []byte, clientID string, seq int) []byte
+}
+
+// NewTemplate parses the template text. Unknown or malformed placeholders are
+// rejected so typos don't silently end up in the payload.
+func NewTemplate(text string) (*Template, error) {
+	t := &Template{}
+
+	var literal strings.Builder
+	for len(text) > 0 {
+		open := strings.IndexByte(text, '{')
+		if open < 0 {
+			literal.WriteString(text)
+			break
+		}
+		literal.WriteString(text[:open])
+		text = text[open:]
+
+		// Braces that don't enclose a placeholder name (e.g. JSON objects)
+		// are kept as literal text
+		end := strings.IndexByte(text, '}')
+		if end < 0 || !isPlaceholder(text[1:end]) {
+			literal.WriteByte('{')
+			text = text[1:]
+			continue
+		}
+
+		render, err := placeholder(text[1:end])
+		if err != nil {
+			return nil, err
+		}
+		if literal.Len() > 0 {
+			t.segments = append(t.segments, segment{literal: literal.String()})
+			literal.Reset()
+		}
+		t.segments = append(t.segments, segment{render: render})
+		text = text[end+1:]
+	}
+	if literal.Len() > 0 {
+		t.segments = append(t.segments, segment{literal: literal.String()})
+	}
+
+	return t, nil
+}
+
+// isPlaceholder reports whether s looks like a placeholder: a lowercase name
+// optionally followed by colon separated arguments
+func isPlaceholder(s string) bool {
+	if s == "" || s[0] < 'a' || s[0] > 'z' {
+		return false
+	}
+	return !strings.ContainsAny(s, "{\" \t\n")
+}
+
+// Next renders the template for message seq of clientID
+func (t *Template) Next(clientID string, seq int) ([]byte, error) {
+	buf := make([]byte, 0, 64)
+	for _, seg := range t.segments {
+		if seg.render == nil {
+			buf = append(buf, seg.literal...)
+			continue
+		}
+		buf = seg.render(buf, clientID, seq)
+	}
+	return buf, nil
+}
+
+func placeholder(name string) (func([]byte, string, int) []byte, error) {
+	parts := strings.Split(name, ":")
+
+	switch {
+	case name == "timestamp":
+		return func(buf []byte, _ string, _ int) []byte {
+			return strconv.AppendInt(buf, time.Now().UnixMilli(), 10)
+		}, nil
+	case name == "timestamp_ns":
+		return func(buf []byte, _ string, _ int) []byte {
+			return strconv.AppendInt(buf, time.Now().UnixNano(), 10)
+		}, nil
+	case name == "iso_time":
+		return func(buf []byte, _ string, _ int) []byte {
+			return time.Now().AppendFormat(buf, time.RFC3339Nano)
+		}, nil
+	case name == "seq":
+		return func(buf []byte, _ string, seq int) []byte {
+			return strconv.AppendInt(buf, int64(seq), 10)
+		}, nil
+	case name == "client_id":
+		return func(buf []byte, clientID string, _ int) []byte {
+			return append(buf, clientID...)
+		}, nil
+	case parts[0] == "rand_int" && len(parts) == 3:
+		lo, errLo := strconv.ParseInt(parts[1], 10, 64)
+		hi, errHi := strconv.ParseInt(parts[2], 10, 64)
+		if errLo != nil || errHi != nil || lo > hi {
+			return nil, invalidPlaceholder(name)
+		}
+		// The span is computed unsigned so ranges wider than MaxInt64 don't
+		// overflow, the full int64 range takes any 64 random bits
+		span := uint64(hi) - uint64(lo)
+		return func(buf []byte, _ string, _ int) []byte {
+			n := rand.Uint64()
+			if span < math.MaxUint64 {
+				n = rand.Uint64N(span + 1)
+			}
+			return strconv.AppendInt(buf, lo+int64(n), 10)
+		}, nil
+	case parts[0] == "rand_float" && len(parts) == 3:
+		lo, errLo := strconv.ParseFloat(parts[1], 64)
+		hi, errHi := strconv.ParseFloat(parts[2], 64)
+		if errLo != nil || errHi != nil || lo > hi {
+			return nil, invalidPlaceholder(name)
+		}
+		return func(buf []byte, _ string, _ int) []byte {
+			return strconv.AppendFloat(buf, lo+rand.Float64()*(hi-lo), 'f', -1, 64)
+		}, nil
+	default:
+		return nil, invalidPlaceholder(name)
+	}
+}
+
+func invalidPlaceholder(name string) error {
+	return &er.Error{
+		Package: "Payload",
+		Func:    "NewTemplate",
+		Message: er.ErrInvalidTemplate,
+		Raw:     fmt.Errorf("unknown placeholder {%s}", name),
+	}
+}
@@ -0,0 +1,101 @@
+package payload
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		text string
+		want *regexp.Regexp
+	}{
+		{"plain", regexp.MustCompile(`^plain$`)},
+		{"{seq}/{client_id}", regexp.MustCompile(`^7/dev-1$`)},
+		{`{"id":"{client_id}","n":{seq}}`, regexp.MustCompile(`^\{"id":"dev-1","n":7\}$`)},
+		{"{timestamp}", regexp.MustCompile(`^\d{13}$`)},
+		{"{timestamp_ns}", regexp.MustCompile(`^\d{19}$`)},
+		{"{iso_time}", regexp.MustCompile(`^\d{4}-\d\d-\d\dT`)},
+		{"{rand_int:5:5}", regexp.MustCompile(`^5$`)},
+		{"{rand_int:-3:-1}", regexp.MustCompile(`^-[123]$`)},
+		{"{rand_float:1:1}", regexp.MustCompile(`^1$`)},
+		{"{ not a placeholder }", regexp.MustCompile(`^\{ not a placeholder \}$`)},
+		{"{unclosed", regexp.MustCompile(`^\{unclosed$`)},
+	}
+	for _, tt := range tests {
+		tmpl, err := NewTemplate(tt.text)
+		if err != nil {
+			t.Errorf("NewTemplate(%q) error = %v", tt.text, err)
+			continue
+		}
+		got, _ := tmpl.Next("dev-1", 7)
+		if !tt.want.Match(got) {
+			t.Errorf("NewTemplate(%q).Next() = %q, want match of %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateTime(t *testing.T) {
+	tmpl, err := NewTemplate("{iso_time}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := tmpl.Next("c", 0)
+	if _, err := time.Parse(time.RFC3339Nano, string(got)); err != nil {
+		t.Errorf("iso_time %q: %v", got, err)
+	}
+}
+
+func TestTemplateRandRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		lo, hi int64
+	}{
+		{"small", 1, 6},
+		{"wider than MaxInt64", -1, math.MaxInt64},
+		{"full range", math.MinInt64, math.MaxInt64},
+		{"top", math.MaxInt64 - 1, math.MaxInt64},
+		{"bottom", math.MinInt64, math.MinInt64 + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := "{rand_int:" + strconv.FormatInt(tt.lo, 10) + ":" + strconv.FormatInt(tt.hi, 10) + "}"
+			tmpl, err := NewTemplate(text)
+			if err != nil {
+				t.Fatalf("NewTemplate(%q) error = %v", text, err)
+			}
+			for i := range 1000 {
+				got, _ := tmpl.Next("c", i)
+				n, err := strconv.ParseInt(string(got), 10, 64)
+				if err != nil || n < tt.lo || n > tt.hi {
+					t.Fatalf("rand_int = %s, want within [%d, %d]", got, tt.lo, tt.hi)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateInvalid(t *testing.T) {
+	tests := []string{
+		"{unknown}",
+		"{seq:1}",
+		"{rand_int}",
+		"{rand_int:1}",
+		"{rand_int:1:2:3}",
+		"{rand_int:a:2}",
+		"{rand_int:1:b}",
+		"{rand_int:5:1}",
+		"{rand_int:0:9223372036854775808}",
+		"{rand_float:1:x}",
+		"{rand_float:2:1}",
+	}
+	for _, text := range tests {
+		if _, err := NewTemplate("x" + text + "x"); err == nil || !strings.Contains(err.Error(), "placeholder") {
+			t.Errorf("NewTemplate(%q) error = %v, want an invalid placeholder", text, err)
+		}
+	}
+}
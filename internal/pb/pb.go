@@ -0,0 +1,197 @@
+// Package pb encodes and decodes the protobuf wire format by hand, enough for
+// fixed schemas like Sparkplug B without generated code
+package pb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Wire types
+const (
+	WireVarint  = 0
+	WireFixed64 = 1
+	WireBytes   = 2
+	WireFixed32 = 5
+)
+
+// Encoder appends fields to a protobuf message
+type Encoder struct {
+	buf []byte
+}
+
+// Encoded returns the message
+func (e *Encoder) Encoded() []byte {
+	return e.buf
+}
+
+func (e *Encoder) tag(field, wire int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wire))
+}
+
+// Varint appends an unsigned integer field, int32 and int64 values are cast
+// like the protobuf encoders do
+func (e *Encoder) Varint(field int, v uint64) {
+	e.tag(field, WireVarint)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+// Bool appends a bool field
+func (e *Encoder) Bool(field int, v bool) {
+	var n uint64
+	if v {
+		n = 1
+	}
+	e.Varint(field, n)
+}
+
+// Fixed32 appends a fixed32 or sfixed32 field
+func (e *Encoder) Fixed32(field int, v uint32) {
+	e.tag(field, WireFixed32)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+// Fixed64 appends a fixed64 or sfixed64 field
+func (e *Encoder) Fixed64(field int, v uint64) {
+	e.tag(field, WireFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+// Float appends a float field
+func (e *Encoder) Float(field int, v float32) {
+	e.tag(field, WireFixed32)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, math.Float32bits(v))
+}
+
+// Double appends a double field
+func (e *Encoder) Double(field int, v float64) {
+	e.tag(field, WireFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+// Bytes appends a bytes field
+func (e *Encoder) Bytes(field int, v []byte) {
+	e.tag(field, WireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// String appends a string field
+func (e *Encoder) String(field int, v string) {
+	e.tag(field, WireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// Message appends an embedded message written by fn
+func (e *Encoder) Message(field int, fn func(*Encoder)) {
+	var sub Encoder
+	fn(&sub)
+	e.Bytes(field, sub.buf)
+}
+
+// Decoder reads the fields of a protobuf message in order
+type Decoder struct {
+	buf []byte
+	pos int
+}
+
+// NewDecoder returns a decoder of msg
+func NewDecoder(msg []byte) *Decoder {
+	return &Decoder{buf: msg}
+}
+
+// Next returns the number and wire type of the next field, io.EOF at the
+// end of the message. The value must be read or skipped before the next call.
+func (d *Decoder) Next() (field, wire int, err error) {
+	if d.pos >= len(d.buf) {
+		return 0, 0, io.EOF
+	}
+	key, err := d.uvarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	if key>>3 == 0 {
+		return 0, 0, malformed(fmt.Errorf("field number 0 at offset %d", d.pos))
+	}
+	return int(key >> 3), int(key & 7), nil
+}
+
+// Varint reads a varint value
+func (d *Decoder) Varint() (uint64, error) {
+	return d.uvarint()
+}
+
+// Fixed32 reads a fixed 32-bit value
+func (d *Decoder) Fixed32() (uint32, error) {
+	if len(d.buf)-d.pos < 4 {
+		return 0, malformed(io.ErrUnexpectedEOF)
+	}
+	v := binary.LittleEndian.Uint32(d.buf[d.pos:])
+	d.pos += 4
+	return v, nil
+}
+
+// Fixed64 reads a fixed 64-bit value
+func (d *Decoder) Fixed64() (uint64, error) {
+	if len(d.buf)-d.pos < 8 {
+		return 0, malformed(io.ErrUnexpectedEOF)
+	}
+	v := binary.LittleEndian.Uint64(d.buf[d.pos:])
+	d.pos += 8
+	return v, nil
+}
+
+// Bytes reads a length-delimited value, the slice aliases the message
+func (d *Decoder) Bytes() ([]byte, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.buf)-d.pos) {
+		return nil, malformed(io.ErrUnexpectedEOF)
+	}
+	v := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return v, nil
+}
+
+// Skip discards the value of a field of the given wire type
+func (d *Decoder) Skip(wire int) error {
+	var err error
+	switch wire {
+	case WireVarint:
+		_, err = d.uvarint()
+	case WireFixed64:
+		_, err = d.Fixed64()
+	case WireBytes:
+		_, err = d.Bytes()
+	case WireFixed32:
+		_, err = d.Fixed32()
+	default:
+		err = malformed(fmt.Errorf("unsupported wire type %d", wire))
+	}
+	return err
+}
+
+func (d *Decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf[d.pos:])
+	if n <= 0 {
+		return 0, malformed(fmt.Errorf("invalid varint at offset %d", d.pos))
+	}
+	d.pos += n
+	return v, nil
+}
+
+func malformed(err error) error {
+	return &er.Error{
+		Package: "PB",
+		Func:    "Decode",
+		Message: er.ErrInvalidProtobuf,
+		Raw:     err,
+	}
+}
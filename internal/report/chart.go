@@ -0,0 +1,220 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Chart dimensions in SVG user units
+const (
+	chartWidth   = 760
+	chartHeight  = 240
+	marginLeft   = 64
+	marginRight  = 16
+	marginTop    = 30
+	marginBottom = 34
+	yTicks       = 5
+	xTicks       = 6
+)
+
+// palette colors the lines of a chart in order
+var palette = []string{"#2563eb", "#dc2626", "#16a34a", "#9333ea", "#ea580c", "#0891b2"}
+
+// line is one series of a line chart
+type line struct {
+	name   string
+	values []float64
+}
+
+// bar is one bar of a bar chart
+type bar struct {
+	label string
+	value float64
+}
+
+// lineChart draws lines over the shared x values (seconds into the run)
+func lineChart(title, unit string, xs []float64, lines []line) template.HTML {
+	var sb strings.Builder
+	svgOpen(&sb, chartHeight)
+	svgTitle(&sb, title, unit)
+
+	if len(xs) == 0 {
+		noData(&sb)
+		return svgClose(&sb)
+	}
+
+	xMax := xs[len(xs)-1]
+	yMax := 0.0
+	for _, l := range lines {
+		for _, v := range l.values {
+			yMax = max(yMax, v)
+		}
+	}
+	xMax, xStep := axis(xMax, xTicks)
+	yMax, yStep := axis(yMax, yTicks)
+
+	plotW := float64(chartWidth - marginLeft - marginRight)
+	plotH := float64(chartHeight - marginTop - marginBottom)
+	px := func(x float64) float64 { return marginLeft + x/xMax*plotW }
+	py := func(y float64) float64 { return marginTop + plotH - y/yMax*plotH }
+
+	// Grid and y axis labels
+	for v := 0.0; v <= yMax*1.0001; v += yStep {
+		y := py(v)
+		fmt.Fprintf(&sb, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" class="grid"/>`, marginLeft, y, chartWidth-marginRight, y)
+		fmt.Fprintf(&sb, `<text x="%d" y="%.1f" class="tick" text-anchor="end">%s</text>`, marginLeft-6, y+4, formatNumber(v))
+	}
+	// x axis labels
+	for v := 0.0; v <= xMax*1.0001; v += xStep {
+		fmt.Fprintf(&sb, `<text x="%.1f" y="%d" class="tick" text-anchor="middle">%ss</text>`, px(v), chartHeight-marginBottom+16, formatNumber(v))
+	}
+
+	for i, l := range lines {
+		color := palette[i%len(palette)]
+		sb.WriteString(`<polyline fill="none" stroke-width="1.5" stroke="` + color + `" points="`)
+		for j, v := range l.values {
+			if j < len(xs) {
+				fmt.Fprintf(&sb, "%.1f,%.1f ", px(xs[j]), py(v))
+			}
+		}
+		sb.WriteString(`"/>`)
+	}
+	legend(&sb, lines)
+	return svgClose(&sb)
+}
+
+// barChart draws horizontal bars, one per label
+func barChart(title string, bars []bar) template.HTML {
+	const rowHeight = 26
+	height := marginTop + max(len(bars), 1)*rowHeight + 12
+
+	var sb strings.Builder
+	svgOpen(&sb, height)
+	svgTitle(&sb, title, "")
+
+	if len(bars) == 0 {
+		noData(&sb)
+		return svgClose(&sb)
+	}
+
+	labelW := 140
+	plotW := float64(chartWidth - labelW - marginRight - 60)
+	vMax := 0.0
+	for _, b := range bars {
+		vMax = max(vMax, b.value)
+	}
+
+	for i, b := range bars {
+		y := marginTop + i*rowHeight
+		w := 0.0
+		if vMax > 0 {
+			w = b.value / vMax * plotW
+		}
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" class="label" text-anchor="end">%s</text>`, labelW-8, y+16, html.EscapeString(b.label))
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`, labelW, y+4, w, rowHeight-8, palette[1])
+		fmt.Fprintf(&sb, `<text x="%.1f" y="%d" class="label">%s</text>`, float64(labelW)+w+6, y+16, formatNumber(b.value))
+	}
+	return svgClose(&sb)
+}
+
+// sparkline draws a small unlabeled line for table cells
+func sparkline(values []float64) template.HTML {
+	const w, h = 120, 24
+	if len(values) < 2 {
+		return ""
+	}
+
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	span := hi - lo
+	if span == 0 {
+		span = 1
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d"><polyline fill="none" stroke="%s" stroke-width="1" points="`, w, h, w, h, palette[0])
+	for i, v := range values {
+		x := float64(i) / float64(len(values)-1) * (w - 2)
+		y := h - 2 - (v-lo)/span*(h-4)
+		fmt.Fprintf(&sb, "%.1f,%.1f ", x+1, y)
+	}
+	sb.WriteString(`"/></svg>`)
+	return template.HTML(sb.String())
+}
+
+func svgOpen(sb *strings.Builder, height int) {
+	fmt.Fprintf(sb, `<svg xmlns="http://www.w3.org/2000/svg" class="chart" viewBox="0 0 %d %d" role="img">`, chartWidth, height)
+}
+
+func svgClose(sb *strings.Builder) template.HTML {
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+func svgTitle(sb *strings.Builder, title, unit string) {
+	if unit != "" {
+		title += " (" + unit + ")"
+	}
+	fmt.Fprintf(sb, `<text x="%d" y="18" class="title">%s</text>`, marginLeft, html.EscapeString(title))
+}
+
+func noData(sb *strings.Builder) {
+	fmt.Fprintf(sb, `<text x="%d" y="%d" class="label" text-anchor="middle">no data</text>`, chartWidth/2, marginTop+40)
+}
+
+// legend lists the line names in the top right corner
+func legend(sb *strings.Builder, lines []line) {
+	x := chartWidth - marginRight
+	for i := len(lines) - 1; i >= 0; i-- {
+		name := html.EscapeString(lines[i].name)
+		x -= 7*len(lines[i].name) + 24
+		fmt.Fprintf(sb, `<rect x="%d" y="9" width="10" height="10" fill="%s"/>`, x, palette[i%len(palette)])
+		fmt.Fprintf(sb, `<text x="%d" y="18" class="label">%s</text>`, x+14, name)
+	}
+}
+
+// axis returns the top of an axis covering v and its tick step, the step is
+// 1, 2 or 5 times a power of ten so labels are round numbers
+func axis(v float64, ticks int) (float64, float64) {
+	if v <= 0 {
+		return 1, 1 / float64(ticks)
+	}
+	raw := v / float64(ticks)
+	exp := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := 10 * exp
+	for _, m := range []float64{1, 2, 5} {
+		if raw <= m*exp {
+			step = m * exp
+			break
+		}
+	}
+	return math.Ceil(v/step) * step, step
+}
+
+// formatNumber formats v compactly with k, M and G suffixes
+func formatNumber(v float64) string {
+	abs := math.Abs(v)
+	switch {
+	case abs >= 1e9:
+		return trimFloat(v/1e9) + "G"
+	case abs >= 1e6:
+		return trimFloat(v/1e6) + "M"
+	case abs >= 1e4:
+		return trimFloat(v/1e3) + "k"
+	default:
+		return trimFloat(v)
+	}
+}
+
+// trimFloat formats v with up to three decimals and no trailing zeros
+func trimFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 3, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,182 @@
+package report
+
+import (
+	"bufio"
+	"cmp"
+	"html/template"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/monitor"
+	"github.com/rayomqio/benchmq/internal/sysstats"
+	"github.com/rayomqio/benchmq/internal/timeline"
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Setting is a named value, a flag of the run or a result
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// Broker holds the statistics one broker endpoint published
+type Broker struct {
+	Endpoint string
+	Series   []sysstats.Series
+}
+
+// Report is everything known about a finished run
+type Report struct {
+	Title     string
+	Command   string
+	Started   time.Time
+	Elapsed   time.Duration
+	Settings  []Setting // Run configuration
+	Summary   []Setting // Final results
+	Timeline  []timeline.Interval
+	Errors    map[string]int64 // Errors by kind
+	Resources []monitor.Sample
+	Brokers   []Broker
+}
+
+// Write renders the report as a single HTML file without external assets
+func Write(path string, r *Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return &er.Error{
+			Package: "Report",
+			Func:    "Write",
+			Message: er.ErrReportFailed,
+			Raw:     err,
+		}
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := page.Execute(w, newView(r)); err != nil {
+		return &er.Error{
+			Package: "Report",
+			Func:    "Write",
+			Message: er.ErrReportFailed,
+			Raw:     err,
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return &er.Error{
+			Package: "Report",
+			Func:    "Write",
+			Message: er.ErrReportFailed,
+			Raw:     err,
+		}
+	}
+	return f.Close()
+}
+
+// view is the data the page template renders
+type view struct {
+	*Report
+	Generated  time.Time
+	Charts     []template.HTML
+	ErrorRows  []Setting
+	BrokerRows []brokerRow
+}
+
+// brokerRow summarizes one broker statistic
+type brokerRow struct {
+	Endpoint string
+	Topic    string
+	Samples  int
+	First    string
+	Last     string
+	Min      string
+	Max      string
+	Trend    template.HTML
+}
+
+func newView(r *Report) view {
+	v := view{Report: r, Generated: time.Now()}
+
+	xs := make([]float64, len(r.Timeline))
+	sent := make([]float64, len(r.Timeline))
+	received := make([]float64, len(r.Timeline))
+	errors := make([]float64, len(r.Timeline))
+	connects := make([]float64, len(r.Timeline))
+	active := make([]float64, len(r.Timeline))
+	p50 := make([]float64, len(r.Timeline))
+	p90 := make([]float64, len(r.Timeline))
+	p99 := make([]float64, len(r.Timeline))
+	pMax := make([]float64, len(r.Timeline))
+	for i, in := range r.Timeline {
+		xs[i] = (in.Offset + in.Duration).Seconds()
+		secs := in.Duration.Seconds()
+		if secs <= 0 {
+			secs = 1
+		}
+		sent[i] = float64(in.Sent) / secs
+		received[i] = float64(in.Received) / secs
+		errors[i] = float64(in.Errors) / secs
+		connects[i] = float64(in.Connects) / secs
+		active[i] = float64(in.Active)
+		p50[i], p90[i], p99[i], pMax[i] = ms(in.Latency.P50), ms(in.Latency.P90), ms(in.Latency.P99), ms(in.Latency.Max)
+	}
+
+	v.Charts = append(v.Charts,
+		lineChart("Throughput", "msg/s", xs, []line{{"published", sent}, {"received", received}}),
+		lineChart("Latency percentiles", "ms", xs, []line{{"p50", p50}, {"p90", p90}, {"p99", p99}, {"max", pMax}}),
+		lineChart("Connections", "", xs, []line{{"active", active}, {"new per second", connects}}),
+		lineChart("Errors", "per second", xs, []line{{"errors", errors}}),
+	)
+
+	var bars []bar
+	for kind, n := range r.Errors {
+		bars = append(bars, bar{label: kind, value: float64(n)})
+		v.ErrorRows = append(v.ErrorRows, Setting{Name: kind, Value: formatNumber(float64(n))})
+	}
+	slices.SortFunc(bars, func(a, b bar) int { return cmp.Compare(b.value, a.value) })
+	slices.SortFunc(v.ErrorRows, func(a, b Setting) int { return cmp.Compare(a.Name, b.Name) })
+	v.Charts = append(v.Charts, barChart("Error breakdown", bars))
+
+	if len(r.Resources) > 0 {
+		start := r.Resources[0].Time.Add(-r.Resources[0].Elapsed)
+		rx := make([]float64, len(r.Resources))
+		cpu := make([]float64, len(r.Resources))
+		rss := make([]float64, len(r.Resources))
+		goroutines := make([]float64, len(r.Resources))
+		for i, s := range r.Resources {
+			rx[i] = s.Time.Sub(start).Seconds()
+			cpu[i] = max(s.CPUPercent, 0)
+			rss[i] = float64(s.RSSBytes) / (1 << 20)
+			goroutines[i] = float64(s.Goroutines)
+		}
+		v.Charts = append(v.Charts,
+			lineChart("benchmq CPU", "% of one core", rx, []line{{"cpu", cpu}}),
+			lineChart("benchmq memory and goroutines", "", rx, []line{{"rss MiB", rss}, {"goroutines", goroutines}}),
+		)
+	}
+
+	for _, b := range r.Brokers {
+		for _, s := range b.Series {
+			values := make([]float64, len(s.Points))
+			for i, p := range s.Points {
+				values[i] = p.Value
+			}
+			lo, hi := slices.Min(values), slices.Max(values)
+			v.BrokerRows = append(v.BrokerRows, brokerRow{
+				Endpoint: b.Endpoint,
+				Topic:    s.Topic,
+				Samples:  len(values),
+				First:    formatNumber(values[0]),
+				Last:     formatNumber(values[len(values)-1]),
+				Min:      formatNumber(lo),
+				Max:      formatNumber(hi),
+				Trend:    sparkline(values),
+			})
+		}
+	}
+	return v
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
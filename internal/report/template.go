@@ -0,0 +1,68 @@
+package report
+
+import "html/template"
+
+// page is the report layout, styles are inlined so the file works offline
+var page = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>benchmq - {{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; color: #1f2937; margin: 0 auto; max-width: 1100px; padding: 24px; }
+h1 { font-size: 24px; margin-bottom: 4px; }
+h2 { font-size: 18px; margin-top: 32px; border-bottom: 1px solid #e5e7eb; padding-bottom: 4px; }
+.meta { color: #6b7280; font-size: 13px; }
+code { background: #f3f4f6; padding: 2px 4px; border-radius: 3px; font-size: 12px; word-break: break-all; }
+table { border-collapse: collapse; font-size: 13px; width: 100%; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #f3f4f6; vertical-align: middle; }
+th { background: #f9fafb; font-weight: 600; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.grid-2 { display: grid; grid-template-columns: 1fr 1fr; gap: 8px 32px; }
+.chart { width: 100%; height: auto; margin: 8px 0; }
+.chart .grid { stroke: #e5e7eb; stroke-width: 1; }
+.chart .tick { font-size: 10px; fill: #6b7280; }
+.chart .label { font-size: 12px; fill: #374151; }
+.chart .title { font-size: 13px; font-weight: 600; fill: #111827; }
+@media (max-width: 800px) { .grid-2 { grid-template-columns: 1fr; } }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">
+Started {{.Started.Format "2006-01-02 15:04:05 MST"}}, ran for {{.Elapsed}}, generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}
+</div>
+{{if .Command}}<p><code>{{.Command}}</code></p>{{end}}
+
+<h2>Results</h2>
+<div class="grid-2">
+<table>
+<tr><th>Metric</th><th>Value</th></tr>
+{{range .Summary}}<tr><td>{{.Name}}</td><td class="num">{{.Value}}</td></tr>
+{{end}}</table>
+{{if .ErrorRows}}<table>
+<tr><th>Error</th><th>Count</th></tr>
+{{range .ErrorRows}}<tr><td>{{.Name}}</td><td class="num">{{.Value}}</td></tr>
+{{end}}</table>{{else}}<p>No errors.</p>{{end}}
+</div>
+
+<h2>Charts</h2>
+{{range .Charts}}{{.}}
+{{end}}
+
+{{if .BrokerRows}}<h2>Broker statistics</h2>
+<table>
+<tr><th>Endpoint</th><th>Topic</th><th>Samples</th><th>First</th><th>Last</th><th>Min</th><th>Max</th><th>Trend</th></tr>
+{{range .BrokerRows}}<tr><td>{{.Endpoint}}</td><td>{{.Topic}}</td><td class="num">{{.Samples}}</td><td class="num">{{.First}}</td><td class="num">{{.Last}}</td><td class="num">{{.Min}}</td><td class="num">{{.Max}}</td><td>{{.Trend}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Configuration</h2>
+<table>
+<tr><th>Setting</th><th>Value</th></tr>
+{{range .Settings}}<tr><td>{{.Name}}</td><td><code>{{.Value}}</code></td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,675 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// JSON is a JSON Schema. The supported keywords are type, properties,
+// required, additionalProperties, items, minItems, maxItems, minimum,
+// maximum, exclusiveMinimum, exclusiveMaximum, multipleOf, minLength,
+// maxLength, pattern, format, enum, const, anyOf, oneOf, allOf and $ref to
+// the same document. Other keywords are ignored.
+type JSON struct {
+	root *node
+}
+
+// node is a compiled schema, ref points to the node a $ref resolves to
+type node struct {
+	ref         *node
+	never       bool // The false schema
+	types       []string
+	properties  map[string]*node
+	names       []string // Property names in a stable order
+	required    []string
+	closed      bool // additionalProperties: false
+	additional  *node
+	items       *node
+	minItems    int
+	maxItems    int // -1 when unbounded
+	minimum     *float64
+	maximum     *float64
+	exclusiveLo bool
+	exclusiveHi bool
+	multipleOf  float64
+	minLength   int
+	maxLength   int // -1 when unbounded
+	pattern     *pattern
+	format      string
+	enum        []any
+	constant    any
+	hasConst    bool
+	anyOf       []*node
+	oneOf       []*node
+}
+
+// ParseJSON compiles a JSON Schema document
+func ParseJSON(raw []byte) (*JSON, error) {
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, invalid("ParseJSON", err)
+	}
+	c := &compiler{doc: doc, refs: make(map[string]*node)}
+	root, err := c.compile(doc, "#")
+	if err != nil {
+		return nil, invalid("ParseJSON", err)
+	}
+	return &JSON{root: root}, nil
+}
+
+// compiler resolves $ref pointers of a document, refs caches the nodes by
+// pointer so recursive schemas compile to cycles
+type compiler struct {
+	doc  any
+	refs map[string]*node
+}
+
+func (c *compiler) compile(v any, at string) (*node, error) {
+	if b, ok := v.(bool); ok {
+		return &node{never: !b, maxItems: -1, maxLength: -1}, nil
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or boolean", at)
+	}
+
+	n := &node{maxItems: -1, maxLength: -1}
+	if ref, ok := obj["$ref"].(string); ok {
+		target, err := c.resolve(ref)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", at, err)
+		}
+		n.ref = target
+		return n, nil
+	}
+	if err := c.fill(n, obj, at); err != nil {
+		return nil, err
+	}
+
+	// allOf is folded into the node, constraints it sets itself win
+	if list, ok := obj["allOf"].([]any); ok {
+		for i, sub := range list {
+			part, ok := sub.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s/allOf/%d: schema must be an object", at, i)
+			}
+			merged := &node{maxItems: -1, maxLength: -1}
+			if err := c.fill(merged, part, fmt.Sprintf("%s/allOf/%d", at, i)); err != nil {
+				return nil, err
+			}
+			n.merge(merged)
+		}
+	}
+	return n, nil
+}
+
+// resolve compiles the node a JSON pointer within the document refers to
+func (c *compiler) resolve(ref string) (*node, error) {
+	if n, ok := c.refs[ref]; ok {
+		return n, nil
+	}
+	if ref != "#" && !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("$ref %q is not a pointer within the document", ref)
+	}
+
+	v := c.doc
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+		if v, ok = obj[token]; !ok {
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+	}
+
+	// The placeholder is registered first so references to itself end here
+	n := &node{}
+	c.refs[ref] = n
+	compiled, err := c.compile(v, ref)
+	if err != nil {
+		return nil, err
+	}
+	*n = *compiled
+	return n, nil
+}
+
+// fill sets the keywords of obj on n
+func (c *compiler) fill(n *node, obj map[string]any, at string) error {
+	switch t := obj["type"].(type) {
+	case string:
+		n.types = []string{t}
+	case []any:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				n.types = append(n.types, s)
+			}
+		}
+	}
+	for _, t := range n.types {
+		switch t {
+		case "object", "array", "string", "integer", "number", "boolean", "null":
+		default:
+			return fmt.Errorf("%s: unknown type %q", at, t)
+		}
+	}
+
+	if props, ok := obj["properties"].(map[string]any); ok {
+		n.properties = make(map[string]*node, len(props))
+		for name, sub := range props {
+			p, err := c.compile(sub, at+"/properties/"+name)
+			if err != nil {
+				return err
+			}
+			n.properties[name] = p
+			n.names = append(n.names, name)
+		}
+		sort.Strings(n.names)
+	}
+	if req, ok := obj["required"].([]any); ok {
+		for _, v := range req {
+			if s, ok := v.(string); ok {
+				n.required = append(n.required, s)
+			}
+		}
+	}
+	switch add := obj["additionalProperties"].(type) {
+	case bool:
+		n.closed = !add
+	case map[string]any:
+		sub, err := c.compile(add, at+"/additionalProperties")
+		if err != nil {
+			return err
+		}
+		n.additional = sub
+	}
+	if items, ok := obj["items"]; ok {
+		sub, err := c.compile(items, at+"/items")
+		if err != nil {
+			return err
+		}
+		n.items = sub
+	}
+
+	n.minItems = intKeyword(obj, "minItems", 0)
+	n.maxItems = intKeyword(obj, "maxItems", -1)
+	n.minLength = intKeyword(obj, "minLength", 0)
+	n.maxLength = intKeyword(obj, "maxLength", -1)
+	n.minimum = floatKeyword(obj, "minimum")
+	n.maximum = floatKeyword(obj, "maximum")
+	// Draft 4 marks the bounds exclusive with booleans, later drafts give
+	// the exclusive bound itself
+	switch v := obj["exclusiveMinimum"].(type) {
+	case bool:
+		n.exclusiveLo = v
+	case float64:
+		n.minimum, n.exclusiveLo = &v, true
+	}
+	switch v := obj["exclusiveMaximum"].(type) {
+	case bool:
+		n.exclusiveHi = v
+	case float64:
+		n.maximum, n.exclusiveHi = &v, true
+	}
+	if m := floatKeyword(obj, "multipleOf"); m != nil {
+		if *m <= 0 {
+			return fmt.Errorf("%s: multipleOf must be > 0", at)
+		}
+		n.multipleOf = *m
+	}
+	if expr, ok := obj["pattern"].(string); ok {
+		p, err := compilePattern(expr)
+		if err != nil {
+			return fmt.Errorf("%s/pattern: %w", at, err)
+		}
+		n.pattern = p
+	}
+	n.format, _ = obj["format"].(string)
+	if enum, ok := obj["enum"].([]any); ok {
+		if len(enum) == 0 {
+			return fmt.Errorf("%s: enum must not be empty", at)
+		}
+		n.enum = enum
+	}
+	n.constant, n.hasConst = obj["const"]
+
+	for key, list := range map[string]*[]*node{"anyOf": &n.anyOf, "oneOf": &n.oneOf} {
+		subs, ok := obj[key].([]any)
+		if !ok {
+			continue
+		}
+		for i, sub := range subs {
+			s, err := c.compile(sub, fmt.Sprintf("%s/%s/%d", at, key, i))
+			if err != nil {
+				return err
+			}
+			*list = append(*list, s)
+		}
+	}
+	return nil
+}
+
+// merge copies the constraints of other n doesn't set
+func (n *node) merge(other *node) {
+	if n.types == nil {
+		n.types = other.types
+	}
+	for _, name := range other.names {
+		if n.properties == nil {
+			n.properties = make(map[string]*node)
+		}
+		if _, ok := n.properties[name]; !ok {
+			n.properties[name] = other.properties[name]
+			n.names = append(n.names, name)
+		}
+	}
+	sort.Strings(n.names)
+	for _, name := range other.required {
+		if !slices.Contains(n.required, name) {
+			n.required = append(n.required, name)
+		}
+	}
+	n.closed = n.closed || other.closed
+	if n.items == nil {
+		n.items = other.items
+	}
+	if n.minimum == nil {
+		n.minimum, n.exclusiveLo = other.minimum, other.exclusiveLo
+	}
+	if n.maximum == nil {
+		n.maximum, n.exclusiveHi = other.maximum, other.exclusiveHi
+	}
+	if n.pattern == nil {
+		n.pattern = other.pattern
+	}
+	if n.format == "" {
+		n.format = other.format
+	}
+	if n.enum == nil {
+		n.enum = other.enum
+	}
+	if !n.hasConst {
+		n.constant, n.hasConst = other.constant, other.hasConst
+	}
+}
+
+func intKeyword(obj map[string]any, key string, fallback int) int {
+	if v, ok := obj[key].(float64); ok && v >= 0 {
+		return int(v)
+	}
+	return fallback
+}
+
+func floatKeyword(obj map[string]any, key string) *float64 {
+	if v, ok := obj[key].(float64); ok {
+		return &v
+	}
+	return nil
+}
+
+// Generate returns a random JSON document valid against the schema
+func (s *JSON) Generate(r *rand.Rand) []byte {
+	out, _ := json.Marshal(s.root.generate(r, 0))
+	return out
+}
+
+func (n *node) generate(r *rand.Rand, depth int) any {
+	// Schemas requiring themselves without end are cut off
+	if depth > 4*maxDepth {
+		return nil
+	}
+	if n.ref != nil {
+		return n.ref.generate(r, depth)
+	}
+	switch {
+	case n.hasConst:
+		return n.constant
+	case n.enum != nil:
+		return n.enum[r.IntN(len(n.enum))]
+	case n.oneOf != nil:
+		return n.oneOf[r.IntN(len(n.oneOf))].generate(r, depth)
+	case n.anyOf != nil:
+		return n.anyOf[r.IntN(len(n.anyOf))].generate(r, depth)
+	}
+
+	switch n.kind(r) {
+	case "object":
+		obj := make(map[string]any)
+		for _, name := range n.names {
+			if slices.Contains(n.required, name) || (depth < maxDepth && r.IntN(2) == 0) {
+				obj[name] = n.properties[name].generate(r, depth+1)
+			}
+		}
+		for _, name := range n.required {
+			if _, ok := obj[name]; !ok {
+				obj[name] = randString(r, between(r, 1, maxLength))
+			}
+		}
+		return obj
+	case "array":
+		hi := n.maxItems
+		if hi < 0 {
+			hi = n.minItems + maxItems
+		}
+		if depth >= maxDepth {
+			hi = n.minItems
+		}
+		items := make([]any, between(r, n.minItems, hi))
+		for i := range items {
+			if n.items != nil {
+				items[i] = n.items.generate(r, depth+1)
+			} else {
+				items[i] = r.IntN(numberSpan)
+			}
+		}
+		return items
+	case "integer":
+		lo, hi := n.bounds()
+		if n.multipleOf > 0 {
+			k := between(r, int(math.Ceil(lo/n.multipleOf)), int(math.Floor(hi/n.multipleOf)))
+			return int64(float64(k) * n.multipleOf)
+		}
+		return int64(between(r, int(math.Ceil(lo)), int(math.Floor(hi))))
+	case "number":
+		lo, hi := n.bounds()
+		if n.multipleOf > 0 {
+			k := between(r, int(math.Ceil(lo/n.multipleOf)), int(math.Floor(hi/n.multipleOf)))
+			return float64(k) * n.multipleOf
+		}
+		return lo + r.Float64()*(hi-lo)
+	case "boolean":
+		return r.IntN(2) == 0
+	case "null":
+		return nil
+	default:
+		return n.generateString(r)
+	}
+}
+
+// kind picks the type of a generated value
+func (n *node) kind(r *rand.Rand) string {
+	switch {
+	case len(n.types) > 0:
+		return n.types[r.IntN(len(n.types))]
+	case n.properties != nil:
+		return "object"
+	case n.items != nil:
+		return "array"
+	case n.minimum != nil || n.maximum != nil:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// bounds returns the range numbers are generated from, exclusive bounds are
+// moved inside by one unit, or a thousandth of the range for numbers
+func (n *node) bounds() (float64, float64) {
+	lo, hi := 0.0, float64(numberSpan)
+	switch {
+	case n.minimum != nil && n.maximum != nil:
+		lo, hi = *n.minimum, *n.maximum
+	case n.minimum != nil:
+		lo, hi = *n.minimum, *n.minimum+numberSpan
+	case n.maximum != nil:
+		lo, hi = *n.maximum-numberSpan, *n.maximum
+	}
+	step := 1.0
+	if slices.Contains(n.types, "number") && !slices.Contains(n.types, "integer") {
+		step = (hi - lo) / 1000
+	}
+	if n.exclusiveLo {
+		lo += step
+	}
+	if n.exclusiveHi {
+		hi -= step
+	}
+	return lo, max(lo, hi)
+}
+
+func (n *node) generateString(r *rand.Rand) string {
+	switch n.format {
+	case "date-time":
+		return randTime(r).Format(time.RFC3339)
+	case "date":
+		return randTime(r).Format(time.DateOnly)
+	case "uuid":
+		b := make([]byte, 16)
+		for i := range b {
+			b[i] = byte(r.Uint32())
+		}
+		b[6], b[8] = b[6]&0x0f|0x40, b[8]&0x3f|0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+	case "email":
+		return strings.ToLower(randString(r, 8)) + "@example.com"
+	case "ipv4":
+		return fmt.Sprintf("10.%d.%d.%d", r.IntN(256), r.IntN(256), r.IntN(256))
+	}
+
+	hi := n.maxLength
+	if hi < 0 {
+		hi = n.minLength + maxLength
+	}
+	if n.pattern == nil {
+		return randString(r, between(r, n.minLength, hi))
+	}
+	// Patterns rarely bound the length, the closest of a few tries is kept
+	var s string
+	for range 8 {
+		s = n.pattern.generate(r)
+		if l := utf8.RuneCountInString(s); l >= n.minLength && l <= hi {
+			break
+		}
+	}
+	return s
+}
+
+func randTime(r *rand.Rand) time.Time {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return start.Add(time.Duration(r.Int64N(int64(10 * 365 * 24 * time.Hour))))
+}
+
+// Validate reports the first place payload violates the schema
+func (s *JSON) Validate(payload []byte) error {
+	var v any
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return mismatch("Validate", err)
+	}
+	if err := s.root.validate(v, "$"); err != nil {
+		return mismatch("Validate", err)
+	}
+	return nil
+}
+
+func (n *node) validate(v any, at string) error {
+	if n.ref != nil {
+		return n.ref.validate(v, at)
+	}
+	if n.never {
+		return fmt.Errorf("%s: no value is allowed", at)
+	}
+	if n.hasConst && !equal(v, n.constant) {
+		return fmt.Errorf("%s: must be %v", at, n.constant)
+	}
+	if n.enum != nil && !slices.ContainsFunc(n.enum, func(e any) bool { return equal(v, e) }) {
+		return fmt.Errorf("%s: %v is not one of the enum values", at, v)
+	}
+	if len(n.types) > 0 && !slices.ContainsFunc(n.types, func(t string) bool { return isType(v, t) }) {
+		return fmt.Errorf("%s: %s is not of type %s", at, typeName(v), strings.Join(n.types, " or "))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		if err := n.validateObject(v, at); err != nil {
+			return err
+		}
+	case []any:
+		if len(v) < n.minItems || (n.maxItems >= 0 && len(v) > n.maxItems) {
+			return fmt.Errorf("%s: %d items outside %d..%d", at, len(v), n.minItems, n.maxItems)
+		}
+		if n.items != nil {
+			for i, item := range v {
+				if err := n.items.validate(item, at+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+		}
+	case float64:
+		if err := n.validateNumber(v, at); err != nil {
+			return err
+		}
+	case string:
+		if err := n.validateString(v, at); err != nil {
+			return err
+		}
+	}
+
+	if n.anyOf != nil && !slices.ContainsFunc(n.anyOf, func(s *node) bool { return s.validate(v, at) == nil }) {
+		return fmt.Errorf("%s: matches none of anyOf", at)
+	}
+	if n.oneOf != nil {
+		matched := 0
+		for _, s := range n.oneOf {
+			if s.validate(v, at) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%s: matches %d of oneOf instead of 1", at, matched)
+		}
+	}
+	return nil
+}
+
+func (n *node) validateObject(obj map[string]any, at string) error {
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", at, name)
+		}
+	}
+	for name, value := range obj {
+		sub, ok := n.properties[name]
+		switch {
+		case ok:
+		case n.closed:
+			return fmt.Errorf("%s: additional property %q", at, name)
+		case n.additional != nil:
+			sub = n.additional
+		default:
+			continue
+		}
+		if err := sub.validate(value, at+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *node) validateNumber(v float64, at string) error {
+	if n.minimum != nil && (v < *n.minimum || (n.exclusiveLo && v == *n.minimum)) {
+		return fmt.Errorf("%s: %v below minimum %v", at, v, *n.minimum)
+	}
+	if n.maximum != nil && (v > *n.maximum || (n.exclusiveHi && v == *n.maximum)) {
+		return fmt.Errorf("%s: %v above maximum %v", at, v, *n.maximum)
+	}
+	if n.multipleOf > 0 {
+		q := v / n.multipleOf
+		if math.Abs(q-math.Round(q)) > 1e-9*math.Max(1, math.Abs(q)) {
+			return fmt.Errorf("%s: %v is not a multiple of %v", at, v, n.multipleOf)
+		}
+	}
+	return nil
+}
+
+func (n *node) validateString(v, at string) error {
+	l := utf8.RuneCountInString(v)
+	if l < n.minLength || (n.maxLength >= 0 && l > n.maxLength) {
+		return fmt.Errorf("%s: length %d outside %d..%d", at, l, n.minLength, n.maxLength)
+	}
+	if n.pattern != nil && !n.pattern.re.MatchString(v) {
+		return fmt.Errorf("%s: %q does not match pattern %s", at, v, n.pattern.re)
+	}
+
+	var err error
+	switch n.format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, v)
+	case "date":
+		_, err = time.Parse(time.DateOnly, v)
+	case "uuid":
+		if len(v) != 36 || v[8] != '-' || v[13] != '-' || v[18] != '-' || v[23] != '-' {
+			err = fmt.Errorf("malformed")
+		}
+	case "email":
+		if i := strings.IndexByte(v, '@'); i < 1 || i == len(v)-1 {
+			err = fmt.Errorf("malformed")
+		}
+	case "ipv4":
+		if ip := net.ParseIP(v); ip == nil || ip.To4() == nil {
+			err = fmt.Errorf("malformed")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %q is not a valid %s: %w", at, v, n.format, err)
+	}
+	return nil
+}
+
+func isType(v any, t string) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		return t == "object"
+	case []any:
+		return t == "array"
+	case string:
+		return t == "string"
+	case bool:
+		return t == "boolean"
+	case nil:
+		return t == "null"
+	case float64:
+		return t == "number" || (t == "integer" && v == math.Trunc(v))
+	}
+	return false
+}
+
+func typeName(v any) string {
+	for _, t := range []string{"object", "array", "string", "boolean", "null", "integer", "number"} {
+		if isType(v, t) {
+			return t
+		}
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// equal compares decoded JSON values, numbers of any Go type by value
+func equal(a, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"bytes"
+	"testing"
+)
+
+const sensorSchema = `{
+	"type": "object",
+	"required": ["id", "temp", "status"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "string", "format": "uuid"},
+		"temp": {"type": "number", "minimum": -40, "exclusiveMaximum": 125},
+		"count": {"type": "integer", "minimum": 0, "maximum": 10, "multipleOf": 2},
+		"status": {"enum": ["ok", "degraded", "down"]},
+		"tags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z]{3,5}$"}, "minItems": 1, "maxItems": 3},
+		"seen": {"type": "string", "format": "date-time"},
+		"owner": {"$ref": "#/$defs/owner"},
+		"value": {"oneOf": [{"type": "integer"}, {"type": "string", "maxLength": 4}]}
+	},
+	"$defs": {
+		"owner": {"type": "object", "required": ["email"], "properties": {"email": {"type": "string", "format": "email"}}}
+	}
+}`
+
+func TestJSONValidate(t *testing.T) {
+	s, err := ParseJSON([]byte(sensorSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		payload string
+		valid   bool
+	}{
+		{"minimal", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":21.5,"status":"ok"}`, true},
+		{"all properties", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":-40,"count":4,"status":"down","tags":["abc"],"seen":"2024-05-01T10:00:00Z","owner":{"email":"a@b.c"},"value":"abcd"}`, true},
+		{"not JSON", `{"id":`, false},
+		{"not an object", `[]`, false},
+		{"missing required", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":21.5}`, false},
+		{"additional property", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":21.5,"status":"ok","extra":1}`, false},
+		{"malformed uuid", `{"id":"0b7c6e9e","temp":21.5,"status":"ok"}`, false},
+		{"below minimum", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":-41,"status":"ok"}`, false},
+		{"at exclusive maximum", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":125,"status":"ok"}`, false},
+		{"not a multiple", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":0,"count":3,"status":"ok"}`, false},
+		{"not an integer", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":0,"count":2.5,"status":"ok"}`, false},
+		{"not in enum", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":0,"status":"up"}`, false},
+		{"too few items", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":0,"status":"ok","tags":[]}`, false},
+		{"pattern mismatch", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":0,"status":"ok","tags":["ABC"]}`, false},
+		{"malformed date-time", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":0,"status":"ok","seen":"yesterday"}`, false},
+		{"ref mismatch", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":0,"status":"ok","owner":{"email":"nobody"}}`, false},
+		{"oneOf none", `{"id":"0b7c6e9e-3f5a-4a59-8c1e-2f0d6a1b9c3d","temp":0,"status":"ok","value":"abcde"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate([]byte(tt.payload))
+			if tt.valid && err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Validate() = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestJSONGenerate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"sensor", sensorSchema},
+		{"scalar", `{"type": "integer", "minimum": 5, "maximum": 7}`},
+		{"const", `{"const": "fixed"}`},
+		{"anyOf", `{"anyOf": [{"type": "null"}, {"type": "boolean"}]}`},
+		{"allOf", `{"allOf": [{"type": "object", "required": ["a"], "properties": {"a": {"type": "string", "minLength": 2}}}, {"properties": {"b": {"type": "number"}}}]}`},
+		{"formats", `{"type": "array", "minItems": 5, "items": {"type": "object", "required": ["d", "ip"], "properties": {"d": {"type": "string", "format": "date"}, "ip": {"type": "string", "format": "ipv4"}}}}`},
+		{"recursive", `{"$ref": "#/$defs/tree", "$defs": {"tree": {"type": "object", "required": ["children"], "properties": {"children": {"type": "array", "items": {"$ref": "#/$defs/tree"}}}}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseJSON([]byte(tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for seq := range 200 {
+				payload := s.Generate(NewRand(1, "client", seq))
+				if err := s.Validate(payload); err != nil {
+					t.Fatalf("Validate(%s) = %v", payload, err)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONGenerateSeeded(t *testing.T) {
+	s, err := ParseJSON([]byte(sensorSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := s.Generate(NewRand(7, "client-0", 3))
+	if b := s.Generate(NewRand(7, "client-0", 3)); !bytes.Equal(a, b) {
+		t.Errorf("same seed generated %s and %s", a, b)
+	}
+	if b := s.Generate(NewRand(7, "client-1", 3)); bytes.Equal(a, b) {
+		t.Errorf("different clients both generated %s", a)
+	}
+}
+
+func TestParseJSONInvalid(t *testing.T) {
+	tests := []string{
+		`{"type": `,
+		`{"type": "float"}`,
+		`{"enum": []}`,
+		`{"multipleOf": 0}`,
+		`{"pattern": "("}`,
+		`{"$ref": "#/$defs/missing"}`,
+	}
+	for _, raw := range tests {
+		if _, err := ParseJSON([]byte(raw)); err == nil {
+			t.Errorf("ParseJSON(%s) succeeded", raw)
+		}
+	}
+}
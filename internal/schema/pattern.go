@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// pattern is a regular expression strings are generated from and matched
+// against
+type pattern struct {
+	re   *regexp.Regexp
+	tree *syntax.Regexp
+}
+
+func compilePattern(expr string) (*pattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	return &pattern{re: re, tree: tree.Simplify()}, nil
+}
+
+// generate returns a random string matching the expression
+func (p *pattern) generate(r *rand.Rand) string {
+	var b strings.Builder
+	appendMatch(&b, r, p.tree)
+	return b.String()
+}
+
+func appendMatch(b *strings.Builder, r *rand.Rand, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		b.WriteRune(classRune(r, re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteByte(byte(0x20 + r.IntN(0x7f-0x20)))
+	case syntax.OpCapture:
+		appendMatch(b, r, re.Sub[0])
+	case syntax.OpStar:
+		repeatMatch(b, r, re.Sub[0], 0, 3)
+	case syntax.OpPlus:
+		repeatMatch(b, r, re.Sub[0], 1, 4)
+	case syntax.OpQuest:
+		repeatMatch(b, r, re.Sub[0], 0, 1)
+	case syntax.OpRepeat:
+		hi := re.Max
+		if hi < 0 {
+			hi = re.Min + 3
+		}
+		repeatMatch(b, r, re.Sub[0], re.Min, hi)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			appendMatch(b, r, sub)
+		}
+	case syntax.OpAlternate:
+		appendMatch(b, r, re.Sub[r.IntN(len(re.Sub))])
+	}
+	// Anchors, word boundaries and empty matches add nothing
+}
+
+func repeatMatch(b *strings.Builder, r *rand.Rand, re *syntax.Regexp, lo, hi int) {
+	for range between(r, lo, hi) {
+		appendMatch(b, r, re)
+	}
+}
+
+// classRune picks a rune of a character class given as range pairs,
+// printable ASCII is preferred so negated classes stay readable
+func classRune(r *rand.Rand, ranges []rune) rune {
+	var printable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := max(ranges[i], 0x20), min(ranges[i+1], 0x7e)
+		if lo <= hi {
+			printable = append(printable, lo, hi)
+		}
+	}
+	if len(printable) > 0 {
+		ranges = printable
+	}
+	if len(ranges) < 2 {
+		return 'x'
+	}
+	i := 2 * r.IntN(len(ranges)/2)
+	return ranges[i] + rune(r.Int64N(int64(ranges[i+1]-ranges[i])+1))
+}
@@ -0,0 +1,444 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Proto is a protobuf message of a .proto file. Imports, extensions, groups
+// and services are not supported.
+type Proto struct {
+	message *message
+	proto3  bool
+}
+
+// message is a parsed message type, map fields refer to synthesized entry
+// messages with a key and a value field
+type message struct {
+	name     string
+	fields   []*field
+	byNumber map[int]*field
+	oneofs   [][]*field
+}
+
+// field is a parsed message field, typeName is resolved to msg or enum
+type field struct {
+	name     string
+	number   int
+	label    string // repeated, optional, required or empty
+	kind     string // Scalar type name, message or enum
+	typeName string
+	scope    string
+	oneof    bool
+	msg      *message
+	enum     []int64
+}
+
+// scalars maps the scalar types to their wire type
+var scalars = map[string]int{
+	"double": 1, "float": 5,
+	"int32": 0, "int64": 0, "uint32": 0, "uint64": 0, "sint32": 0, "sint64": 0, "bool": 0,
+	"fixed32": 5, "sfixed32": 5, "fixed64": 1, "sfixed64": 1,
+	"string": 2, "bytes": 2,
+}
+
+// ParseProto parses a .proto file and returns the schema of message, the
+// first message of the file when empty
+func ParseProto(src, name string) (*Proto, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, invalid("ParseProto", err)
+	}
+	p := &protoParser{tokens: tokens, messages: make(map[string]*message), enums: make(map[string][]int64)}
+	if err := p.parseFile(); err != nil {
+		return nil, invalid("ParseProto", err)
+	}
+	if err := p.link(); err != nil {
+		return nil, invalid("ParseProto", err)
+	}
+
+	if name == "" {
+		if len(p.order) == 0 {
+			return nil, invalid("ParseProto", fmt.Errorf("no message defined"))
+		}
+		name = p.order[0]
+	}
+	m := p.messages[strings.TrimPrefix(name, ".")]
+	if m == nil {
+		m = p.messages[qualify(p.pkg, name)]
+	}
+	if m == nil {
+		return nil, invalid("ParseProto", fmt.Errorf("message %q not defined", name))
+	}
+	return &Proto{message: m, proto3: p.proto3}, nil
+}
+
+// tokenize splits a .proto source into identifiers, numbers, quoted
+// strings and symbols, comments are dropped
+func tokenize(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, src[i:j+1])
+			i = j + 1
+		case c == '_' || c == '.' || c == '-' || c == '+' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// protoParser builds the messages and enums of a file keyed by full name
+type protoParser struct {
+	tokens   []string
+	pos      int
+	pkg      string
+	proto3   bool
+	messages map[string]*message
+	enums    map[string][]int64
+	order    []string // Top-level messages in file order
+	fields   []*field // Fields of every message, linked after parsing
+}
+
+func (p *protoParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *protoParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *protoParser) expect(want string) error {
+	if got := p.next(); got != want {
+		return fmt.Errorf("expected %q, got %q", want, got)
+	}
+	return nil
+}
+
+// skipStatement skips to the end of a statement or block
+func (p *protoParser) skipStatement() error {
+	depth := 0
+	for p.pos < len(p.tokens) {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			if depth--; depth == 0 {
+				return nil
+			}
+		case ";":
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unexpected end of file")
+}
+
+func (p *protoParser) parseFile() error {
+	for p.pos < len(p.tokens) {
+		switch t := p.next(); t {
+		case "syntax", "edition":
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			p.proto3 = strings.Trim(p.next(), `"'`) == "proto3"
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "package":
+			p.pkg = p.next()
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "import":
+			return fmt.Errorf("imports are not supported")
+		case "message":
+			name, err := p.parseMessage("")
+			if err != nil {
+				return err
+			}
+			p.order = append(p.order, name)
+		case "enum":
+			if err := p.parseEnum(""); err != nil {
+				return err
+			}
+		case "option", "service", "extend":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case ";":
+		default:
+			return fmt.Errorf("unexpected %q", t)
+		}
+	}
+	return nil
+}
+
+// parseMessage parses the message following the keyword and returns its
+// full name
+func (p *protoParser) parseMessage(scope string) (string, error) {
+	full := qualify(p.parent(scope), p.next())
+	m := &message{name: full, byNumber: make(map[int]*field)}
+	p.messages[full] = m
+	if err := p.expect("{"); err != nil {
+		return "", err
+	}
+
+	for {
+		switch t := p.peek(); t {
+		case "}":
+			p.pos++
+			return full, nil
+		case "":
+			return "", fmt.Errorf("message %s: unexpected end of file", full)
+		case "message":
+			p.pos++
+			if _, err := p.parseMessage(full); err != nil {
+				return "", err
+			}
+		case "enum":
+			p.pos++
+			if err := p.parseEnum(full); err != nil {
+				return "", err
+			}
+		case "option", "reserved", "extensions", "extend":
+			if err := p.skipStatement(); err != nil {
+				return "", err
+			}
+		case ";":
+			p.pos++
+		case "oneof":
+			p.pos++
+			p.next()
+			if err := p.expect("{"); err != nil {
+				return "", err
+			}
+			var group []*field
+			for p.peek() != "}" {
+				if p.peek() == "option" {
+					if err := p.skipStatement(); err != nil {
+						return "", err
+					}
+					continue
+				}
+				f, err := p.parseField(m, full)
+				if err != nil {
+					return "", err
+				}
+				f.oneof = true
+				group = append(group, f)
+			}
+			p.pos++
+			if len(group) > 0 {
+				m.oneofs = append(m.oneofs, group)
+			}
+		default:
+			if _, err := p.parseField(m, full); err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// parseField parses a field or map field of m
+func (p *protoParser) parseField(m *message, scope string) (*field, error) {
+	f := &field{scope: scope}
+	switch p.peek() {
+	case "repeated", "optional", "required":
+		f.label = p.next()
+	}
+
+	if p.peek() == "map" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == "<" {
+		p.pos += 2
+		key := p.next()
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		value := p.next()
+		if err := p.expect(">"); err != nil {
+			return nil, err
+		}
+		entry := &message{name: scope + ".entry", byNumber: make(map[int]*field)}
+		for i, typ := range []string{key, value} {
+			ef := &field{name: []string{"key", "value"}[i], number: i + 1, typeName: typ, scope: scope}
+			entry.fields = append(entry.fields, ef)
+			entry.byNumber[ef.number] = ef
+			p.fields = append(p.fields, ef)
+		}
+		f.label, f.kind, f.msg = "repeated", "message", entry
+	} else {
+		f.typeName = p.next()
+	}
+
+	f.name = p.next()
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(p.next())
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("field %s.%s: invalid number", scope, f.name)
+	}
+	f.number = n
+	if p.peek() == "[" {
+		for p.next() != "]" {
+			if p.pos >= len(p.tokens) {
+				return nil, fmt.Errorf("field %s.%s: unterminated options", scope, f.name)
+			}
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return nil, fmt.Errorf("field %s.%s: %w", scope, f.name, err)
+	}
+	if _, dup := m.byNumber[n]; dup {
+		return nil, fmt.Errorf("field %s.%s: number %d used twice", scope, f.name, n)
+	}
+	m.fields = append(m.fields, f)
+	m.byNumber[n] = f
+	if f.kind == "" {
+		p.fields = append(p.fields, f)
+	}
+	return f, nil
+}
+
+func (p *protoParser) parseEnum(scope string) error {
+	full := qualify(p.parent(scope), p.next())
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	var values []int64
+	for {
+		switch t := p.peek(); t {
+		case "}":
+			p.pos++
+			if len(values) == 0 {
+				return fmt.Errorf("enum %s has no values", full)
+			}
+			p.enums[full] = values
+			return nil
+		case "":
+			return fmt.Errorf("enum %s: unexpected end of file", full)
+		case "option", "reserved":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case ";":
+			p.pos++
+		default:
+			p.pos++
+			if err := p.expect("="); err != nil {
+				return fmt.Errorf("enum %s: %w", full, err)
+			}
+			v, err := strconv.ParseInt(p.next(), 0, 32)
+			if err != nil {
+				return fmt.Errorf("enum %s: invalid value of %s", full, t)
+			}
+			values = append(values, v)
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// link resolves the type names of the fields, relative names are looked up
+// from the innermost scope outwards like protoc does
+func (p *protoParser) link() error {
+	for _, f := range p.fields {
+		if _, ok := scalars[f.typeName]; ok {
+			f.kind = f.typeName
+			continue
+		}
+		full, ok := p.lookup(f.typeName, f.scope)
+		if !ok {
+			return fmt.Errorf("field %s.%s: unknown type %q", f.scope, f.name, f.typeName)
+		}
+		if m, ok := p.messages[full]; ok {
+			f.kind, f.msg = "message", m
+		} else {
+			f.kind, f.enum = "enum", p.enums[full]
+		}
+	}
+	return nil
+}
+
+func (p *protoParser) lookup(name, scope string) (string, bool) {
+	if strings.HasPrefix(name, ".") {
+		name = name[1:]
+		return name, p.defined(name)
+	}
+	for {
+		if full := qualify(scope, name); p.defined(full) {
+			return full, true
+		}
+		if scope == "" {
+			return "", false
+		}
+		i := strings.LastIndexByte(scope, '.')
+		if i < 0 {
+			scope = ""
+		} else {
+			scope = scope[:i]
+		}
+	}
+}
+
+func (p *protoParser) defined(full string) bool {
+	_, isMessage := p.messages[full]
+	_, isEnum := p.enums[full]
+	return isMessage || isEnum
+}
+
+// parent returns the scope of a definition, the package at the top level
+func (p *protoParser) parent(scope string) string {
+	if scope == "" {
+		return p.pkg
+	}
+	return scope
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
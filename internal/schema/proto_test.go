@@ -0,0 +1,152 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/rayomqio/benchmq/internal/pb"
+)
+
+const telemetryProto = `
+syntax = "proto3";
+package iot.v1;
+
+// A reading of one device
+message Telemetry {
+	string device = 1;
+	double temp = 2;
+	repeated sint32 samples = 3;
+	Status status = 4;
+	Location location = 5;
+	map<string, int64> counters = 6;
+	oneof payload {
+		bytes raw = 7;
+		string text = 8;
+	}
+	fixed64 uptime = 9;
+	bool ok = 10;
+
+	message Location {
+		float lat = 1;
+		float lon = 2;
+	}
+}
+
+enum Status {
+	STATUS_UNKNOWN = 0;
+	STATUS_OK = 1;
+}
+`
+
+const legacyProto = `
+syntax = "proto2";
+
+message Legacy {
+	required int32 id = 1;
+	optional string name = 2;
+	repeated Legacy children = 3;
+}
+`
+
+func TestProtoGenerate(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		msg  string
+	}{
+		{"proto3", telemetryProto, ""},
+		{"nested", telemetryProto, "iot.v1.Telemetry.Location"},
+		{"proto2 recursive", legacyProto, "Legacy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseProto(tt.src, tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for seq := range 200 {
+				payload := s.Generate(NewRand(1, "client", seq))
+				if err := s.Validate(payload); err != nil {
+					t.Fatalf("Validate(%x) = %v", payload, err)
+				}
+			}
+		})
+	}
+}
+
+func TestProtoValidate(t *testing.T) {
+	s, err := ParseProto(legacyProto, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(fill func(e *pb.Encoder)) []byte {
+		var e pb.Encoder
+		fill(&e)
+		return e.Encoded()
+	}
+	tests := []struct {
+		name    string
+		payload []byte
+		valid   bool
+	}{
+		{"required only", encode(func(e *pb.Encoder) { e.Varint(1, 7) }), true},
+		{"nested", encode(func(e *pb.Encoder) {
+			e.Varint(1, 7)
+			e.String(2, "root")
+			e.Message(3, func(e *pb.Encoder) { e.Varint(1, 8) })
+		}), true},
+		{"empty", nil, false},
+		{"missing required nested", encode(func(e *pb.Encoder) {
+			e.Varint(1, 7)
+			e.Message(3, func(e *pb.Encoder) { e.String(2, "child") })
+		}), false},
+		{"unknown field", encode(func(e *pb.Encoder) {
+			e.Varint(1, 7)
+			e.Varint(9, 1)
+		}), false},
+		{"wrong wire type", encode(func(e *pb.Encoder) { e.String(1, "7") }), false},
+		{"truncated", []byte{0x08}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate(tt.payload)
+			if tt.valid && err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Validate() = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestProtoValidateUTF8(t *testing.T) {
+	s, err := ParseProto(telemetryProto, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e pb.Encoder
+	e.String(1, "\xff\xfe")
+	if err := s.Validate(e.Encoded()); err == nil {
+		t.Error("Validate() accepted an invalid UTF-8 string in proto3")
+	}
+}
+
+func TestParseProtoInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		msg  string
+	}{
+		{"no message", `syntax = "proto3";`, ""},
+		{"unknown message", telemetryProto, "Missing"},
+		{"unknown type", `syntax = "proto3"; message M { Other o = 1; }`, ""},
+		{"unterminated", `syntax = "proto3"; message M { int32 a = 1;`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseProto(tt.src, tt.msg); err == nil {
+				t.Error("ParseProto() succeeded")
+			}
+		})
+	}
+}
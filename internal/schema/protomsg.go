@@ -0,0 +1,215 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"unicode/utf8"
+
+	"github.com/rayomqio/benchmq/internal/pb"
+)
+
+// Generate returns the encoding of a random message. Integers get random
+// magnitudes so their varint sizes vary like real data does.
+func (s *Proto) Generate(r *rand.Rand) []byte {
+	var e pb.Encoder
+	s.generate(&e, r, s.message, 0)
+	return e.Encoded()
+}
+
+func (s *Proto) generate(e *pb.Encoder, r *rand.Rand, m *message, depth int) {
+	for _, f := range m.fields {
+		if f.oneof {
+			continue
+		}
+		switch {
+		case f.label == "repeated":
+			n := between(r, 0, maxItems)
+			if depth >= maxDepth {
+				n = 0
+			}
+			for range n {
+				s.value(e, r, f, depth)
+			}
+		case f.label == "required":
+			s.value(e, r, f, depth)
+		case f.label == "optional" || !s.proto3 || f.kind == "message":
+			// Fields with presence are set half of the time
+			if depth < maxDepth && r.IntN(2) == 0 {
+				s.value(e, r, f, depth)
+			}
+		default:
+			s.value(e, r, f, depth)
+		}
+	}
+	for _, group := range m.oneofs {
+		if depth < maxDepth {
+			s.value(e, r, group[r.IntN(len(group))], depth)
+		}
+	}
+}
+
+// value appends a random value of f
+func (s *Proto) value(e *pb.Encoder, r *rand.Rand, f *field, depth int) {
+	switch f.kind {
+	case "message":
+		e.Message(f.number, func(e *pb.Encoder) { s.generate(e, r, f.msg, depth+1) })
+	case "enum":
+		e.Varint(f.number, uint64(f.enum[r.IntN(len(f.enum))]))
+	case "bool":
+		e.Bool(f.number, r.IntN(2) == 0)
+	case "string":
+		e.String(f.number, randString(r, between(r, 0, maxLength)))
+	case "bytes":
+		b := make([]byte, between(r, 0, maxLength))
+		for i := range b {
+			b[i] = byte(r.Uint32())
+		}
+		e.Bytes(f.number, b)
+	case "float":
+		e.Float(f.number, float32(r.NormFloat64()*numberSpan))
+	case "double":
+		e.Double(f.number, r.NormFloat64()*numberSpan)
+	case "int32":
+		e.Varint(f.number, uint64(int64(int32(signed(r, 31)))))
+	case "int64":
+		e.Varint(f.number, uint64(signed(r, 63)))
+	case "uint32":
+		e.Varint(f.number, magnitude(r, 32))
+	case "uint64":
+		e.Varint(f.number, magnitude(r, 64))
+	case "sint32":
+		v := int64(int32(signed(r, 31)))
+		e.Varint(f.number, uint64(v<<1^v>>63))
+	case "sint64":
+		v := signed(r, 63)
+		e.Varint(f.number, uint64(v<<1^v>>63))
+	case "fixed32", "sfixed32":
+		e.Fixed32(f.number, r.Uint32())
+	case "fixed64", "sfixed64":
+		e.Fixed64(f.number, r.Uint64())
+	}
+}
+
+// magnitude returns a random unsigned integer of up to bits bits whose bit
+// length is uniform
+func magnitude(r *rand.Rand, bits int) uint64 {
+	n := r.IntN(bits + 1)
+	if n == 0 {
+		return 0
+	}
+	return r.Uint64() >> (64 - n)
+}
+
+// signed returns a random integer of up to bits bits with a random sign
+func signed(r *rand.Rand, bits int) int64 {
+	v := int64(magnitude(r, bits))
+	if r.IntN(2) == 0 {
+		return -v
+	}
+	return v
+}
+
+// Validate decodes payload as the message and reports unknown fields, wire
+// types that don't match the field type, invalid UTF-8 strings and missing
+// required fields
+func (s *Proto) Validate(payload []byte) error {
+	if err := s.validate(payload, s.message); err != nil {
+		return mismatch("Validate", err)
+	}
+	return nil
+}
+
+func (s *Proto) validate(payload []byte, m *message) error {
+	seen := make(map[int]bool)
+	d := pb.NewDecoder(payload)
+	for {
+		number, wire, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+		f := m.byNumber[number]
+		if f == nil {
+			return fmt.Errorf("%s: unknown field %d", m.name, number)
+		}
+		seen[number] = true
+
+		want := wireType(f)
+		switch {
+		case wire == want:
+			err = s.validateValue(d, f)
+		case wire == pb.WireBytes && f.label == "repeated" && want != pb.WireBytes:
+			// Packed repeated scalars
+			var packed []byte
+			if packed, err = d.Bytes(); err == nil {
+				err = skipAll(pb.NewDecoder(packed), want)
+			}
+		default:
+			return fmt.Errorf("%s.%s: wire type %d instead of %d", m.name, f.name, wire, want)
+		}
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", m.name, f.name, err)
+		}
+	}
+
+	for _, f := range m.fields {
+		if f.label == "required" && !seen[f.number] {
+			return fmt.Errorf("%s: missing required field %s", m.name, f.name)
+		}
+	}
+	return nil
+}
+
+func (s *Proto) validateValue(d *pb.Decoder, f *field) error {
+	switch f.kind {
+	case "message":
+		raw, err := d.Bytes()
+		if err != nil {
+			return err
+		}
+		return s.validate(raw, f.msg)
+	case "string":
+		raw, err := d.Bytes()
+		if err != nil {
+			return err
+		}
+		if s.proto3 && !utf8.Valid(raw) {
+			return fmt.Errorf("invalid UTF-8")
+		}
+		return nil
+	case "int32", "sint32", "uint32", "enum":
+		v, err := d.Varint()
+		if err == nil && f.kind == "uint32" && v > math.MaxUint32 {
+			err = fmt.Errorf("%d overflows uint32", v)
+		}
+		return err
+	default:
+		return d.Skip(wireType(f))
+	}
+}
+
+func skipAll(d *pb.Decoder, wire int) error {
+	for {
+		if err := d.Skip(wire); err != nil {
+			return err
+		}
+		if _, _, err := d.Next(); errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+func wireType(f *field) int {
+	switch f.kind {
+	case "message":
+		return pb.WireBytes
+	case "enum":
+		return pb.WireVarint
+	}
+	return scalars[f.kind]
+}
@@ -0,0 +1,93 @@
+// Package schema generates random payloads that conform to a JSON Schema or a
+// protobuf message of a .proto file, and validates payloads against them
+package schema
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Schema describes the structure of payloads
+type Schema interface {
+	// Generate returns a random payload conforming to the schema
+	Generate(r *rand.Rand) []byte
+	// Validate reports why payload doesn't conform to the schema
+	Validate(payload []byte) error
+}
+
+// Limits of generated values the schema leaves open
+const (
+	maxDepth   = 6  // Nesting below which optional fields and items are left out
+	maxItems   = 4  // Repeated values when the schema sets no bound
+	maxLength  = 16 // String and bytes length when the schema sets no bound
+	numberSpan = 1000
+)
+
+// Load reads the schema at path, files ending in .proto are protobuf
+// definitions of which message is generated, the first message by default.
+// Any other file is a JSON Schema.
+func Load(path, message string) (Schema, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, invalid("Load", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".proto") {
+		return ParseProto(string(raw), message)
+	}
+	if message != "" {
+		return nil, invalid("Load", fmt.Errorf("message %q given for JSON Schema %s", message, path))
+	}
+	return ParseJSON(raw)
+}
+
+// NewRand returns the random source of message seq of clientID, the same
+// seed yields the same payloads whatever order clients publish in
+func NewRand(seed int64, clientID string, seq int) *rand.Rand {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(clientID); i++ {
+		h ^= uint64(clientID[i])
+		h *= 1099511628211
+	}
+	return rand.New(rand.NewPCG(uint64(seed), h^uint64(seq)*0x9e3779b97f4a7c15))
+}
+
+// randString returns n random alphanumeric characters
+func randString(r *rand.Rand, n int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[r.IntN(len(alphabet))]
+	}
+	return string(b)
+}
+
+// between returns a random int in [lo, hi]
+func between(r *rand.Rand, lo, hi int) int {
+	if hi <= lo {
+		return lo
+	}
+	return lo + r.IntN(hi-lo+1)
+}
+
+func invalid(fn string, err error) error {
+	return &er.Error{
+		Package: "Schema",
+		Func:    fn,
+		Message: er.ErrInvalidSchema,
+		Raw:     err,
+	}
+}
+
+func mismatch(fn string, err error) error {
+	return &er.Error{
+		Package: "Schema",
+		Func:    fn,
+		Message: er.ErrSchemaMismatch,
+		Raw:     err,
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+// maxBodySize bounds the request bodies
+const maxBodySize = 1 << 20
+
+// Handler returns the HTTP handler of the API:
+//
+//	POST  /runs              submit a Spec, it runs now or is queued
+//	GET   /runs              list every run
+//	GET   /runs/{id}         state and live status of a run
+//	PATCH /runs/{id}         change delayMs, rate or clients of the active run
+//	POST  /runs/{id}/stop    stop the active run or cancel a queued one
+//	GET   /runs/{id}/results final results and timeline of a finished run
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", s.handleSubmit)
+	mux.HandleFunc("GET /runs", s.handleList)
+	mux.HandleFunc("GET /runs/{id}", s.handleGet)
+	mux.HandleFunc("PATCH /runs/{id}", s.handleAdjust)
+	mux.HandleFunc("POST /runs/{id}/stop", s.handleStop)
+	mux.HandleFunc("GET /runs/{id}/results", s.handleResults)
+	return mux
+}
+
+// adjustRequest is the body of PATCH /runs/{id}, rate is in messages per
+// second per client and an alternative to delayMs
+type adjustRequest struct {
+	DelayMs *float64 `json:"delayMs"`
+	Rate    *float64 `json:"rate"`
+	Clients *int     `json:"clients"`
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, req *http.Request) {
+	var spec Spec
+	if err := decode(w, req, &spec); err != nil {
+		writeError(w, http.StatusBadRequest, invalidSpec(err))
+		return
+	}
+
+	r, err := s.submit(spec)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	s.writeRun(w, http.StatusAccepted, r)
+}
+
+func (s *Server) handleList(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	views := make([]runView, 0, len(s.order))
+	for _, r := range s.order {
+		views = append(views, r.view(s.position(r)))
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, req *http.Request) {
+	r, err := s.find(req.PathValue("id"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	s.writeRun(w, http.StatusOK, r)
+}
+
+func (s *Server) handleAdjust(w http.ResponseWriter, req *http.Request) {
+	var body adjustRequest
+	if err := decode(w, req, &body); err != nil {
+		writeError(w, http.StatusBadRequest, invalidSpec(err))
+		return
+	}
+
+	var delay *time.Duration
+	switch {
+	case body.DelayMs != nil && body.Rate != nil:
+		writeError(w, http.StatusBadRequest, invalidSpec(errors.New("set either delayMs or rate")))
+		return
+	case body.DelayMs != nil:
+		d := time.Duration(*body.DelayMs * float64(time.Millisecond))
+		delay = &d
+	case body.Rate != nil:
+		if *body.Rate <= 0 {
+			writeError(w, http.StatusBadRequest, invalidSpec(errors.New("rate must be > 0")))
+			return
+		}
+		d := time.Duration(float64(time.Second) / *body.Rate)
+		delay = &d
+	}
+
+	r, err := s.adjust(req.PathValue("id"), delay, body.Clients)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	s.writeRun(w, http.StatusOK, r)
+}
+
+func (s *Server) handleStop(w http.ResponseWriter, req *http.Request) {
+	r, err := s.stop(req.PathValue("id"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	s.writeRun(w, http.StatusAccepted, r)
+}
+
+func (s *Server) handleResults(w http.ResponseWriter, req *http.Request) {
+	r, err := s.find(req.PathValue("id"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.State != StateFinished && r.State != StateStopped {
+		writeError(w, http.StatusConflict, fmt.Errorf("run %s is %s, results are available once it finished", r.ID, r.State))
+		return
+	}
+	writeJSON(w, http.StatusOK, r.results())
+}
+
+// writeRun writes the view of r
+func (s *Server) writeRun(w http.ResponseWriter, code int, r *Run) {
+	s.mu.Lock()
+	v := r.view(s.position(r))
+	s.mu.Unlock()
+	writeJSON(w, code, v)
+}
+
+// position returns the place of r in the queue starting at 1, 0 when it
+// isn't queued, s.mu must be held
+func (s *Server) position(r *Run) int {
+	for i, p := range s.pending {
+		if p == r {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// decode reads a JSON body into v, unknown fields are rejected so typos
+// don't silently run a default benchmark
+func decode(w http.ResponseWriter, req *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		logger.Error("failed to write response", logger.ErrorAttr(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": errorMessage(err)})
+}
+
+// errorMessage returns the sentinel and cause of err without the package
+// and function details of er.Error
+func errorMessage(err error) string {
+	var e *er.Error
+	if !errors.As(err, &e) {
+		return err.Error()
+	}
+	if e.Raw == nil || e.Raw == e.Message {
+		return e.Message.Error()
+	}
+	return e.Message.Error() + ": " + e.Raw.Error()
+}
+
+// statusOf maps an error to its HTTP status code
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, er.ErrRunNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, er.ErrRunNotActive), errors.Is(err, er.ErrNotAdjustable):
+		return http.StatusConflict
+	case errors.Is(err, er.ErrQueueFull):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
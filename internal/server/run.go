@@ -0,0 +1,139 @@
+package server
+
+import (
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/bench"
+	"github.com/rayomqio/benchmq/internal/timeline"
+)
+
+// State is the lifecycle stage of a run
+type State string
+
+const (
+	StateQueued    State = "queued"    // Waiting behind the active run
+	StateRunning   State = "running"   // Active
+	StateStopping  State = "stopping"  // Stop requested, waiting for the clients
+	StateFinished  State = "finished"  // Ran to completion
+	StateStopped   State = "stopped"   // Stopped early, results cover the run until then
+	StateCancelled State = "cancelled" // Stopped while queued, never ran
+	StateFailed    State = "failed"    // The benchmark couldn't be created or started
+)
+
+// Run is a submitted benchmark, the server mutex guards its fields
+type Run struct {
+	ID        string
+	Spec      Spec
+	State     State
+	Error     string
+	Submitted time.Time
+	Started   time.Time
+	Finished  time.Time
+
+	bench *bench.Bench
+}
+
+func (r *Run) fail(err error) {
+	r.State = StateFailed
+	r.Error = errorMessage(err)
+	r.Finished = time.Now()
+}
+
+func (r *Run) cancel() {
+	r.State = StateCancelled
+	r.Finished = time.Now()
+}
+
+func (r *Run) finish() {
+	if err := r.bench.Err(); err != nil {
+		r.fail(err)
+		return
+	}
+	r.State = StateFinished
+	if r.bench.Stopped() {
+		r.State = StateStopped
+	}
+	r.Finished = time.Now()
+}
+
+// runView is the JSON form of a run
+type runView struct {
+	ID        string      `json:"id"`
+	State     State       `json:"state"`
+	Error     string      `json:"error,omitempty"`
+	Spec      Spec        `json:"spec"`
+	Position  int         `json:"queuePosition,omitempty"`
+	Submitted time.Time   `json:"submitted"`
+	Started   *time.Time  `json:"started,omitempty"`
+	Finished  *time.Time  `json:"finished,omitempty"`
+	Status    *statusView `json:"status,omitempty"`
+}
+
+// statusView is the live progress of a run, counts are of the closed
+// timeline intervals
+type statusView struct {
+	ElapsedSec   float64            `json:"elapsedSec"`
+	Clients      int                `json:"clients"`
+	DelayMs      float64            `json:"delayMs"`
+	Sent         int64              `json:"sent"`
+	Received     int64              `json:"received"`
+	Errors       int64              `json:"errors"`
+	Active       int64              `json:"activeConnections"`
+	LastInterval *timeline.Interval `json:"lastInterval,omitempty"`
+}
+
+// resultsView holds the final results and the timeline of a run
+type resultsView struct {
+	ID       string              `json:"id"`
+	State    State               `json:"state"`
+	Summary  map[string]any      `json:"summary"`
+	Timeline []timeline.Interval `json:"timeline"`
+}
+
+// view returns the JSON form of r, position is its place in the queue
+// starting at 1, s.mu must be held
+func (r *Run) view(position int) runView {
+	v := runView{
+		ID:        r.ID,
+		State:     r.State,
+		Error:     r.Error,
+		Spec:      r.Spec.redacted(),
+		Position:  position,
+		Submitted: r.Submitted,
+	}
+	if !r.Started.IsZero() {
+		v.Started = &r.Started
+	}
+	if !r.Finished.IsZero() {
+		v.Finished = &r.Finished
+	}
+
+	if r.bench != nil {
+		st := r.bench.Status()
+		end := r.Finished
+		if end.IsZero() {
+			end = time.Now()
+		}
+		v.Status = &statusView{
+			ElapsedSec:   end.Sub(r.Started).Seconds(),
+			Clients:      st.Clients,
+			DelayMs:      float64(st.Delay) / float64(time.Millisecond),
+			Sent:         st.Sent,
+			Received:     st.Received,
+			Errors:       st.Errors,
+			Active:       st.Active,
+			LastInterval: st.Last,
+		}
+	}
+	return v
+}
+
+// results returns the final results of r, s.mu must be held
+func (r *Run) results() resultsView {
+	v := resultsView{ID: r.ID, State: r.State, Summary: map[string]any{}}
+	for _, attr := range r.bench.Results() {
+		v.Summary[attr.Key] = attr.Value.Any()
+	}
+	v.Timeline = r.bench.Intervals()
+	return v
+}
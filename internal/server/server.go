@@ -0,0 +1,328 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/bench"
+	"github.com/rayomqio/benchmq/pkg/config"
+	"github.com/rayomqio/benchmq/pkg/er"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+const (
+	DefaultAddr      = "localhost:8080" // Default listen address of the API
+	DefaultQueueSize = 16               // Default number of runs waiting behind the active one
+)
+
+// Server runs the benchmarks submitted over HTTP one at a time, later
+// submissions wait in a queue
+type Server struct {
+	addr      string
+	queueSize int
+	cfg       *config.Config
+
+	mu      sync.Mutex
+	runs    map[string]*Run
+	order   []*Run // Every run in submission order
+	pending []*Run // Queued runs in execution order
+	active  *Run
+	nextID  int
+	closed  bool
+
+	wake   chan struct{} // Signals the worker that a run was queued
+	done   chan struct{} // Closed when the worker returned
+	http   *http.Server
+	logger *logger.Logger
+}
+
+type Option func(*Server)
+
+// New creates a server running benchmarks against the brokers of cfg
+func New(cfg *config.Config, options ...Option) (*Server, error) {
+	if cfg == nil {
+		return nil, &er.Error{
+			Package: "Server",
+			Func:    "New",
+			Message: er.ErrNilConfig,
+			Raw:     er.ErrNilConfig,
+		}
+	}
+
+	s := &Server{
+		addr:      DefaultAddr,
+		queueSize: DefaultQueueSize,
+		cfg:       cfg,
+		runs:      make(map[string]*Run),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		logger:    logger.NewBenchmarkLogger("server"),
+	}
+	for _, option := range options {
+		if option != nil {
+			option(s)
+		}
+	}
+	if s.queueSize < 0 {
+		s.queueSize = 0
+	}
+
+	s.http = &http.Server{
+		Addr:              s.addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+// WithAddr sets the host:port the API listens on
+func WithAddr(addr string) Option {
+	return func(s *Server) {
+		if addr != "" {
+			s.addr = addr
+		}
+	}
+}
+
+// WithQueueSize sets how many runs may wait behind the active one
+func WithQueueSize(size int) Option {
+	return func(s *Server) {
+		s.queueSize = size
+	}
+}
+
+// ListenAndServe serves the API until Shutdown is called
+func (s *Server) ListenAndServe() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		close(s.done)
+		return &er.Error{
+			Package: "Server",
+			Func:    "ListenAndServe",
+			Message: er.ErrServeFailed,
+			Raw:     err,
+		}
+	}
+	go s.work()
+	s.logger.Info("serving benchmark API", logger.String("addr", ln.Addr().String()), logger.Int("queueSize", s.queueSize))
+
+	if err := s.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return &er.Error{
+			Package: "Server",
+			Func:    "ListenAndServe",
+			Message: er.ErrServeFailed,
+			Raw:     err,
+		}
+	}
+	return nil
+}
+
+// Shutdown stops accepting requests, cancels the queued runs and stops the
+// active one, then waits for it to report its results. Later calls only wait.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	first := !s.closed
+	s.closed = true
+	for _, r := range s.pending {
+		r.cancel()
+	}
+	s.pending = nil
+	if s.active != nil {
+		s.active.bench.Stop()
+	}
+	s.mu.Unlock()
+
+	err := s.http.Shutdown(ctx)
+	if first {
+		close(s.wake)
+	}
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return err
+}
+
+// submit queues a run of spec
+func (s *Server) submit(spec Spec) (*Run, error) {
+	if _, err := spec.options(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The first run starts right away when none is active
+	capacity := s.queueSize
+	if s.active == nil {
+		capacity++
+	}
+	if s.closed || len(s.pending) >= capacity {
+		return nil, &er.Error{
+			Package: "Server",
+			Func:    "Submit",
+			Message: er.ErrQueueFull,
+			Raw:     er.ErrQueueFull,
+		}
+	}
+
+	s.nextID++
+	r := &Run{ID: strconv.Itoa(s.nextID), Spec: spec, State: StateQueued, Submitted: time.Now()}
+	s.runs[r.ID] = r
+	s.order = append(s.order, r)
+	s.pending = append(s.pending, r)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	s.logger.Info("run queued", logger.String("run", r.ID), logger.String("type", spec.Type), logger.Int("queued", len(s.pending)))
+	return r, nil
+}
+
+// find returns the run with id
+func (s *Server) find(id string) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.runs[id]
+	if !ok {
+		return nil, &er.Error{
+			Package: "Server",
+			Func:    "Find",
+			Message: er.ErrRunNotFound,
+			Raw:     er.ErrRunNotFound,
+		}
+	}
+	return r, nil
+}
+
+// stop stops the run with id, queued runs are cancelled
+func (s *Server) stop(id string) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.runs[id]
+	if !ok {
+		return nil, &er.Error{
+			Package: "Server",
+			Func:    "Stop",
+			Message: er.ErrRunNotFound,
+			Raw:     er.ErrRunNotFound,
+		}
+	}
+
+	switch r.State {
+	case StateQueued:
+		r.cancel()
+		for i, p := range s.pending {
+			if p == r {
+				s.pending = append(s.pending[:i], s.pending[i+1:]...)
+				break
+			}
+		}
+		s.logger.Info("run cancelled", logger.String("run", r.ID))
+	case StateRunning:
+		r.State = StateStopping
+		r.bench.Stop()
+		s.logger.Info("stopping run", logger.String("run", r.ID))
+	}
+	return r, nil
+}
+
+// adjust changes the delay or client count of the active run with id
+func (s *Server) adjust(id string, delay *time.Duration, clients *int) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.runs[id]
+	if !ok {
+		return nil, &er.Error{
+			Package: "Server",
+			Func:    "Adjust",
+			Message: er.ErrRunNotFound,
+			Raw:     er.ErrRunNotFound,
+		}
+	}
+	if r.State != StateRunning {
+		return nil, &er.Error{
+			Package: "Server",
+			Func:    "Adjust",
+			Message: er.ErrRunNotActive,
+			Raw:     er.ErrRunNotActive,
+		}
+	}
+
+	if delay != nil {
+		if err := r.bench.SetDelay(*delay); err != nil {
+			return nil, err
+		}
+		s.logger.Info("run delay changed", logger.String("run", r.ID), logger.String("delay", delay.String()))
+	}
+	if clients != nil {
+		if err := r.bench.SetClients(*clients); err != nil {
+			return nil, err
+		}
+		s.logger.Info("run clients changed", logger.String("run", r.ID), logger.Int("clients", *clients))
+	}
+	return r, nil
+}
+
+// work executes the queued runs one after another until Shutdown
+func (s *Server) work() {
+	defer close(s.done)
+
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.mu.Unlock()
+			if _, ok := <-s.wake; !ok {
+				return
+			}
+			continue
+		}
+		r := s.pending[0]
+		s.pending = s.pending[1:]
+		err := s.start(r)
+		s.mu.Unlock()
+
+		if err != nil {
+			s.logger.Error("run failed", logger.String("run", r.ID), logger.ErrorAttr(err))
+			continue
+		}
+		s.logger.Info("run started", logger.String("run", r.ID), logger.String("type", r.Spec.Type))
+		r.Spec.run(r.bench)
+
+		s.mu.Lock()
+		r.finish()
+		s.active = nil
+		s.mu.Unlock()
+		s.logger.Info("run finished", logger.String("run", r.ID), logger.String("state", string(r.State)))
+	}
+}
+
+// start creates the benchmark of r and makes it the active run, s.mu must
+// be held
+func (s *Server) start(r *Run) error {
+	options, err := r.Spec.options()
+	if err == nil {
+		// Each run gets its own copy, options like WithHost modify the config
+		cfg := *s.cfg
+		r.bench, err = bench.NewBenchmark(&cfg, options...)
+	}
+	if err != nil {
+		r.fail(err)
+		return err
+	}
+
+	r.State = StateRunning
+	r.Started = time.Now()
+	s.active = r
+	return nil
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/mqtt/mqtttest"
+	"github.com/rayomqio/benchmq/pkg/config"
+)
+
+// testServer serves the API of a server running benchmarks against a fake
+// broker
+type testServer struct {
+	*Server
+	t   *testing.T
+	url string
+}
+
+func newTestServer(t *testing.T, options ...Option) *testServer {
+	t.Helper()
+	broker := mqtttest.NewBroker(t)
+	cfg := &config.Config{}
+	cfg.Server.Host = broker.Host()
+	cfg.Server.Port = broker.Port()
+
+	s, err := New(cfg, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.work()
+	h := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		h.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	})
+	return &testServer{Server: s, t: t, url: h.URL}
+}
+
+// do sends a request with body encoded as JSON and decodes the response into
+// out, it returns the status code
+func (ts *testServer) do(method, path string, body, out any) int {
+	ts.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, ts.url+path, &buf)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// submit posts spec and returns the accepted run
+func (ts *testServer) submit(spec Spec) runView {
+	ts.t.Helper()
+	var v runView
+	if code := ts.do(http.MethodPost, "/runs", spec, &v); code != http.StatusAccepted {
+		ts.t.Fatalf("POST /runs = %d, want %d", code, http.StatusAccepted)
+	}
+	return v
+}
+
+// wait polls the run with id until cond holds
+func (ts *testServer) wait(id string, cond func(runView) bool) runView {
+	ts.t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var v runView
+		ts.do(http.MethodGet, "/runs/"+id, nil, &v)
+		if cond(v) {
+			return v
+		}
+		if time.Now().After(deadline) {
+			ts.t.Fatalf("run %s: condition not met, state %s", id, v.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func inState(state State) func(runView) bool {
+	return func(v runView) bool { return v.State == state }
+}
+
+// slowPub publishes long enough to stay active until it is stopped
+func slowPub() Spec {
+	delay := 20
+	return Spec{Type: TypePub, ClientImpl: "native", Clients: 1, Count: 100000, DelayMs: &delay}
+}
+
+func TestServerQueue(t *testing.T) {
+	ts := newTestServer(t, WithQueueSize(1))
+
+	first := ts.submit(slowPub())
+	ts.wait(first.ID, inState(StateRunning))
+
+	second := ts.submit(slowPub())
+	if second.State != StateQueued || second.Position != 1 {
+		t.Errorf("second run = %s at position %d, want queued at 1", second.State, second.Position)
+	}
+	if code := ts.do(http.MethodPost, "/runs", slowPub(), nil); code != http.StatusServiceUnavailable {
+		t.Errorf("POST /runs with a full queue = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if code := ts.do(http.MethodPost, "/runs", Spec{Type: "bogus"}, nil); code != http.StatusBadRequest {
+		t.Errorf("POST /runs with an invalid spec = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	var list []runView
+	if code := ts.do(http.MethodGet, "/runs", nil, &list); code != http.StatusOK || len(list) != 2 {
+		t.Fatalf("GET /runs = %d with %d runs, want %d with 2", code, len(list), http.StatusOK)
+	}
+
+	// Cancelling the queued run leaves the active one alone
+	var v runView
+	if code := ts.do(http.MethodPost, "/runs/"+second.ID+"/stop", nil, &v); code != http.StatusAccepted || v.State != StateCancelled {
+		t.Errorf("stop queued run = %d %s, want %d %s", code, v.State, http.StatusAccepted, StateCancelled)
+	}
+	if code := ts.do(http.MethodGet, "/runs/"+first.ID+"/results", nil, nil); code != http.StatusConflict {
+		t.Errorf("results of the running run = %d, want %d", code, http.StatusConflict)
+	}
+
+	if code := ts.do(http.MethodPost, "/runs/"+first.ID+"/stop", nil, nil); code != http.StatusAccepted {
+		t.Errorf("stop running run = %d, want %d", code, http.StatusAccepted)
+	}
+	ts.wait(first.ID, inState(StateStopped))
+
+	var results resultsView
+	if code := ts.do(http.MethodGet, "/runs/"+first.ID+"/results", nil, &results); code != http.StatusOK {
+		t.Fatalf("results = %d, want %d", code, http.StatusOK)
+	}
+	if len(results.Summary) == 0 {
+		t.Error("results have no summary")
+	}
+
+	if code := ts.do(http.MethodGet, "/runs/missing", nil, nil); code != http.StatusNotFound {
+		t.Errorf("GET unknown run = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestServerAdjust(t *testing.T) {
+	ts := newTestServer(t)
+
+	run := ts.submit(slowPub())
+	ts.wait(run.ID, inState(StateRunning))
+	path := "/runs/" + run.ID
+
+	var v runView
+	if code := ts.do(http.MethodPatch, path, map[string]any{"delayMs": 5}, &v); code != http.StatusOK {
+		t.Fatalf("PATCH delayMs = %d, want %d", code, http.StatusOK)
+	}
+	if v.Status == nil || v.Status.DelayMs != 5 {
+		t.Errorf("status after PATCH delayMs = %+v, want delayMs 5", v.Status)
+	}
+	if code := ts.do(http.MethodPatch, path, map[string]any{"rate": 10}, &v); code != http.StatusOK || v.Status.DelayMs != 100 {
+		t.Errorf("PATCH rate = %d with delayMs %v, want %d with 100", code, v.Status.DelayMs, http.StatusOK)
+	}
+
+	if code := ts.do(http.MethodPatch, path, map[string]any{"clients": 3}, nil); code != http.StatusOK {
+		t.Fatalf("PATCH clients = %d, want %d", code, http.StatusOK)
+	}
+	ts.wait(run.ID, func(v runView) bool { return v.Status != nil && v.Status.Clients == 3 })
+
+	for _, body := range []map[string]any{
+		{"delayMs": 5, "rate": 10},
+		{"rate": 0},
+		{"delay": 5},
+	} {
+		if code := ts.do(http.MethodPatch, path, body, nil); code != http.StatusBadRequest {
+			t.Errorf("PATCH %v = %d, want %d", body, code, http.StatusBadRequest)
+		}
+	}
+
+	ts.do(http.MethodPost, path+"/stop", nil, nil)
+	ts.wait(run.ID, inState(StateStopped))
+	if code := ts.do(http.MethodPatch, path, map[string]any{"delayMs": 5}, nil); code != http.StatusConflict {
+		t.Errorf("PATCH a stopped run = %d, want %d", code, http.StatusConflict)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	ts := newTestServer(t, WithQueueSize(1))
+
+	active := ts.submit(slowPub())
+	ts.wait(active.ID, inState(StateRunning))
+	queued := ts.submit(slowPub())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := ts.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	// Shutting down again only waits
+	if err := ts.Shutdown(ctx); err != nil {
+		t.Fatalf("second Shutdown: %v", err)
+	}
+
+	ts.mu.Lock()
+	activeState, queuedState := ts.runs[active.ID].State, ts.runs[queued.ID].State
+	ts.mu.Unlock()
+	if activeState != StateStopped {
+		t.Errorf("active run = %s, want %s", activeState, StateStopped)
+	}
+	if queuedState != StateCancelled {
+		t.Errorf("queued run = %s, want %s", queuedState, StateCancelled)
+	}
+	if _, err := ts.Server.submit(slowPub()); err == nil {
+		t.Error("submit after Shutdown succeeded")
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/bench"
+	"github.com/rayomqio/benchmq/internal/mqtt"
+	"github.com/rayomqio/benchmq/internal/payload"
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Benchmark types a spec can run
+const (
+	TypeConn        = "conn"
+	TypePub         = "pub"
+	TypeSub         = "sub"
+	TypePropagation = "propagation"
+)
+
+// Spec describes a benchmark submitted to the API, unset fields keep the
+// bench defaults and the broker of the config file
+type Spec struct {
+	Type         string   `json:"type"`
+	Host         string   `json:"host,omitempty"`
+	Port         uint16   `json:"port,omitempty"`
+	Brokers      []string `json:"brokers,omitempty"`
+	Strategy     string   `json:"strategy,omitempty"`
+	ClientID     string   `json:"clientId,omitempty"`
+	Clients      int      `json:"clients,omitempty"`
+	Count        int      `json:"count,omitempty"`
+	DelayMs      *int     `json:"delayMs,omitempty"`
+	Topic        string   `json:"topic,omitempty"`
+	QoS          uint16   `json:"qos,omitempty"`
+	Retained     bool     `json:"retained,omitempty"`
+	CleanSession *bool    `json:"cleanSession,omitempty"`
+	KeepAlive    uint16   `json:"keepAlive,omitempty"`
+	Username     string   `json:"username,omitempty"`
+	Password     string   `json:"password,omitempty"`
+	Message      string   `json:"message,omitempty"`
+	PayloadSize  int      `json:"payloadSize,omitempty"`
+	Async        bool     `json:"async,omitempty"`
+	MaxInflight  int      `json:"maxInflight,omitempty"`
+	ClientImpl   string   `json:"clientImpl,omitempty"`
+	Hold         string   `json:"hold,omitempty"`
+	Drain        string   `json:"drain,omitempty"`
+	IdleTimeout  string   `json:"idleTimeout,omitempty"`
+	Deadline     string   `json:"deadline,omitempty"`
+	PubNodes     []string `json:"pubNodes,omitempty"`
+	SubNodes     []string `json:"subNodes,omitempty"`
+	Chaos        string   `json:"chaos,omitempty"`
+	SysStats     bool     `json:"sysStats,omitempty"`
+	Interval     string   `json:"timelineInterval,omitempty"`
+}
+
+// redacted returns the spec without its password
+func (s Spec) redacted() Spec {
+	if s.Password != "" {
+		s.Password = "********"
+	}
+	return s
+}
+
+// options translates the spec into bench options, it fails on an unknown
+// type or a malformed duration
+func (s Spec) options() ([]bench.Option, error) {
+	switch s.Type {
+	case TypeConn, TypePub, TypeSub, TypePropagation:
+	default:
+		return nil, invalidSpec(fmt.Errorf("type must be conn, pub, sub or propagation, got %q", s.Type))
+	}
+
+	hold, err := parseDuration("hold", s.Hold)
+	if err != nil {
+		return nil, err
+	}
+	drain, err := parseDuration("drain", s.Drain)
+	if err != nil {
+		return nil, err
+	}
+	idle, err := parseDuration("idleTimeout", s.IdleTimeout)
+	if err != nil {
+		return nil, err
+	}
+	deadline, err := parseDuration("deadline", s.Deadline)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := parseDuration("timelineInterval", s.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []bench.Option{
+		bench.WithBrokers(s.Brokers),
+		bench.WithStrategy(bench.Strategy(s.Strategy)),
+		bench.WithPublishNodes(s.PubNodes),
+		bench.WithSubscribeNodes(s.SubNodes),
+		bench.WithQoS(s.QoS),
+		bench.WithRetained(s.Retained),
+		bench.WithAsync(s.Async),
+		bench.WithHold(hold),
+		bench.WithDeadline(deadline),
+		bench.WithChaos(s.Chaos),
+		bench.WithSysStats(s.SysStats, ""),
+		bench.WithUsername(s.Username),
+		bench.WithPassword(s.Password),
+	}
+	if s.Host != "" {
+		options = append(options, bench.WithHost(s.Host))
+	}
+	if s.Port != 0 {
+		options = append(options, bench.WithPort(s.Port))
+	}
+	if s.ClientID != "" {
+		options = append(options, bench.WithClientID(s.ClientID))
+	}
+	if s.Clients != 0 {
+		options = append(options, bench.WithClients(s.Clients))
+	}
+	if s.Count != 0 {
+		options = append(options, bench.WithMessageCount(s.Count))
+	}
+	if s.DelayMs != nil {
+		options = append(options, bench.WithDelay(*s.DelayMs))
+	}
+	if s.Topic != "" {
+		options = append(options, bench.WithTopic(s.Topic))
+	}
+	if s.CleanSession != nil {
+		options = append(options, bench.WithCleanSession(*s.CleanSession))
+	}
+	if s.KeepAlive != 0 {
+		options = append(options, bench.WithKeepAlive(s.KeepAlive))
+	}
+	if s.Message != "" {
+		options = append(options, bench.WithMessage(s.Message))
+	}
+	if s.PayloadSize != 0 {
+		gen, err := payload.New(payload.Spec{Size: s.PayloadSize})
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, bench.WithPayload(gen))
+	}
+	if s.MaxInflight != 0 {
+		options = append(options, bench.WithMaxInflight(s.MaxInflight))
+	}
+	if s.ClientImpl != "" {
+		options = append(options, bench.WithClientImpl(mqtt.Implementation(s.ClientImpl)))
+	}
+	if s.Drain != "" {
+		options = append(options, bench.WithDrain(drain))
+	}
+	if s.IdleTimeout != "" {
+		options = append(options, bench.WithIdleTimeout(idle))
+	}
+	if s.Interval != "" {
+		options = append(options, bench.WithTimeline(interval, "", ""))
+	}
+	return options, nil
+}
+
+// run starts the benchmark of the spec type and blocks until it finished
+func (s Spec) run(b *bench.Bench) {
+	switch s.Type {
+	case TypeConn:
+		b.RunConnections()
+	case TypePub:
+		b.PublishMessages()
+	case TypeSub:
+		b.Subscribe()
+	case TypePropagation:
+		b.RunPropagation()
+	}
+}
+
+func parseDuration(field, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, invalidSpec(fmt.Errorf("%s: %w", field, err))
+	}
+	return d, nil
+}
+
+func invalidSpec(err error) error {
+	return &er.Error{
+		Package: "Server",
+		Func:    "Spec",
+		Message: er.ErrInvalidSpec,
+		Raw:     err,
+	}
+}
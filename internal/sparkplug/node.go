@@ -0,0 +1,149 @@
+package sparkplug
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Message is a generated Sparkplug B message
+type Message struct {
+	Topic   string
+	Type    MessageType
+	Payload []byte
+}
+
+// Metric names defined by the specification
+const (
+	metricBdSeq   = "bdSeq"
+	metricRebirth = "Node Control/Rebirth"
+)
+
+// Node generates the messages of one edge node session with its devices.
+// Every message but NDEATH carries the node sequence number, which starts at
+// 0 with NBIRTH and wraps after 255. The birth/death sequence number bdSeq
+// ties NDEATH, registered as will, to the NBIRTH of the same session.
+type Node struct {
+	group   string
+	node    string
+	devices []string
+	metrics int
+	bdSeq   uint64
+	seq     uint64
+	next    int   // Rotation over the node and its devices for data messages
+	ticks   int64 // Data messages generated, drives the metric values
+}
+
+// NewNode creates the generator of edge node node in group with devices
+// devices and metrics metrics per message, bdSeq numbers the session
+func NewNode(group, node string, devices, metrics int, bdSeq uint64) (*Node, error) {
+	if !ValidID(group) || !ValidID(node) || devices < 0 || metrics < 0 {
+		return nil, &er.Error{
+			Package: "Sparkplug",
+			Func:    "NewNode",
+			Message: er.ErrInvalidSparkplug,
+			Raw:     fmt.Errorf("group %q, node %q, %d devices, %d metrics", group, node, devices, metrics),
+		}
+	}
+
+	n := &Node{group: group, node: node, metrics: metrics, bdSeq: bdSeq % 256}
+	for i := range devices {
+		n.devices = append(n.devices, "device-"+strconv.Itoa(i))
+	}
+	return n, nil
+}
+
+// Death returns the NDEATH message to register as will before connecting
+func (n *Node) Death() Message {
+	p := Payload{
+		Timestamp: now(),
+		Metrics:   []Metric{{Name: metricBdSeq, Timestamp: now(), DataType: Int64, Value: int64(n.bdSeq)}},
+	}
+	return n.message(NDEATH, "", p)
+}
+
+// Births returns NBIRTH and a DBIRTH per device, they define every metric
+// with its name, alias and type
+func (n *Node) Births() []Message {
+	n.seq = 0
+	ts := now()
+
+	metrics := []Metric{
+		{Name: metricBdSeq, Timestamp: ts, DataType: Int64, Value: int64(n.bdSeq)},
+		{Name: metricRebirth, Timestamp: ts, DataType: Boolean, Value: false},
+	}
+	metrics = append(metrics, n.values(ts, true)...)
+	births := []Message{n.message(NBIRTH, "", Payload{Timestamp: ts, Metrics: metrics, Seq: 0, HasSeq: true})}
+
+	for _, device := range n.devices {
+		births = append(births, n.message(DBIRTH, device, n.sequenced(ts, n.values(ts, true))))
+	}
+	return births
+}
+
+// Data returns the next NDATA or DDATA message, rotating over the node and
+// its devices. Data messages refer to the metrics by alias only.
+func (n *Node) Data() Message {
+	target := n.next
+	n.next = (n.next + 1) % (len(n.devices) + 1)
+	n.ticks++
+
+	ts := now()
+	p := n.sequenced(ts, n.values(ts, false))
+	if target == 0 {
+		return n.message(NDATA, "", p)
+	}
+	return n.message(DDATA, n.devices[target-1], p)
+}
+
+// DeviceDeaths returns a DDEATH per device
+func (n *Node) DeviceDeaths() []Message {
+	var deaths []Message
+	for _, device := range n.devices {
+		deaths = append(deaths, n.message(DDEATH, device, n.sequenced(now(), nil)))
+	}
+	return deaths
+}
+
+// sequenced returns a payload with the next sequence number
+func (n *Node) sequenced(ts uint64, metrics []Metric) Payload {
+	n.seq = (n.seq + 1) % 256
+	return Payload{Timestamp: ts, Metrics: metrics, Seq: n.seq, HasSeq: true}
+}
+
+// values returns the metrics of a message, births name them. The types
+// rotate so every message carries integers, floats, booleans and strings.
+func (n *Node) values(ts uint64, birth bool) []Metric {
+	metrics := make([]Metric, n.metrics)
+	for i := range metrics {
+		m := Metric{Alias: uint64(i), HasAlias: true, Timestamp: ts}
+		if birth {
+			m.Name = "metric-" + strconv.Itoa(i)
+		}
+		x := float64(n.ticks + int64(i))
+		switch i % 4 {
+		case 0:
+			m.DataType, m.Value = Int64, n.ticks+int64(i)
+		case 1:
+			m.DataType, m.Value = Double, math.Sin(x/10)*100
+		case 2:
+			m.DataType, m.Value = Boolean, (n.ticks+int64(i))%2 == 0
+		case 3:
+			m.DataType, m.Value = String, "value-"+strconv.FormatInt(n.ticks, 10)
+		}
+		metrics[i] = m
+	}
+	return metrics
+}
+
+func (n *Node) message(t MessageType, device string, p Payload) Message {
+	topic := Topic{Group: n.group, Type: t, Node: n.node, Device: device}
+	return Message{Topic: topic.String(), Type: t, Payload: Encode(p)}
+}
+
+func now() uint64 {
+	return uint64(time.Now().UnixMilli())
+}
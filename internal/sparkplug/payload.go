@@ -0,0 +1,212 @@
+package sparkplug
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/rayomqio/benchmq/internal/pb"
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// DataType is the Sparkplug B type of a metric value
+type DataType uint32
+
+const (
+	Int32   DataType = 3
+	Int64   DataType = 4
+	UInt32  DataType = 7
+	UInt64  DataType = 8
+	Float   DataType = 9
+	Double  DataType = 10
+	Boolean DataType = 11
+	String  DataType = 12
+)
+
+// Metric is one value of a payload. Value holds an int64 for the integer
+// types, a float64 for Float and Double, a bool or a string.
+type Metric struct {
+	Name      string
+	Alias     uint64
+	HasAlias  bool
+	Timestamp uint64
+	DataType  DataType
+	Value     any
+}
+
+// Payload is a Sparkplug B payload, Seq is unset in NDEATH messages
+type Payload struct {
+	Timestamp uint64
+	Metrics   []Metric
+	Seq       uint64
+	HasSeq    bool
+}
+
+// Field numbers of the Sparkplug B protobuf schema
+const (
+	payloadTimestamp = 1
+	payloadMetrics   = 2
+	payloadSeq       = 3
+
+	metricName      = 1
+	metricAlias     = 2
+	metricTimestamp = 3
+	metricDataType  = 4
+	metricIsNull    = 7
+	metricInt       = 10
+	metricLong      = 11
+	metricFloat     = 12
+	metricDouble    = 13
+	metricBool      = 14
+	metricString    = 15
+)
+
+// Encode returns the protobuf encoding of p
+func Encode(p Payload) []byte {
+	var e pb.Encoder
+	e.Varint(payloadTimestamp, p.Timestamp)
+	for _, m := range p.Metrics {
+		e.Message(payloadMetrics, func(e *pb.Encoder) { encodeMetric(e, m) })
+	}
+	if p.HasSeq {
+		e.Varint(payloadSeq, p.Seq)
+	}
+	return e.Encoded()
+}
+
+func encodeMetric(e *pb.Encoder, m Metric) {
+	if m.Name != "" {
+		e.String(metricName, m.Name)
+	}
+	if m.HasAlias {
+		e.Varint(metricAlias, m.Alias)
+	}
+	e.Varint(metricTimestamp, m.Timestamp)
+	e.Varint(metricDataType, uint64(m.DataType))
+
+	switch v := m.Value.(type) {
+	case nil:
+		e.Bool(metricIsNull, true)
+	case int64:
+		if m.DataType == Int32 || m.DataType == UInt32 {
+			e.Varint(metricInt, uint64(uint32(v)))
+		} else {
+			e.Varint(metricLong, uint64(v))
+		}
+	case float64:
+		if m.DataType == Float {
+			e.Float(metricFloat, float32(v))
+		} else {
+			e.Double(metricDouble, v)
+		}
+	case bool:
+		e.Bool(metricBool, v)
+	case string:
+		e.String(metricString, v)
+	}
+}
+
+// Decode parses a Sparkplug B payload, fields outside the supported subset
+// are skipped
+func Decode(b []byte) (Payload, error) {
+	var p Payload
+	d := pb.NewDecoder(b)
+	for {
+		field, wire, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			return p, nil
+		}
+		if err != nil {
+			return p, err
+		}
+
+		switch {
+		case field == payloadTimestamp && wire == pb.WireVarint:
+			p.Timestamp, err = d.Varint()
+		case field == payloadSeq && wire == pb.WireVarint:
+			p.Seq, err = d.Varint()
+			p.HasSeq = true
+		case field == payloadMetrics && wire == pb.WireBytes:
+			var raw []byte
+			if raw, err = d.Bytes(); err == nil {
+				var m Metric
+				if m, err = decodeMetric(raw); err == nil {
+					p.Metrics = append(p.Metrics, m)
+				}
+			}
+		default:
+			err = d.Skip(wire)
+		}
+		if err != nil {
+			return p, err
+		}
+	}
+}
+
+func decodeMetric(b []byte) (Metric, error) {
+	var m Metric
+	d := pb.NewDecoder(b)
+	for {
+		field, wire, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			return m, nil
+		}
+		if err != nil {
+			return m, err
+		}
+
+		var n uint64
+		switch {
+		case field == metricName && wire == pb.WireBytes:
+			var raw []byte
+			raw, err = d.Bytes()
+			m.Name = string(raw)
+		case field == metricAlias && wire == pb.WireVarint:
+			m.Alias, err = d.Varint()
+			m.HasAlias = true
+		case field == metricTimestamp && wire == pb.WireVarint:
+			m.Timestamp, err = d.Varint()
+		case field == metricDataType && wire == pb.WireVarint:
+			n, err = d.Varint()
+			m.DataType = DataType(n)
+		case field == metricInt && wire == pb.WireVarint:
+			n, err = d.Varint()
+			m.Value = int64(int32(n))
+			if m.DataType == UInt32 {
+				m.Value = int64(uint32(n))
+			}
+		case field == metricLong && wire == pb.WireVarint:
+			n, err = d.Varint()
+			m.Value = int64(n)
+		case field == metricFloat && wire == pb.WireFixed32:
+			var bits uint32
+			bits, err = d.Fixed32()
+			m.Value = float64(math.Float32frombits(bits))
+		case field == metricDouble && wire == pb.WireFixed64:
+			n, err = d.Fixed64()
+			m.Value = math.Float64frombits(n)
+		case field == metricBool && wire == pb.WireVarint:
+			n, err = d.Varint()
+			m.Value = n != 0
+		case field == metricString && wire == pb.WireBytes:
+			var raw []byte
+			raw, err = d.Bytes()
+			m.Value = string(raw)
+		default:
+			err = d.Skip(wire)
+		}
+		if err != nil {
+			return m, err
+		}
+	}
+}
+
+// invalid wraps the reason a message doesn't follow Sparkplug B
+func invalid(fn string, err error) error {
+	return &er.Error{
+		Package: "Sparkplug",
+		Func:    fn,
+		Message: er.ErrInvalidSparkplugMsg,
+		Raw:     err,
+	}
+}
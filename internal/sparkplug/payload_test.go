@@ -0,0 +1,76 @@
+package sparkplug
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload Payload
+	}{
+		{"empty", Payload{}},
+		{"death", Payload{Timestamp: 1700000000000, Metrics: []Metric{
+			{Name: "bdSeq", Timestamp: 1700000000000, DataType: Int64, Value: int64(7)},
+		}}},
+		{"sequence", Payload{Timestamp: 1, Seq: 255, HasSeq: true}},
+		{"all types", Payload{Timestamp: 2, Seq: 1, HasSeq: true, Metrics: []Metric{
+			{Name: "int32", Alias: 1, HasAlias: true, Timestamp: 2, DataType: Int32, Value: int64(-5)},
+			{Name: "int64", Alias: 2, HasAlias: true, Timestamp: 2, DataType: Int64, Value: int64(-1 << 40)},
+			{Name: "uint32", Timestamp: 2, DataType: UInt32, Value: int64(1<<32 - 1)},
+			{Name: "uint64", Timestamp: 2, DataType: UInt64, Value: int64(1 << 62)},
+			{Name: "float", Timestamp: 2, DataType: Float, Value: float64(1.5)},
+			{Name: "double", Timestamp: 2, DataType: Double, Value: 3.141592653589793},
+			{Name: "boolean", Timestamp: 2, DataType: Boolean, Value: true},
+			{Name: "string", Timestamp: 2, DataType: String, Value: "benchmq"},
+			{Name: "empty string", Timestamp: 2, DataType: String, Value: ""},
+		}}},
+		{"aliases only", Payload{Timestamp: 3, Seq: 4, HasSeq: true, Metrics: []Metric{
+			{Alias: 1, HasAlias: true, Timestamp: 3, DataType: Int64, Value: int64(42)},
+			{Alias: 2, HasAlias: true, Timestamp: 3, DataType: Boolean, Value: false},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(Encode(tt.payload))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.payload) {
+				t.Errorf("Decode(Encode()) = %+v, want %+v", got, tt.payload)
+			}
+		})
+	}
+}
+
+func TestPayloadEncoding(t *testing.T) {
+	p := Payload{Timestamp: 1, Seq: 2, HasSeq: true, Metrics: []Metric{
+		{Name: "a", Timestamp: 1, DataType: Boolean, Value: true},
+	}}
+	want := []byte{
+		0x08, 0x01, // timestamp
+		0x12, 0x09, // metrics
+		0x0a, 0x01, 'a', // name
+		0x18, 0x01, // timestamp
+		0x20, 0x0b, // datatype
+		0x70, 0x01, // boolean_value
+		0x18, 0x02, // seq
+	}
+	if got := Encode(p); !bytes.Equal(got, want) {
+		t.Errorf("Encode() = %x, want %x", got, want)
+	}
+}
+
+func TestPayloadMalformed(t *testing.T) {
+	tests := [][]byte{
+		{0x08},             // Truncated varint
+		{0x12, 0x05, 0x0a}, // Metric longer than the payload
+	}
+	for _, b := range tests {
+		if _, err := Decode(b); err == nil {
+			t.Errorf("Decode(%x) succeeded", b)
+		}
+	}
+}
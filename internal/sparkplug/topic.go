@@ -0,0 +1,77 @@
+// Package sparkplug generates and validates Sparkplug B messages: the
+// spBv1.0 topic namespace, protobuf payloads, and the birth, data and death
+// lifecycle of edge nodes and their devices with its sequence numbers
+package sparkplug
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Namespace is the first topic level of Sparkplug B
+const Namespace = "spBv1.0"
+
+// MessageType is the third topic level
+type MessageType string
+
+const (
+	NBIRTH MessageType = "NBIRTH" // Edge node birth, starts a session
+	NDEATH MessageType = "NDEATH" // Edge node death, the will of the session
+	DBIRTH MessageType = "DBIRTH" // Device birth
+	DDEATH MessageType = "DDEATH" // Device death
+	NDATA  MessageType = "NDATA"  // Edge node metrics
+	DDATA  MessageType = "DDATA"  // Device metrics
+	NCMD   MessageType = "NCMD"   // Command to an edge node
+	DCMD   MessageType = "DCMD"   // Command to a device
+)
+
+// Topic is a parsed spBv1.0/group/type/node[/device] topic
+type Topic struct {
+	Group  string
+	Type   MessageType
+	Node   string
+	Device string
+}
+
+// String returns the topic name
+func (t Topic) String() string {
+	s := Namespace + "/" + t.Group + "/" + string(t.Type) + "/" + t.Node
+	if t.Device != "" {
+		s += "/" + t.Device
+	}
+	return s
+}
+
+// Filter returns the subscription covering every message of group
+func Filter(group string) string {
+	return Namespace + "/" + group + "/#"
+}
+
+// ParseTopic parses a Sparkplug B topic name
+func ParseTopic(name string) (Topic, error) {
+	levels := strings.Split(name, "/")
+	if len(levels) < 4 || len(levels) > 5 || levels[0] != Namespace {
+		return Topic{}, invalid("ParseTopic", fmt.Errorf("topic %q is not spBv1.0/group/type/node[/device]", name))
+	}
+
+	t := Topic{Group: levels[1], Type: MessageType(levels[2]), Node: levels[3]}
+	if len(levels) == 5 {
+		t.Device = levels[4]
+	}
+
+	device := t.Type == DBIRTH || t.Type == DDEATH || t.Type == DDATA || t.Type == DCMD
+	switch t.Type {
+	case NBIRTH, NDEATH, NDATA, NCMD, DBIRTH, DDEATH, DDATA, DCMD:
+	default:
+		return Topic{}, invalid("ParseTopic", fmt.Errorf("unknown message type %q", t.Type))
+	}
+	if device != (t.Device != "") {
+		return Topic{}, invalid("ParseTopic", fmt.Errorf("%s topic %q has the wrong number of levels", t.Type, name))
+	}
+	return t, nil
+}
+
+// ValidID reports whether id can be used as a group, node or device id
+func ValidID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, "/+#")
+}
@@ -0,0 +1,160 @@
+package sparkplug
+
+import (
+	"sync"
+)
+
+// Stats counts what a validator saw
+type Stats struct {
+	Messages        int64
+	Births          int64
+	Deaths          int64
+	Data            int64
+	DecodeErrors    int64 // Payloads that aren't Sparkplug B protobuf
+	TopicErrors     int64 // Topics outside the spBv1.0 namespace rules
+	SeqGaps         int64 // Sequence numbers skipped, lost messages
+	SeqErrors       int64 // Duplicated or reordered sequence numbers
+	Unborn          int64 // Messages of a node or device before its birth
+	BdSeqMismatches int64 // NDEATH whose bdSeq doesn't match the NBIRTH
+	Nodes           int64 // Edge nodes born at least once
+}
+
+// session is the state of one edge node seen by a validator
+type session struct {
+	born    bool
+	bdSeq   uint64
+	seq     uint64
+	devices map[string]bool
+}
+
+// Validator checks the messages of edge nodes follow the Sparkplug B
+// lifecycle and that their sequence numbers increase without gaps
+type Validator struct {
+	mu    sync.Mutex
+	nodes map[string]*session
+	stats Stats
+}
+
+// NewValidator returns an empty validator
+func NewValidator() *Validator {
+	return &Validator{nodes: make(map[string]*session)}
+}
+
+// Check validates a message and records it, the error tells why it is invalid
+func (v *Validator) Check(topic string, payload []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.stats.Messages++
+
+	t, err := ParseTopic(topic)
+	if err != nil {
+		v.stats.TopicErrors++
+		return err
+	}
+	p, err := Decode(payload)
+	if err != nil {
+		v.stats.DecodeErrors++
+		return err
+	}
+
+	key := t.Group + "/" + t.Node
+	s := v.nodes[key]
+	if s == nil {
+		s = &session{devices: make(map[string]bool)}
+		v.nodes[key] = s
+	}
+
+	switch t.Type {
+	case NBIRTH:
+		v.stats.Births++
+		if !s.born {
+			v.stats.Nodes++
+		}
+		*s = session{born: true, seq: p.Seq, devices: make(map[string]bool)}
+		s.bdSeq, _ = bdSeq(p)
+		if !p.HasSeq || p.Seq != 0 {
+			v.stats.SeqErrors++
+		}
+		return nil
+	case NDEATH:
+		v.stats.Deaths++
+		if n, ok := bdSeq(p); !ok || !s.born || n != s.bdSeq {
+			v.stats.BdSeqMismatches++
+		}
+		s.born = false
+		return nil
+	case NCMD, DCMD:
+		// Commands come from host applications and carry no sequence number
+		return nil
+	}
+
+	if !s.born || (t.Device != "" && t.Type != DBIRTH && !s.devices[t.Device]) {
+		v.stats.Unborn++
+	}
+	switch t.Type {
+	case DBIRTH:
+		v.stats.Births++
+		s.devices[t.Device] = true
+	case DDEATH:
+		v.stats.Deaths++
+		delete(s.devices, t.Device)
+	default:
+		v.stats.Data++
+	}
+	if s.born {
+		v.sequence(s, p)
+	}
+	return nil
+}
+
+// sequence checks p follows the last sequence number of the node
+func (v *Validator) sequence(s *session, p Payload) {
+	if !p.HasSeq || p.Seq > 255 {
+		v.stats.SeqErrors++
+		return
+	}
+	diff := (p.Seq + 256 - s.seq) % 256
+	switch {
+	case diff == 1:
+	case diff > 1 && diff < 128:
+		v.stats.SeqGaps += int64(diff - 1)
+	default:
+		v.stats.SeqErrors++
+		return
+	}
+	s.seq = p.Seq
+}
+
+// Stats returns the counts so far
+func (v *Validator) Stats() Stats {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.stats
+}
+
+// Add sums other into s
+func (s *Stats) Add(other Stats) {
+	s.Messages += other.Messages
+	s.Births += other.Births
+	s.Deaths += other.Deaths
+	s.Data += other.Data
+	s.DecodeErrors += other.DecodeErrors
+	s.TopicErrors += other.TopicErrors
+	s.SeqGaps += other.SeqGaps
+	s.SeqErrors += other.SeqErrors
+	s.Unborn += other.Unborn
+	s.BdSeqMismatches += other.BdSeqMismatches
+	s.Nodes += other.Nodes
+}
+
+// bdSeq returns the bdSeq metric of a birth or death payload
+func bdSeq(p Payload) (uint64, bool) {
+	for _, m := range p.Metrics {
+		if m.Name == metricBdSeq {
+			if n, ok := m.Value.(int64); ok {
+				return uint64(n), true
+			}
+		}
+	}
+	return 0, false
+}
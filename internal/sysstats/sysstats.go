@@ -0,0 +1,153 @@
+package sysstats
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/mqtt"
+)
+
+// DefaultFilter is the topic filter most brokers publish their statistics on
+const DefaultFilter = "$SYS/#"
+
+// Point is one value a broker published
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is the numeric history of one statistic
+type Series struct {
+	Topic  string
+	Points []Point
+}
+
+// Collector subscribes to broker statistics during a run and records every
+// numeric value as a time series. Non-numeric payloads are skipped.
+type Collector struct {
+	client mqtt.Client
+	filter string
+
+	mu       sync.Mutex
+	series   map[string]*Series
+	messages int64 // Messages received
+	skipped  int64 // Messages without a numeric value
+}
+
+// New creates a collector on a client that is not connected yet
+func New(client mqtt.Client, filter string) *Collector {
+	if filter == "" {
+		filter = DefaultFilter
+	}
+	return &Collector{
+		client: client,
+		filter: filter,
+		series: make(map[string]*Series),
+	}
+}
+
+// Start connects the collector client and subscribes to the filter
+func (c *Collector) Start() error {
+	if err := c.client.Connect(); err != nil {
+		return err
+	}
+	if err := c.client.SubscribeMessages(c.filter, 0, c.record); err != nil {
+		c.client.Disconnect()
+		return err
+	}
+	return nil
+}
+
+// Stop unsubscribes, disconnects and returns the series sorted by topic
+func (c *Collector) Stop() []Series {
+	_ = c.client.Unsubscribe(c.filter)
+	c.client.Disconnect()
+	return c.Series()
+}
+
+// Series returns a copy of the series recorded so far, sorted by topic
+func (c *Collector) Series() []Series {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	series := make([]Series, 0, len(c.series))
+	for _, s := range c.series {
+		series = append(series, Series{Topic: s.Topic, Points: slices.Clone(s.Points)})
+	}
+	slices.SortFunc(series, func(a, b Series) int { return strings.Compare(a.Topic, b.Topic) })
+	return series
+}
+
+// Counts returns the messages received and those without a numeric value
+func (c *Collector) Counts() (int64, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.messages, c.skipped
+}
+
+func (c *Collector) record(topic string, payload []byte) {
+	now := time.Now()
+	values := parse(topic, payload)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages++
+	if len(values) == 0 {
+		c.skipped++
+		return
+	}
+	for name, v := range values {
+		s, ok := c.series[name]
+		if !ok {
+			s = &Series{Topic: name}
+			c.series[name] = s
+		}
+		s.Points = append(s.Points, Point{Time: now, Value: v})
+	}
+}
+
+// parse extracts the numeric values of a payload. Plain numbers are taken
+// as is, a number followed by a unit like "1234 seconds" keeps the number,
+// and JSON objects contribute each numeric field as topic/field.
+func parse(topic string, payload []byte) map[string]float64 {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		return nil
+	}
+
+	if payload[0] == '{' {
+		var doc map[string]any
+		if err := json.Unmarshal(payload, &doc); err != nil {
+			return nil
+		}
+		values := make(map[string]float64)
+		flatten(topic, doc, values)
+		return values
+	}
+
+	field, _, _ := strings.Cut(string(payload), " ")
+	v, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return nil
+	}
+	return map[string]float64{topic: v}
+}
+
+// flatten adds the numeric leaves of a JSON document under prefix
+func flatten(prefix string, doc map[string]any, values map[string]float64) {
+	for key, v := range doc {
+		name := prefix + "/" + key
+		switch v := v.(type) {
+		case float64:
+			values[name] = v
+		case map[string]any:
+			flatten(name, v, values)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package timeline
+
+import "time"
+
+// Summary condenses the intervals of a run, it shows what averages hide
+type Summary struct {
+	Intervals       int
+	SentPerSecMin   float64
+	SentPerSecMax   float64
+	RecvPerSecMin   float64
+	RecvPerSecMax   float64
+	LatencyP99Max   time.Duration // Worst per-interval p99
+	Stalls          int           // Intervals without any message while the run was moving messages
+	LongestStall    time.Duration // Longest stretch of consecutive stalled intervals
+	ErrorsPerSecMax float64
+}
+
+// Summarize computes the summary of intervals of the given length. Rates only
+// consider the active part of the run, from the first to the last interval
+// with messages, so ramp-up and drain don't count as stalls. Intervals
+// shorter than half the length, like the last one, are too noisy for rates.
+func Summarize(intervals []Interval, length time.Duration) Summary {
+	sum := Summary{Intervals: len(intervals)}
+
+	first, last := -1, -1
+	for i, in := range intervals {
+		if in.Sent+in.Received > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+		if secs := in.Duration.Seconds(); secs > 0 {
+			sum.ErrorsPerSecMax = max(sum.ErrorsPerSecMax, float64(in.Errors)/secs)
+		}
+		sum.LatencyP99Max = max(sum.LatencyP99Max, in.Latency.P99)
+	}
+	if first < 0 {
+		return sum
+	}
+
+	var stall time.Duration
+	rated := false
+	for _, in := range intervals[first : last+1] {
+		if in.Sent+in.Received == 0 {
+			sum.Stalls++
+			stall += in.Duration
+			sum.LongestStall = max(sum.LongestStall, stall)
+		} else {
+			stall = 0
+		}
+
+		if in.Duration < length/2 {
+			continue
+		}
+		secs := in.Duration.Seconds()
+		sent, received := float64(in.Sent)/secs, float64(in.Received)/secs
+		if !rated {
+			rated = true
+			sum.SentPerSecMin, sum.SentPerSecMax = sent, sent
+			sum.RecvPerSecMin, sum.RecvPerSecMax = received, received
+		}
+		sum.SentPerSecMin, sum.SentPerSecMax = min(sum.SentPerSecMin, sent), max(sum.SentPerSecMax, sent)
+		sum.RecvPerSecMin, sum.RecvPerSecMax = min(sum.RecvPerSecMin, received), max(sum.RecvPerSecMax, received)
+	}
+	return sum
+}
@@ -0,0 +1,44 @@
+package timeline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	sec := time.Second
+	intervals := []Interval{
+		{Duration: sec}, // Ramp-up, not a stall
+		{Duration: sec, Sent: 100, Received: 50},
+		{Duration: sec, Errors: 4}, // Stall
+		{Duration: sec},            // Stall
+		{Duration: sec, Sent: 300, Received: 250},
+		{Duration: sec, Latency: Latency{P99: 40 * time.Millisecond}, Sent: 200, Received: 100},
+		{Duration: 100 * time.Millisecond, Sent: 1}, // Too short for rates
+		{Duration: sec}, // Drain, not a stall
+	}
+
+	got := Summarize(intervals, sec)
+	want := Summary{
+		Intervals:       8,
+		SentPerSecMin:   0,
+		SentPerSecMax:   300,
+		RecvPerSecMin:   0,
+		RecvPerSecMax:   250,
+		LatencyP99Max:   40 * time.Millisecond,
+		Stalls:          2,
+		LongestStall:    2 * sec,
+		ErrorsPerSecMax: 4,
+	}
+	if got != want {
+		t.Errorf("Summarize = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSummarizeIdle(t *testing.T) {
+	got := Summarize([]Interval{{Duration: time.Second}, {Duration: time.Second, Errors: 3}}, time.Second)
+	want := Summary{Intervals: 2, ErrorsPerSecMax: 3}
+	if got != want {
+		t.Errorf("Summarize = %+v, want %+v", got, want)
+	}
+}
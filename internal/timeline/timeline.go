@@ -0,0 +1,236 @@
+package timeline
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rayomqio/benchmq/internal/metrics"
+)
+
+// DefaultInterval is the default length of an interval
+const DefaultInterval = time.Second
+
+// Interval holds what happened during one interval of a run
+type Interval struct {
+	Start     time.Time
+	Offset    time.Duration // Start relative to the start of the run
+	Duration  time.Duration
+	Sent      int64 // Messages published and acknowledged
+	Received  int64 // Messages received
+	Errors    int64 // Failures of any kind
+	Late      int64 // Messages received after the client unsubscribed
+	Connects  int64 // Connections established
+	Active    int64 // Connections open at the end of the interval
+	Latency   Latency
+	Resources *Resources // Last resource sample of the interval, nil without one
+}
+
+// Resources is the resource usage of benchmq in one sample
+type Resources struct {
+	CPUPercent float64
+	RSSBytes   int64
+	Goroutines int
+	GCPause    time.Duration
+	OpenFDs    int
+}
+
+// Latency is the latency distribution of one interval
+type Latency struct {
+	Count uint64
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Recorder buckets the events of a run into fixed intervals, it is safe for
+// concurrent use
+type Recorder struct {
+	interval time.Duration
+
+	sent     atomic.Int64
+	received atomic.Int64
+	errors   atomic.Int64
+	late     atomic.Int64
+	connects atomic.Int64
+	active   atomic.Int64
+	latency  *metrics.Histogram
+
+	mu        sync.Mutex
+	start     time.Time
+	last      time.Time
+	intervals []Interval
+	kinds     map[string]int64 // Errors by kind over the whole run
+	resources *Resources       // Latest sample of the current interval
+
+	sink func(Interval) // Receives every interval as it closes
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type Option func(*Recorder)
+
+// New creates a recorder with intervals of the given length
+func New(interval time.Duration, options ...Option) *Recorder {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	r := &Recorder{
+		interval: interval,
+		latency:  metrics.NewHistogram(),
+		kinds:    make(map[string]int64),
+	}
+	for _, option := range options {
+		if option != nil {
+			option(r)
+		}
+	}
+	return r
+}
+
+// WithSink streams every interval to sink as soon as it closes
+func WithSink(sink func(Interval)) Option {
+	return func(r *Recorder) {
+		r.sink = sink
+	}
+}
+
+// Start begins the first interval
+func (r *Recorder) Start() {
+	r.mu.Lock()
+	r.start = time.Now()
+	r.last = r.start
+	r.mu.Unlock()
+
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run()
+}
+
+// Stop closes the last, possibly partial, interval and returns all of them
+func (r *Recorder) Stop() []Interval {
+	close(r.stop)
+	<-r.done
+	r.flush(time.Now())
+	return r.Intervals()
+}
+
+// Interval returns the configured interval length
+func (r *Recorder) Interval() time.Duration {
+	return r.interval
+}
+
+// Intervals returns the intervals closed so far
+func (r *Recorder) Intervals() []Interval {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interval(nil), r.intervals...)
+}
+
+// Errors returns the error counts by kind
+func (r *Recorder) Errors() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.kinds)
+}
+
+// Sent counts an acknowledged publish, latency is recorded when positive
+func (r *Recorder) Sent(latency time.Duration) {
+	r.sent.Add(1)
+	if latency > 0 {
+		r.latency.Record(latency)
+	}
+}
+
+// Received counts a received message, latency is recorded when positive
+func (r *Recorder) Received(latency time.Duration) {
+	r.received.Add(1)
+	if latency > 0 {
+		r.latency.Record(latency)
+	}
+}
+
+// Error counts a failure of the given kind, like connect or publish
+func (r *Recorder) Error(kind string) {
+	r.errors.Add(1)
+	r.mu.Lock()
+	r.kinds[kind]++
+	r.mu.Unlock()
+}
+
+// Late counts a message that arrived after the client unsubscribed from it,
+// it counts neither as received nor as an error
+func (r *Recorder) Late() {
+	r.late.Add(1)
+}
+
+// Sampled records the resource usage of benchmq, the last sample of an
+// interval is kept with it
+func (r *Recorder) Sampled(res Resources) {
+	r.mu.Lock()
+	r.resources = &res
+	r.mu.Unlock()
+}
+
+// Connected counts an established connection
+func (r *Recorder) Connected() {
+	r.connects.Add(1)
+	r.active.Add(1)
+}
+
+// Disconnected counts a closed connection
+func (r *Recorder) Disconnected() {
+	r.active.Add(-1)
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-ticker.C:
+			r.flush(now)
+		}
+	}
+}
+
+// flush closes the current interval at now
+func (r *Recorder) flush(now time.Time) {
+	h := r.latency.Reset()
+	in := Interval{
+		Sent:     r.sent.Swap(0),
+		Received: r.received.Swap(0),
+		Errors:   r.errors.Swap(0),
+		Late:     r.late.Swap(0),
+		Connects: r.connects.Swap(0),
+		Active:   r.active.Load(),
+		Latency: Latency{
+			Count: h.Count(),
+			P50:   h.Percentile(50),
+			P90:   h.Percentile(90),
+			P99:   h.Percentile(99),
+			Max:   h.Max(),
+		},
+	}
+
+	r.mu.Lock()
+	in.Start = r.last
+	in.Offset = r.last.Sub(r.start)
+	in.Duration = now.Sub(r.last)
+	in.Resources, r.resources = r.resources, nil
+	r.last = now
+	r.intervals = append(r.intervals, in)
+	r.mu.Unlock()
+
+	if r.sink != nil {
+		r.sink(in)
+	}
+}
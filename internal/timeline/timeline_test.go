@@ -0,0 +1,75 @@
+package timeline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	var streamed []Interval
+	r := New(time.Hour, WithSink(func(in Interval) { streamed = append(streamed, in) }))
+	r.Start()
+
+	r.Connected()
+	r.Connected()
+	r.Disconnected()
+	r.Sent(10 * time.Millisecond)
+	r.Sent(0)
+	r.Received(20 * time.Millisecond)
+	r.Late()
+	r.Error("publish")
+	r.Error("publish")
+	r.Error("connect")
+	r.Sampled(Resources{Goroutines: 7})
+
+	intervals := r.Stop()
+	if len(intervals) != 1 || len(streamed) != 1 {
+		t.Fatalf("got %d intervals and %d streamed, want 1 each", len(intervals), len(streamed))
+	}
+	in := intervals[0]
+	if in.Sent != 2 || in.Received != 1 || in.Errors != 3 || in.Late != 1 || in.Connects != 2 || in.Active != 1 {
+		t.Errorf("interval = %+v, want sent 2, received 1, errors 3, late 1, connects 2, active 1", in)
+	}
+	// Zero latencies aren't recorded
+	if in.Latency.Count != 2 {
+		t.Errorf("latency count = %d, want 2", in.Latency.Count)
+	}
+	if in.Resources == nil || in.Resources.Goroutines != 7 {
+		t.Errorf("resources = %+v, want the last sample", in.Resources)
+	}
+	if errs := r.Errors(); errs["publish"] != 2 || errs["connect"] != 1 {
+		t.Errorf("errors = %v, want publish 2 and connect 1", errs)
+	}
+}
+
+func TestRecorderIntervals(t *testing.T) {
+	r := New(20 * time.Millisecond)
+	r.Start()
+	r.Connected()
+	time.Sleep(100 * time.Millisecond)
+	intervals := r.Stop()
+
+	if len(intervals) < 3 {
+		t.Fatalf("got %d intervals, want at least 3", len(intervals))
+	}
+	// Counters reset per interval, open connections carry over
+	if intervals[0].Connects != 1 || intervals[1].Connects != 0 || intervals[1].Active != 1 {
+		t.Errorf("connects, active = %d, %d then %d, %d, want 1, 1 then 0, 1",
+			intervals[0].Connects, intervals[0].Active, intervals[1].Connects, intervals[1].Active)
+	}
+	for i := 1; i < len(intervals); i++ {
+		if got, want := intervals[i].Offset, intervals[i-1].Offset+intervals[i-1].Duration; got != want {
+			t.Errorf("interval %d offset = %s, want %s", i, got, want)
+		}
+	}
+	// The sample ended with its interval
+	if intervals[len(intervals)-1].Resources != nil {
+		t.Error("last interval has a resource sample, want none")
+	}
+}
+
+func TestNewDefaultInterval(t *testing.T) {
+	if got := New(0).Interval(); got != DefaultInterval {
+		t.Errorf("interval = %s, want %s", got, DefaultInterval)
+	}
+}
@@ -0,0 +1,227 @@
+package timeline
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// Format is the encoding of a streamed timeline
+type Format string
+
+const (
+	FormatJSON Format = "jsonl" // One JSON object per line
+	FormatCSV  Format = "csv"   // A header row, then one row per interval
+)
+
+// ParseFormat validates format, an empty format is taken from the extension
+// of path and defaults to JSON lines
+func ParseFormat(format, path string) (Format, error) {
+	if format == "" {
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			return FormatCSV, nil
+		}
+		return FormatJSON, nil
+	}
+	switch f := Format(strings.ToLower(format)); f {
+	case FormatJSON, FormatCSV:
+		return f, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return "", &er.Error{
+			Package: "Timeline",
+			Func:    "ParseFormat",
+			Message: er.ErrInvalidTimeline,
+			Raw:     fmt.Errorf("%w: %q", er.ErrInvalidTimeline, format),
+		}
+	}
+}
+
+// record is the encoded form of an interval, rates are per second
+type record struct {
+	Time           string  `json:"time"`
+	OffsetSec      float64 `json:"offsetSec"`
+	DurationSec    float64 `json:"durationSec"`
+	Sent           int64   `json:"sent"`
+	Received       int64   `json:"received"`
+	Errors         int64   `json:"errors"`
+	Late           int64   `json:"late"`
+	SentPerSec     float64 `json:"sentPerSec"`
+	ReceivedPerSec float64 `json:"receivedPerSec"`
+	ErrorsPerSec   float64 `json:"errorsPerSec"`
+	Connects       int64   `json:"connects"`
+	Active         int64   `json:"active"`
+	LatencyCount   uint64  `json:"latencyCount"`
+	LatencyP50Ms   float64 `json:"latencyP50Ms"`
+	LatencyP90Ms   float64 `json:"latencyP90Ms"`
+	LatencyP99Ms   float64 `json:"latencyP99Ms"`
+	LatencyMaxMs   float64 `json:"latencyMaxMs"`
+	*resourceRecord
+}
+
+// resourceRecord is the encoded resource sample of an interval, left out
+// when the interval has none
+type resourceRecord struct {
+	CPUPercent float64 `json:"cpuPercent"`
+	RSSBytes   int64   `json:"rssBytes"`
+	Goroutines int     `json:"goroutines"`
+	GCPauseMs  float64 `json:"gcPauseMs"`
+	OpenFDs    int     `json:"openFDs"`
+}
+
+// csvHeader matches the field order of record
+var csvHeader = []string{
+	"time", "offsetSec", "durationSec", "sent", "received", "errors", "late",
+	"sentPerSec", "receivedPerSec", "errorsPerSec", "connects", "active",
+	"latencyCount", "latencyP50Ms", "latencyP90Ms", "latencyP99Ms", "latencyMaxMs",
+	"cpuPercent", "rssBytes", "goroutines", "gcPauseMs", "openFDs",
+}
+
+func newRecord(in Interval) record {
+	secs := in.Duration.Seconds()
+	rate := func(n int64) float64 {
+		if secs <= 0 {
+			return 0
+		}
+		return float64(n) / secs
+	}
+	rec := record{
+		Time:           in.Start.Add(in.Duration).Format(time.RFC3339Nano),
+		OffsetSec:      in.Offset.Seconds(),
+		DurationSec:    secs,
+		Sent:           in.Sent,
+		Received:       in.Received,
+		Errors:         in.Errors,
+		Late:           in.Late,
+		SentPerSec:     rate(in.Sent),
+		ReceivedPerSec: rate(in.Received),
+		ErrorsPerSec:   rate(in.Errors),
+		Connects:       in.Connects,
+		Active:         in.Active,
+		LatencyCount:   in.Latency.Count,
+		LatencyP50Ms:   ms(in.Latency.P50),
+		LatencyP90Ms:   ms(in.Latency.P90),
+		LatencyP99Ms:   ms(in.Latency.P99),
+		LatencyMaxMs:   ms(in.Latency.Max),
+	}
+	if res := in.Resources; res != nil {
+		rec.resourceRecord = &resourceRecord{
+			CPUPercent: res.CPUPercent,
+			RSSBytes:   res.RSSBytes,
+			Goroutines: res.Goroutines,
+			GCPauseMs:  ms(res.GCPause),
+			OpenFDs:    res.OpenFDs,
+		}
+	}
+	return rec
+}
+
+func (r record) row() []string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	i := func(v int64) string { return strconv.FormatInt(v, 10) }
+	row := []string{
+		r.Time, f(r.OffsetSec), f(r.DurationSec), i(r.Sent), i(r.Received), i(r.Errors), i(r.Late),
+		f(r.SentPerSec), f(r.ReceivedPerSec), f(r.ErrorsPerSec), i(r.Connects), i(r.Active),
+		strconv.FormatUint(r.LatencyCount, 10), f(r.LatencyP50Ms), f(r.LatencyP90Ms), f(r.LatencyP99Ms), f(r.LatencyMaxMs),
+	}
+	// Intervals without a resource sample leave its columns empty
+	if res := r.resourceRecord; res != nil {
+		return append(row, f(res.CPUPercent), i(res.RSSBytes), strconv.Itoa(res.Goroutines), f(res.GCPauseMs), strconv.Itoa(res.OpenFDs))
+	}
+	return append(row, "", "", "", "", "")
+}
+
+// Writer streams intervals to a file, every interval is flushed as it is
+// written so the file can be followed during the run
+type Writer struct {
+	file   *os.File
+	buf    *bufio.Writer
+	format Format
+	csv    *csv.Writer
+}
+
+// Create opens path for a stream of intervals in format
+func Create(path string, format Format) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, &er.Error{
+			Package: "Timeline",
+			Func:    "Create",
+			Message: er.ErrTimelineWriteFailed,
+			Raw:     err,
+		}
+	}
+
+	w := &Writer{file: f, buf: bufio.NewWriter(f), format: format}
+	if format == FormatCSV {
+		w.csv = csv.NewWriter(w.buf)
+		if err := w.csv.Write(csvHeader); err != nil {
+			_ = f.Close()
+			return nil, &er.Error{
+				Package: "Timeline",
+				Func:    "Create",
+				Message: er.ErrTimelineWriteFailed,
+				Raw:     err,
+			}
+		}
+	}
+	return w, nil
+}
+
+// Write appends one interval and flushes it to the file
+func (w *Writer) Write(in Interval) error {
+	rec := newRecord(in)
+
+	var err error
+	if w.csv != nil {
+		if err = w.csv.Write(rec.row()); err == nil {
+			w.csv.Flush()
+			err = w.csv.Error()
+		}
+	} else {
+		var line []byte
+		if line, err = json.Marshal(rec); err == nil {
+			line = append(line, '\n')
+			_, err = w.buf.Write(line)
+		}
+	}
+	if err == nil {
+		err = w.buf.Flush()
+	}
+	if err != nil {
+		return &er.Error{
+			Package: "Timeline",
+			Func:    "Write",
+			Message: er.ErrTimelineWriteFailed,
+			Raw:     err,
+		}
+	}
+	return nil
+}
+
+// Close closes the file
+func (w *Writer) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// MarshalJSON encodes the interval like a JSON lines timeline record
+func (in Interval) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newRecord(in))
+}
@@ -0,0 +1,171 @@
+package timeline
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIntervals are a full interval with a resource sample and a partial
+// one without
+func testIntervals() []Interval {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []Interval{
+		{
+			Start: start, Duration: time.Second,
+			Sent: 100, Received: 90, Errors: 2, Late: 1, Connects: 10, Active: 10,
+			Latency:   Latency{Count: 100, P50: 2 * time.Millisecond, P90: 5 * time.Millisecond, P99: 9 * time.Millisecond, Max: 12 * time.Millisecond},
+			Resources: &Resources{CPUPercent: 12.5, RSSBytes: 1 << 20, Goroutines: 42, GCPause: 500 * time.Microsecond, OpenFDs: 15},
+		},
+		{
+			Start: start.Add(time.Second), Offset: time.Second, Duration: 500 * time.Millisecond,
+			Sent: 10, Active: 8,
+		},
+	}
+}
+
+// writeIntervals writes intervals in format and returns the file content
+func writeIntervals(t *testing.T, format Format, intervals []Interval) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "timeline")
+	w, err := Create(path, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range intervals {
+		if err := w.Write(in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, tt := range []struct {
+		format, path string
+		want         Format
+	}{
+		{"", "run.jsonl", FormatJSON},
+		{"", "run.CSV", FormatCSV},
+		{"", "run", FormatJSON},
+		{"csv", "run.jsonl", FormatCSV},
+		{"JSON", "run.csv", FormatJSON},
+		{"jsonl", "", FormatJSON},
+	} {
+		got, err := ParseFormat(tt.format, tt.path)
+		if err != nil {
+			t.Errorf("ParseFormat(%q, %q): %v", tt.format, tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFormat(%q, %q) = %q, want %q", tt.format, tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := ParseFormat("xml", "run.xml"); err == nil {
+		t.Error("ParseFormat(xml) succeeded, want error")
+	}
+}
+
+func TestWriterJSON(t *testing.T) {
+	out := writeIntervals(t, FormatJSON, testIntervals())
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), out)
+	}
+
+	var first map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{
+		"time":         "2024-05-01T12:00:01Z",
+		"durationSec":  1.0,
+		"sent":         100.0,
+		"late":         1.0,
+		"sentPerSec":   100.0,
+		"errorsPerSec": 2.0,
+		"latencyP99Ms": 9.0,
+		"cpuPercent":   12.5,
+		"gcPauseMs":    0.5,
+		"openFDs":      15.0,
+	} {
+		if first[key] != want {
+			t.Errorf("first line %s = %v, want %v", key, first[key], want)
+		}
+	}
+
+	var second map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	if second["sentPerSec"] != 20.0 || second["offsetSec"] != 1.0 {
+		t.Errorf("second line sentPerSec, offsetSec = %v, %v, want 20, 1", second["sentPerSec"], second["offsetSec"])
+	}
+	// Intervals without a resource sample leave the resource fields out
+	if _, ok := second["cpuPercent"]; ok {
+		t.Error("second line has cpuPercent, want it left out")
+	}
+}
+
+func TestWriterCSV(t *testing.T) {
+	out := writeIntervals(t, FormatCSV, testIntervals())
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want a header and 2 intervals", len(rows))
+	}
+	if strings.Join(rows[0], ",") != strings.Join(csvHeader, ",") {
+		t.Errorf("header = %v, want %v", rows[0], csvHeader)
+	}
+
+	column := func(row []string, name string) string {
+		for i, h := range csvHeader {
+			if h == name {
+				return row[i]
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return ""
+	}
+	for _, tt := range []struct {
+		row        int
+		name, want string
+	}{
+		{1, "time", "2024-05-01T12:00:01Z"},
+		{1, "received", "90"},
+		{1, "receivedPerSec", "90"},
+		{1, "latencyP50Ms", "2"},
+		{1, "rssBytes", "1048576"},
+		{1, "gcPauseMs", "0.5"},
+		{2, "durationSec", "0.5"},
+		{2, "sentPerSec", "20"},
+		{2, "active", "8"},
+		{2, "cpuPercent", ""},
+		{2, "openFDs", ""},
+	} {
+		if got := column(rows[tt.row], tt.name); got != tt.want {
+			t.Errorf("row %d %s = %q, want %q", tt.row, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCreateInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "timeline.csv")
+	if _, err := Create(path, FormatCSV); err == nil {
+		t.Error("Create in a missing directory succeeded, want error")
+	}
+}
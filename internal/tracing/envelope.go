@@ -0,0 +1,36 @@
+package tracing
+
+import "bytes"
+
+// envelopePrefix starts a payload that carries a traceparent. MQTT 3.1.1 has
+// no user properties, so the context travels in front of the payload as
+// "traceparent:00-<trace-id>-<span-id>-<flags>\n" followed by the original
+// bytes.
+const envelopePrefix = "traceparent:"
+
+// traceparentLen is the length of a version 00 traceparent
+const traceparentLen = 55
+
+// Wrap returns payload behind an envelope carrying sc
+func Wrap(sc SpanContext, payload []byte) []byte {
+	out := make([]byte, 0, len(envelopePrefix)+traceparentLen+1+len(payload))
+	out = append(out, envelopePrefix...)
+	out = append(out, sc.Traceparent()...)
+	out = append(out, '\n')
+	return append(out, payload...)
+}
+
+// Unwrap splits an enveloped payload, as received by subscribers, into its
+// span context and the original payload without copying it. Payloads without
+// a valid envelope are returned unchanged with ok false.
+func Unwrap(payload []byte) (SpanContext, []byte, bool) {
+	n := len(envelopePrefix) + traceparentLen
+	if len(payload) <= n || !bytes.HasPrefix(payload, []byte(envelopePrefix)) || payload[n] != '\n' {
+		return SpanContext{}, payload, false
+	}
+	sc, err := ParseTraceparent(string(payload[len(envelopePrefix):n]))
+	if err != nil {
+		return SpanContext{}, payload, false
+	}
+	return sc, payload[n+1:], true
+}
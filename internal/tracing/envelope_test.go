@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	payload := []byte("temperature=21.5\nhumidity=40")
+
+	wrapped := Wrap(sc, payload)
+	if want := "traceparent:" + sc.Traceparent() + "\n"; !bytes.HasPrefix(wrapped, []byte(want)) {
+		t.Errorf("Wrap = %q, want prefix %q", wrapped, want)
+	}
+
+	got, unwrapped, ok := Unwrap(wrapped)
+	if !ok || got != sc || !bytes.Equal(unwrapped, payload) {
+		t.Errorf("Unwrap = %+v, %q, %v, want %+v, %q, true", got, unwrapped, ok, sc, payload)
+	}
+
+	// An empty payload survives the round trip
+	if _, unwrapped, ok := Unwrap(Wrap(sc, nil)); !ok || len(unwrapped) != 0 {
+		t.Errorf("Unwrap of an empty payload = %q, %v, want empty, true", unwrapped, ok)
+	}
+}
+
+func TestUnwrapInvalid(t *testing.T) {
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID()}
+	valid := Wrap(sc, []byte("x"))
+
+	for _, payload := range [][]byte{
+		nil,
+		[]byte("plain payload"),
+		valid[:len(valid)-2], // Cut short
+		bytes.Replace(valid, []byte("\n"), []byte(" "), 1), // No newline after the traceparent
+		bytes.Replace(valid, []byte("-"), []byte("_"), 1),  // Malformed traceparent
+		append([]byte("traceparent:00-"), bytes.Repeat([]byte("0"), 60)...),
+	} {
+		if _, got, ok := Unwrap(payload); ok || !bytes.Equal(got, payload) {
+			t.Errorf("Unwrap(%q) = %q, %v, want the payload unchanged", payload, got, ok)
+		}
+	}
+}
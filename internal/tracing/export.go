@@ -0,0 +1,218 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+)
+
+// exporter delivers encoded OTLP export requests
+type exporter interface {
+	export(body []byte) error
+	close() error
+}
+
+// otlpExporter posts export requests to an OTLP/HTTP receiver
+type otlpExporter struct {
+	url    string
+	client *http.Client
+}
+
+// newOTLPExporter targets the traces path of endpoint unless endpoint
+// already names a path
+func newOTLPExporter(endpoint string) (*otlpExporter, error) {
+	uri, err := url.Parse(endpoint)
+	if err != nil || (uri.Scheme != "http" && uri.Scheme != "https") || uri.Host == "" {
+		if err == nil {
+			err = fmt.Errorf("unsupported endpoint %q", endpoint)
+		}
+		return nil, &er.Error{
+			Package: "Tracing",
+			Func:    "NewOTLPExporter",
+			Message: er.ErrInvalidTracing,
+			Raw:     err,
+		}
+	}
+	if uri.Path == "" || uri.Path == "/" {
+		uri.Path = "/v1/traces"
+	}
+	return &otlpExporter{url: uri.String(), client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (e *otlpExporter) export(body []byte) error {
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s", e.url, resp.Status)
+	}
+	return nil
+}
+
+func (e *otlpExporter) close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// fileExporter appends one export request per line, the format of the
+// OpenTelemetry Collector file exporter
+type fileExporter struct {
+	file *os.File
+	buf  *bufio.Writer
+}
+
+func newFileExporter(path string) (*fileExporter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, &er.Error{
+			Package: "Tracing",
+			Func:    "NewFileExporter",
+			Message: er.ErrInvalidTracing,
+			Raw:     err,
+		}
+	}
+	return &fileExporter{file: f, buf: bufio.NewWriter(f)}, nil
+}
+
+func (e *fileExporter) export(body []byte) error {
+	if _, err := e.buf.Write(body); err != nil {
+		return err
+	}
+	if err := e.buf.WriteByte('\n'); err != nil {
+		return err
+	}
+	return e.buf.Flush()
+}
+
+func (e *fileExporter) close() error {
+	if err := e.buf.Flush(); err != nil {
+		_ = e.file.Close()
+		return err
+	}
+	return e.file.Close()
+}
+
+// OTLP JSON encoding, see opentelemetry-proto trace/v1. IDs are hex strings
+// and 64-bit integers are decimal strings in the JSON mapping.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []keyValue `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []spanJSON `json:"spans"`
+	}
+	scope struct {
+		Name string `json:"name"`
+	}
+	spanJSON struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              Kind       `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            status     `json:"status"`
+	}
+	status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	anyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// OTLP status codes
+const (
+	statusOK    = 1
+	statusError = 2
+)
+
+// encode builds the OTLP JSON export request of spans
+func encode(service string, spans []*Span) ([]byte, error) {
+	out := make([]spanJSON, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		sj := spanJSON{
+			TraceID:           hex.EncodeToString(s.ctx.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.ctx.SpanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Status:            status{Code: statusOK},
+		}
+		if s.parent != [8]byte{} {
+			sj.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, a := range s.attrs {
+			sj.Attributes = append(sj.Attributes, keyValue{Key: a.key, Value: toAnyValue(a.value)})
+		}
+		if s.err != nil {
+			sj.Status = status{Code: statusError, Message: s.err.Error()}
+		}
+		s.mu.Unlock()
+		out = append(out, sj)
+	}
+
+	req := exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: []keyValue{{Key: "service.name", Value: toAnyValue(service)}}},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: "github.com/rayomqio/benchmq"}, Spans: out}},
+	}}}
+	return json.Marshal(req)
+}
+
+func toAnyValue(v any) anyValue {
+	switch v := v.(type) {
+	case string:
+		return anyValue{StringValue: &v}
+	case bool:
+		return anyValue{BoolValue: &v}
+	case int:
+		s := strconv.Itoa(v)
+		return anyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return anyValue{IntValue: &s}
+	case uint64:
+		s := strconv.FormatUint(v, 10)
+		return anyValue{IntValue: &s}
+	case float64:
+		return anyValue{DoubleValue: &v}
+	default:
+		s := strings.TrimSpace(fmt.Sprint(v))
+		return anyValue{StringValue: &s}
+	}
+}
@@ -0,0 +1,39 @@
+package tracing
+
+import "testing"
+
+func TestTraceparent(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.Sampled || sc.TraceID[0] != 0x4b || sc.SpanID[7] != 0xb7 {
+		t.Errorf("ParseTraceparent = %+v", sc)
+	}
+	if got := sc.Traceparent(); got != tp {
+		t.Errorf("Traceparent = %q, want %q", got, tp)
+	}
+
+	sc.Sampled = false
+	if got, want := sc.Traceparent(), tp[:len(tp)-2]+"00"; got != want {
+		t.Errorf("unsampled Traceparent = %q, want %q", got, want)
+	}
+}
+
+func TestParseTraceparentInvalid(t *testing.T) {
+	for _, tp := range []string{
+		"",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",  // Unknown version
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",   // Short trace ID
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",     // No flags
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",  // Not hex
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",  // Zero trace ID
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",  // Zero span ID
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-", // Extra field
+	} {
+		if _, err := ParseTraceparent(tp); err == nil {
+			t.Errorf("ParseTraceparent(%q) succeeded, want error", tp)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package tracing
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rayomqio/benchmq/pkg/er"
+	"github.com/rayomqio/benchmq/pkg/logger"
+)
+
+const (
+	DefaultService     = "benchmq"   // Default service.name resource attribute
+	DefaultSampleRatio = 1.0         // Default share of root spans recorded
+	batchSize          = 512         // Spans per export request
+	batchTimeout       = time.Second // Longest a span waits for its batch
+	queueSize          = 8192        // Ended spans waiting for export, more are dropped
+)
+
+// Tracer records spans and exports them in batches. A nil tracer, used when
+// tracing is disabled, returns nil spans.
+type Tracer struct {
+	service   string
+	ratio     float64
+	endpoint  string
+	file      string
+	exporters []exporter
+
+	queue   chan *Span
+	dropped atomic.Int64 // Spans dropped on a full queue
+	failed  atomic.Int64 // Spans lost to failed exports
+	wg      sync.WaitGroup
+	logger  *logger.Logger
+}
+
+type Option func(*Tracer)
+
+// New creates a tracer exporting to the configured OTLP endpoint and file,
+// it returns nil when neither is set
+func New(options ...Option) (*Tracer, error) {
+	t := &Tracer{
+		service: DefaultService,
+		ratio:   DefaultSampleRatio,
+		logger:  logger.NewBenchmarkLogger("tracing"),
+	}
+	for _, option := range options {
+		if option != nil {
+			option(t)
+		}
+	}
+
+	if t.endpoint == "" && t.file == "" {
+		return nil, nil
+	}
+	if t.ratio < 0 || t.ratio > 1 {
+		return nil, &er.Error{
+			Package: "Tracing",
+			Func:    "New",
+			Message: er.ErrInvalidTracing,
+			Raw:     er.ErrInvalidTracing,
+		}
+	}
+	if t.endpoint != "" {
+		exp, err := newOTLPExporter(t.endpoint)
+		if err != nil {
+			return nil, err
+		}
+		t.exporters = append(t.exporters, exp)
+	}
+	if t.file != "" {
+		exp, err := newFileExporter(t.file)
+		if err != nil {
+			return nil, err
+		}
+		t.exporters = append(t.exporters, exp)
+	}
+
+	t.queue = make(chan *Span, queueSize)
+	t.wg.Add(1)
+	go t.run()
+	return t, nil
+}
+
+// WithOTLPEndpoint exports spans with OTLP/HTTP JSON to endpoint, like
+// http://localhost:4318
+func WithOTLPEndpoint(endpoint string) Option {
+	return func(t *Tracer) {
+		t.endpoint = endpoint
+	}
+}
+
+// WithFile appends the export requests to a file as JSON lines
+func WithFile(path string) Option {
+	return func(t *Tracer) {
+		t.file = path
+	}
+}
+
+// WithSampleRatio sets the share of root spans recorded, children follow
+// the decision of their parent
+func WithSampleRatio(ratio float64) Option {
+	return func(t *Tracer) {
+		t.ratio = ratio
+	}
+}
+
+// WithService sets the service.name resource attribute
+func WithService(service string) Option {
+	return func(t *Tracer) {
+		if service != "" {
+			t.service = service
+		}
+	}
+}
+
+// Start begins a span. Without a valid parent it starts a new trace, which
+// is sampled by the ratio, otherwise it joins the trace of parent. It
+// returns nil when the span isn't recorded.
+func (t *Tracer) Start(name string, kind Kind, parent SpanContext) *Span {
+	if t == nil {
+		return nil
+	}
+
+	ctx := SpanContext{SpanID: newSpanID()}
+	var parentID [8]byte
+	if parent.IsValid() {
+		if !parent.Sampled {
+			return nil
+		}
+		ctx.TraceID = parent.TraceID
+		parentID = parent.SpanID
+	} else {
+		if t.ratio < 1 && rand.Float64() >= t.ratio {
+			return nil
+		}
+		ctx.TraceID = newTraceID()
+	}
+	ctx.Sampled = true
+
+	return &Span{tracer: t, ctx: ctx, parent: parentID, name: name, kind: kind, start: time.Now()}
+}
+
+// Close exports the remaining spans and closes the exporters
+func (t *Tracer) Close() {
+	if t == nil {
+		return
+	}
+	close(t.queue)
+	t.wg.Wait()
+
+	for _, exp := range t.exporters {
+		if err := exp.close(); err != nil {
+			t.logger.Error("failed to close span exporter", logger.ErrorAttr(err))
+		}
+	}
+	if dropped, failed := t.dropped.Load(), t.failed.Load(); dropped > 0 || failed > 0 {
+		t.logger.Warn("spans lost", logger.Any("dropped", dropped), logger.Any("exportFailed", failed))
+	}
+}
+
+// enqueue hands an ended span to the exporter without blocking the caller
+func (t *Tracer) enqueue(s *Span) {
+	select {
+	case t.queue <- s:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+func (t *Tracer) run() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(batchTimeout)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		body, err := encode(t.service, batch)
+		if err != nil {
+			t.failed.Add(int64(len(batch)))
+			t.logger.Error("failed to encode spans", logger.ErrorAttr(err))
+		} else {
+			for _, exp := range t.exporters {
+				if err := exp.export(body); err != nil {
+					t.failed.Add(int64(len(batch)))
+					t.logger.Error("failed to export spans", logger.Int("spans", len(batch)), logger.ErrorAttr(err))
+				}
+			}
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case s, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) == batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
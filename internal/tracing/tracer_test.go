@@ -0,0 +1,168 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewDisabled(t *testing.T) {
+	tr, err := New(WithSampleRatio(0.5))
+	if err != nil || tr != nil {
+		t.Fatalf("New without exporters = %v, %v, want nil, nil", tr, err)
+	}
+
+	// A nil tracer and its nil spans ignore every call
+	span := tr.Start("publish", KindProducer, SpanContext{})
+	span.SetAttr("topic", "a")
+	span.End(nil)
+	if span.Context().IsValid() {
+		t.Error("nil span has a valid context")
+	}
+	tr.Close()
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, options := range [][]Option{
+		{WithOTLPEndpoint("http://localhost:4318"), WithSampleRatio(1.5)},
+		{WithOTLPEndpoint("grpc://localhost:4317")},
+		{WithOTLPEndpoint("localhost:4318")},
+		{WithFile(filepath.Join(t.TempDir(), "missing", "spans.jsonl"))},
+	} {
+		if _, err := New(options...); err == nil {
+			t.Errorf("New succeeded, want error")
+		}
+	}
+}
+
+func TestStartSampling(t *testing.T) {
+	tr, err := New(WithFile(filepath.Join(t.TempDir(), "spans.jsonl")), WithSampleRatio(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	if span := tr.Start("connect", KindClient, SpanContext{}); span != nil {
+		t.Error("root span recorded with sample ratio 0")
+	}
+
+	parent := SpanContext{TraceID: newTraceID(), SpanID: newSpanID()}
+	if span := tr.Start("receive", KindConsumer, parent); span != nil {
+		t.Error("child of an unsampled parent recorded")
+	}
+
+	// Children follow the sampled parent regardless of the ratio
+	parent.Sampled = true
+	span := tr.Start("receive", KindConsumer, parent)
+	if span == nil {
+		t.Fatal("child of a sampled parent not recorded")
+	}
+	if sc := span.Context(); sc.TraceID != parent.TraceID || sc.SpanID == parent.SpanID || !sc.Sampled {
+		t.Errorf("child context = %+v, want the trace of %+v", sc, parent)
+	}
+	span.End(nil)
+}
+
+// exported decodes the spans of OTLP JSON export requests
+func exported(t *testing.T, bodies ...[]byte) []spanJSON {
+	t.Helper()
+	var spans []spanJSON
+	for _, body := range bodies {
+		var req exportRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Fatal(err)
+		}
+		for _, rs := range req.ResourceSpans {
+			if len(rs.Resource.Attributes) != 1 || *rs.Resource.Attributes[0].Value.StringValue != "bench-test" {
+				t.Errorf("resource attributes = %+v, want service.name bench-test", rs.Resource.Attributes)
+			}
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func TestTracerOTLP(t *testing.T) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request to %s with %s, want /v1/traces with JSON", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	tr, err := New(WithOTLPEndpoint(srv.URL), WithService("bench-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tr.Start("publish", KindProducer, SpanContext{})
+	root.SetAttr("messaging.destination.name", "bench/0")
+	root.SetAttr("messaging.message.body.size", 128)
+	root.SetAttr("retained", false)
+	child := tr.Start("puback", KindInternal, root.Context())
+	child.End(errors.New("timeout"))
+	root.End(nil)
+	root.End(nil) // Ending twice exports once
+	tr.Close()
+
+	mu.Lock()
+	spans := exported(t, bodies...)
+	mu.Unlock()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if c.Name != "puback" || c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID {
+		t.Errorf("child = %+v, want a child of %s", c, r.SpanID)
+	}
+	if c.Status.Code != statusError || c.Status.Message != "timeout" {
+		t.Errorf("child status = %+v, want error timeout", c.Status)
+	}
+	if r.Kind != KindProducer || r.ParentSpanID != "" || r.Status.Code != statusOK {
+		t.Errorf("root = %+v, want an OK producer span without parent", r)
+	}
+	if len(r.TraceID) != 32 || len(r.SpanID) != 16 {
+		t.Errorf("IDs %q, %q, want 32 and 16 hex digits", r.TraceID, r.SpanID)
+	}
+	if len(r.Attributes) != 3 || *r.Attributes[1].Value.IntValue != "128" || *r.Attributes[2].Value.BoolValue {
+		t.Errorf("root attributes = %+v", r.Attributes)
+	}
+}
+
+func TestTracerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	tr, err := New(WithFile(path), WithService("bench-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		tr.Start("connect", KindClient, SpanContext{}).End(nil)
+	}
+	tr.Close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bodies [][]byte
+	for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		bodies = append(bodies, []byte(line))
+	}
+	if spans := exported(t, bodies...); len(spans) != 3 {
+		t.Errorf("exported %d spans, want 3", len(spans))
+	}
+}
@@ -10,17 +10,20 @@ import (
 
 // Config represents the entire yaml config file fields
 type Config struct {
-	Name        string `yaml:"name"`
-	Version     string `yaml:"version"`
-	Environment string `yaml:"environment"`
-	Server      server `yaml:"server"`
-	Client      Client `yaml:"client"`
+	Name        string  `yaml:"name"`
+	Version     string  `yaml:"version"`
+	Environment string  `yaml:"environment"`
+	Server      server  `yaml:"server"`
+	Client      Client  `yaml:"client"`
+	Cluster     Cluster `yaml:"cluster"`
 }
 
 // Server represents the server configuration fields
 type server struct {
-	Host string `yaml:"host"`
-	Port uint16 `yaml:"port"`
+	Host     string   `yaml:"host"`
+	Port     uint16   `yaml:"port"`
+	Brokers  []string `yaml:"brokers"`  // Broker URLs, overriding host and port when set
+	Strategy string   `yaml:"strategy"` // Distribution of clients over the brokers
 }
 
 // Client represents the client configuration fields
@@ -30,6 +33,14 @@ type Client struct {
 	CleanSession bool   `yaml:"clean_session"`
 	Username     string `yaml:"username"`
 	Password     string `yaml:"password"`
+	Proxy        string `yaml:"proxy"` // SOCKS5 or HTTP CONNECT proxy URL
+}
+
+// Cluster represents the broker nodes targeted by the propagation benchmark,
+// each entry is a host or host:port
+type Cluster struct {
+	Publishers  []string `yaml:"publishers"`
+	Subscribers []string `yaml:"subscribers"`
 }
 
 // InitializeCfg reads the config file and returns a pointer to the Config struct
@@ -18,10 +18,60 @@ var (
 	ErrEmptyHost            = errors.New("bench: host must be non-empty")
 	ErrEmptyTopic           = errors.New("bench: topic must be non-empty")
 	ErrNilConfig            = errors.New("bench: config cannot be nil")
+	ErrInvalidMaxInflight   = errors.New("bench: max in-flight must be > 0")
+	ErrInvalidHold          = errors.New("bench: hold must be >= 0")
+	ErrInvalidNode          = errors.New("bench: node must be host or host:port")
+	ErrInvalidDrain         = errors.New("bench: drain must be >= 0")
+	ErrInvalidCompletion    = errors.New("bench: idle timeout and deadline must be >= 0")
+	ErrInvalidBroker        = errors.New("bench: broker must be a URL like tcp://host:port")
+	ErrInvalidLocalAddr     = errors.New("bench: local address must be an IP or CIDR")
+	ErrInvalidPortRange     = errors.New("bench: local ports must be a port or range like 20000-30000")
+	ErrSourcesExhausted     = errors.New("bench: local address and port combinations exhausted")
+	ErrInvalidProxyProtocol = errors.New("bench: PROXY protocol must be v1 or v2 with a source CIDR")
+	ErrUnknownStrategy      = errors.New("bench: strategy must be round-robin, random, hash or weighted")
+	ErrInvalidMonitor       = errors.New("bench: monitor interval must be >= 0")
+	ErrInvalidConformance   = errors.New("conformance: timeout, max packet and topic prefix must be set")
 	ErrPublishFailed        = errors.New("mqtt: failed to publish")
 	ErrSubscribeFailed      = errors.New("mqtt: failed to subscribe")
 	ErrUnsubscribeFailed    = errors.New("mqtt: failed to unsubscribe")
+	ErrInvalidChaos         = errors.New("chaos: invalid fault settings")
+	ErrListenFailed         = errors.New("chaos: failed to listen")
+	ErrInvalidProxy         = errors.New("mqtt: proxy must be a socks5://, socks5h:// or http:// URL")
+	ErrDialFailed           = errors.New("mqtt: failed to open network connection")
+	ErrUnknownClientImpl    = errors.New("mqtt: client implementation must be paho or native")
+	ErrConnectionRefused    = errors.New("mqtt: connection refused by broker")
+	ErrPasswordWithoutUser  = errors.New("mqtt: password requires a username")
+	ErrConnectionLost       = errors.New("mqtt: connection lost")
+	ErrNotConnected         = errors.New("mqtt: client not connected")
+	ErrUnsupportedQoS       = errors.New("mqtt: QoS not supported by native client")
+	ErrInvalidPayload       = errors.New("mqtt: payload must be string or []byte")
+	ErrAckTimeout           = errors.New("mqtt: timeout waiting for acknowledgement")
 	ErrNilCallback          = errors.New("bench: callback cannot be nil")
+	ErrInvalidPayloadSize   = errors.New("payload: size must be >= 0 and within min/max bounds")
+	ErrInvalidDistribution  = errors.New("payload: distribution must be fixed, uniform, or normal")
+	ErrPayloadReadFailed    = errors.New("payload: failed to read payload file")
+	ErrEmptyPayloadDir      = errors.New("payload: directory contains no files")
+	ErrEmptyPayloadFile     = errors.New("payload: file is empty")
+	ErrInvalidTemplate      = errors.New("payload: invalid template placeholder")
+	ErrConflictingPayloads  = errors.New("payload: only one of message, size, file, dir, template, or schema may be set")
+	ErrReportFailed         = errors.New("report: failed to write report")
+	ErrInvalidTimeline      = errors.New("timeline: interval must be > 0 and format jsonl or csv")
+	ErrTimelineWriteFailed  = errors.New("timeline: failed to write timeline")
+	ErrInvalidTracing       = errors.New("tracing: endpoint must be an http(s) URL and sample ratio within 0..1")
+	ErrNotAdjustable        = errors.New("bench: clients can only be changed while publishers run")
+	ErrInvalidSpec          = errors.New("serve: invalid benchmark spec")
+	ErrRunNotFound          = errors.New("serve: run not found")
+	ErrRunNotActive         = errors.New("serve: run is not running")
+	ErrQueueFull            = errors.New("serve: run queue is full")
+	ErrServeFailed          = errors.New("serve: failed to serve HTTP API")
+	ErrInvalidProtobuf      = errors.New("pb: malformed protobuf message")
+	ErrInvalidSparkplug     = errors.New("sparkplug: group must be set and devices and metrics >= 0")
+	ErrInvalidSparkplugMsg  = errors.New("sparkplug: invalid topic or payload")
+	ErrInvalidSchema        = errors.New("schema: unsupported or malformed schema")
+	ErrSchemaMismatch       = errors.New("schema: payload does not match schema")
+	ErrInvalidTopicTree     = errors.New("bench: topic tree needs depth, fanout, steps and publishers > 0 and wildcards from 0 to 1")
+	ErrInvalidChurn         = errors.New("bench: churn needs filters and rate > 0, dwell and publishers >= 0 and a publish rate > 0")
+	ErrInvalidLargeMessage  = errors.New("bench: large message sizes must be 0 < min <= max and factor >= 2")
 )
 
 type Error struct {